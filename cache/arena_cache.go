@@ -0,0 +1,324 @@
+package cache
+
+import (
+	"fmt"
+	"math"
+	"sync"
+)
+
+// arenaNil is the index that ends an arena list
+const arenaNil int32 = -1
+
+// arenaEntry is a cache entry stored by value in an arena. Its list links are
+// indexes into the arena instead of pointers, so with pointer-free keys and
+// payloads the garbage collector has nothing to scan in the whole arena.
+type arenaEntry[K comparable, P any] struct {
+	key            K
+	payload        P
+	prev, next     int32 // neighbours in the LRU list, or the next free slot
+	accessCount    int32
+	readAfterWrite bool
+}
+
+// arenaLRU is an LRU list of entries kept in one slice, with a map from key
+// to slot. Slots of removed entries go on a free list and are reused before
+// the slice grows.
+type arenaLRU[K comparable, P any] struct {
+	index   map[K]int32
+	entries []arenaEntry[K, P]
+	head    int32 // most recently used
+	tail    int32 // least recently used
+	free    int32 // first free slot, linked through next
+	len     int
+}
+
+func newArenaLRU[K comparable, P any](entryLimit int) arenaLRU[K, P] {
+	if entryLimit > math.MaxInt32 {
+		panic(fmt.Sprintf("cache: arena entry limit %d exceeds %d", entryLimit, math.MaxInt32))
+	}
+	return arenaLRU[K, P]{
+		index:   make(map[K]int32, entryLimit),
+		entries: make([]arenaEntry[K, P], 0, entryLimit),
+		head:    arenaNil,
+		tail:    arenaNil,
+		free:    arenaNil,
+	}
+}
+
+// at returns the entry in slot i
+func (a *arenaLRU[K, P]) at(i int32) *arenaEntry[K, P] {
+	return &a.entries[i]
+}
+
+// insert stores a new entry at the front of the list and returns its slot
+func (a *arenaLRU[K, P]) insert(key K, payload P) int32 {
+	var i int32
+	if a.free != arenaNil {
+		i = a.free
+		a.free = a.entries[i].next
+	} else {
+		i = int32(len(a.entries))
+		a.entries = append(a.entries, arenaEntry[K, P]{})
+	}
+
+	a.entries[i] = arenaEntry[K, P]{key: key, payload: payload}
+	a.index[key] = i
+	a.pushFront(i)
+	a.len++
+	return i
+}
+
+// delete removes the entry in slot i and puts the slot on the free list
+func (a *arenaLRU[K, P]) delete(i int32) {
+	a.unlink(i)
+	delete(a.index, a.entries[i].key)
+
+	// Drop the key and payload so a free slot keeps nothing alive
+	a.entries[i] = arenaEntry[K, P]{prev: arenaNil, next: a.free}
+	a.free = i
+	a.len--
+}
+
+// moveToFront makes the entry in slot i the most recently used
+func (a *arenaLRU[K, P]) moveToFront(i int32) {
+	if i == a.head {
+		return
+	}
+	a.unlink(i)
+	a.pushFront(i)
+}
+
+func (a *arenaLRU[K, P]) pushFront(i int32) {
+	e := &a.entries[i]
+	e.prev = arenaNil
+	e.next = a.head
+	if a.head != arenaNil {
+		a.entries[a.head].prev = i
+	} else {
+		a.tail = i
+	}
+	a.head = i
+}
+
+func (a *arenaLRU[K, P]) unlink(i int32) {
+	e := &a.entries[i]
+	if e.prev != arenaNil {
+		a.entries[e.prev].next = e.next
+	} else {
+		a.head = e.next
+	}
+	if e.next != arenaNil {
+		a.entries[e.next].prev = e.prev
+	} else {
+		a.tail = e.prev
+	}
+	e.prev, e.next = arenaNil, arenaNil
+}
+
+// statistics fills in the on-demand fields of stats from the live entries
+func (a *arenaLRU[K, P]) statistics(stats *Statistics) {
+	if a.len == 0 {
+		return
+	}
+
+	totalAccesses := 0
+	for i := a.head; i != arenaNil; i = a.entries[i].next {
+		e := &a.entries[i]
+		totalAccesses += int(e.accessCount)
+		if !e.readAfterWrite {
+			stats.CurrentNeverRead++
+		}
+	}
+	stats.AverageAccessCount = float64(totalAccesses) / float64(a.len)
+}
+
+// ArenaCache implements an LRU cache whose entries live in a single slice
+// linked by int32 indexes, instead of one heap object per entry linked by
+// pointers. For large caches with pointer-free keys and values the garbage
+// collector sees a handful of objects rather than millions, which keeps GC
+// cycles short. The arena is allocated for entryLimit entries up front.
+type ArenaCache[K comparable, V any] struct {
+	mu         sync.Mutex
+	entryLimit int
+	lru        arenaLRU[K, V]
+	stats      *Statistics
+	cloner     cloning[V] // deep copies values on Put and Get, set by SetCloner
+}
+
+// NewArenaCache creates a new arena-backed LRU cache with the given entry
+// limit. A limit below 1 holds one entry, as a Cache does.
+func NewArenaCache[K comparable, V any](entryLimit int) *ArenaCache[K, V] {
+	entryLimit = max(entryLimit, 1)
+	return &ArenaCache[K, V]{
+		entryLimit: entryLimit,
+		lru:        newArenaLRU[K, V](entryLimit),
+		stats:      newStatistics(),
+	}
+}
+
+// SetCloner makes the cache deep copy values with clone when mode says so
+func (c *ArenaCache[K, V]) SetCloner(clone Cloner[V], mode CloneMode) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.cloner = cloning[V]{clone: clone, mode: mode}
+}
+
+// Put adds the value to the cache, and returns a boolean to indicate whether
+// a value already existed in the cache for that key
+func (c *ArenaCache[K, V]) Put(key K, value V) bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if debugValidate {
+		defer c.debugCheckLocked()
+	}
+
+	c.stats.IncrementWrites()
+	value = c.cloner.onPut(value)
+
+	if i, exists := c.lru.index[key]; exists {
+		e := c.lru.at(i)
+		e.payload = value
+		e.readAfterWrite = false
+		c.lru.moveToFront(i)
+		return true
+	}
+
+	if c.lru.len >= c.entryLimit && c.lru.len > 0 {
+		if !c.lru.at(c.lru.tail).readAfterWrite {
+			c.stats.IncrementNeverRead()
+		}
+		c.lru.delete(c.lru.tail)
+		c.stats.IncrementEvictions()
+	}
+
+	c.lru.insert(key, value)
+	return false
+}
+
+// Get returns a copy of the value associated with key
+func (c *ArenaCache[K, V]) Get(key K) (*V, bool) {
+	value, found := c.GetValue(key)
+	if !found {
+		return nil, false
+	}
+	return &value, true
+}
+
+// GetValue is Get returning the copy of the value itself
+func (c *ArenaCache[K, V]) GetValue(key K) (V, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.stats.IncrementReads()
+
+	i, exists := c.lru.index[key]
+	if !exists {
+		c.stats.IncrementMisses()
+		var zero V
+		return zero, false
+	}
+
+	e := c.lru.at(i)
+	e.accessCount++
+	e.readAfterWrite = true
+	c.lru.moveToFront(i)
+	c.stats.IncrementHits()
+
+	return c.cloner.onGet(e.payload), true
+}
+
+// Delete removes the key from the cache, and returns a boolean to indicate
+// whether a value existed for that key
+func (c *ArenaCache[K, V]) Delete(key K) bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	i, exists := c.lru.index[key]
+	if !exists {
+		return false
+	}
+
+	c.lru.delete(i)
+	c.stats.IncrementDeletes()
+	if debugValidate {
+		c.debugCheckLocked()
+	}
+
+	return true
+}
+
+// GetStatistics returns consistent statistics about the cache
+func (c *ArenaCache[K, V]) GetStatistics() Statistics {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	stats := *c.stats
+	c.lru.statistics(&stats)
+	return stats
+}
+
+// Validate checks the internal invariants of the cache, like Cache.Validate,
+// and that the free list holds every slot not in use
+func (c *ArenaCache[K, V]) Validate() error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	return c.lru.validate(c.entryLimit)
+}
+
+// debugCheckLocked panics if the cache is invalid; the caller must hold c.mu
+func (c *ArenaCache[K, V]) debugCheckLocked() {
+	if err := c.lru.validate(c.entryLimit); err != nil {
+		panic(err)
+	}
+}
+
+// validate walks an arena list from head to tail and then the free list,
+// checking the links against the index
+func (a *arenaLRU[K, P]) validate(entryLimit int) error {
+	if a.len > entryLimit {
+		return fmt.Errorf("%w: %d entries exceed the limit of %d", errInvalid, a.len, entryLimit)
+	}
+	if a.len != len(a.index) {
+		return fmt.Errorf("%w: %d indexed keys but the list counts %d entries", errInvalid, len(a.index), a.len)
+	}
+
+	count := 0
+	prev := arenaNil
+	for i := a.head; i != arenaNil; prev, i = i, a.entries[i].next {
+		if i < 0 || int(i) >= len(a.entries) {
+			return fmt.Errorf("%w: link to slot %d outside the arena", errInvalid, i)
+		}
+		if count++; count > a.len {
+			return fmt.Errorf("%w: list has a cycle or more entries than the index (%d)", errInvalid, a.len)
+		}
+		e := &a.entries[i]
+		if e.prev != prev {
+			return fmt.Errorf("%w: entry %v has a bad previous link", errInvalid, e.key)
+		}
+		if j, exists := a.index[e.key]; !exists || j != i {
+			return fmt.Errorf("%w: entry %v is not the one indexed under its key", errInvalid, e.key)
+		}
+	}
+	if prev != a.tail {
+		return fmt.Errorf("%w: walk from head doesn't end at tail", errInvalid)
+	}
+	if count != a.len {
+		return fmt.Errorf("%w: list links %d entries but counts %d", errInvalid, count, a.len)
+	}
+
+	free := 0
+	for i := a.free; i != arenaNil; i = a.entries[i].next {
+		if i < 0 || int(i) >= len(a.entries) {
+			return fmt.Errorf("%w: free list links slot %d outside the arena", errInvalid, i)
+		}
+		if free++; count+free > len(a.entries) {
+			return fmt.Errorf("%w: free list has a cycle or overlaps the entries", errInvalid)
+		}
+	}
+	if count+free != len(a.entries) {
+		return fmt.Errorf("%w: %d entries and %d free slots in an arena of %d", errInvalid, count, free, len(a.entries))
+	}
+	return nil
+}
@@ -0,0 +1,228 @@
+package cache
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"math/rand/v2"
+	"sync"
+	"testing"
+)
+
+func TestArenaCacheOperations(t *testing.T) {
+	c := NewArenaCache[string, int](3)
+
+	if c.Put("one", 1) {
+		t.Error("Key shouldn't exist on first Put")
+	}
+	if !c.Put("one", 100) {
+		t.Error("Key should exist on second Put")
+	}
+	if v, found := c.Get("one"); !found || *v != 100 {
+		t.Errorf("Expected value 100, got %v, %v", deref(v), found)
+	}
+
+	c.Put("two", 2)
+	c.Put("three", 3)
+	c.Get("one")
+	c.Put("four", 4) // Should evict "two"
+
+	if _, found := c.Get("two"); found {
+		t.Error("Key 'two' should have been evicted")
+	}
+	for _, key := range []string{"one", "three", "four"} {
+		if _, found := c.GetValue(key); !found {
+			t.Errorf("Key %q should still be cached", key)
+		}
+	}
+	if !c.Delete("one") || c.Delete("one") {
+		t.Error("Expected the first Delete of 'one' to succeed and the second to fail")
+	}
+
+	stats := c.GetStatistics()
+	if stats.Evictions != 1 || stats.NeverReadCount != 1 || stats.Deletes != 1 {
+		t.Errorf("Expected 1 eviction of a never read entry and 1 delete, got %+v", stats)
+	}
+	if err := c.Validate(); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestArenaCacheReusesSlots(t *testing.T) {
+	c := NewArenaCache[int, int](4)
+	rng := rand.New(rand.NewPCG(1, 0))
+	for i := 0; i < 1000; i++ {
+		key := rng.IntN(10)
+		switch rng.IntN(3) {
+		case 0:
+			c.Delete(key)
+		case 1:
+			c.Get(key)
+		default:
+			c.Put(key, i)
+		}
+		if err := c.Validate(); err != nil {
+			t.Fatalf("Step %d: %v", i, err)
+		}
+	}
+
+	// Evictions and deletes hand their slots to later inserts
+	if n := len(c.lru.entries); n > 4 {
+		t.Errorf("Expected the arena to stay at 4 slots, it has %d", n)
+	}
+}
+
+func TestArenaCacheValidate(t *testing.T) {
+	corruptions := map[string]func(c *ArenaCache[int, int]){
+		"unindexed entry": func(c *ArenaCache[int, int]) {
+			delete(c.lru.index, c.lru.at(c.lru.head).key)
+		},
+		"bad prev link": func(c *ArenaCache[int, int]) {
+			c.lru.at(c.lru.tail).prev = c.lru.tail
+		},
+		"cycle": func(c *ArenaCache[int, int]) {
+			c.lru.at(c.lru.tail).next = c.lru.head
+		},
+		"lost free slot": func(c *ArenaCache[int, int]) {
+			c.lru.free = arenaNil
+		},
+	}
+
+	for name, corrupt := range corruptions {
+		c := NewArenaCache[int, int](4)
+		for i := 0; i < 4; i++ {
+			c.Put(i, i)
+		}
+		c.Delete(1)
+		corrupt(c)
+		if err := c.Validate(); !errors.Is(err, errInvalid) {
+			t.Errorf("%s: expected an invariant violation, got %v", name, err)
+		}
+	}
+}
+
+func TestBytesCacheOperations(t *testing.T) {
+	c := NewBytesCache[string](3, 16)
+
+	value := []byte("hello")
+	c.Put("greeting", value)
+	value[0] = 'j' // Put copied it
+
+	v, found := c.Get("greeting")
+	if !found || string(*v) != "hello" {
+		t.Fatalf("Expected hello, got %v, %v", v, found)
+	}
+	(*v)[0] = 'c' // and Get copied it out
+	if v, _ := c.GetValue("greeting"); string(v) != "hello" {
+		t.Errorf("Changing a returned value changed the cache to %q", v)
+	}
+
+	// Larger than a chunk, and empty
+	big := bytes.Repeat([]byte("x"), 40)
+	c.Put("big", big)
+	c.Put("empty", nil)
+	if v, _ := c.GetValue("big"); !bytes.Equal(v, big) {
+		t.Errorf("Expected the 40 byte value back, got %q", v)
+	}
+	if v, found := c.GetValue("empty"); !found || len(v) != 0 {
+		t.Errorf("Expected an empty value, got %q, %v", v, found)
+	}
+
+	c.Put("more", []byte("data")) // Evicts "greeting"
+	if _, found := c.Get("greeting"); found {
+		t.Error("Key 'greeting' should have been evicted")
+	}
+	if err := c.Validate(); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestArenaCacheNegativeLimit(t *testing.T) {
+	// A limit below 1 holds a single entry instead of panicking
+	c := NewArenaCache[int, int](-1)
+	b := NewBytesCache[int](-1, 0)
+	for key := 0; key < 3; key++ {
+		c.Put(key, key)
+		b.Put(key, []byte{byte(key)})
+	}
+	if _, found := c.Get(2); !found || c.GetStatistics().Evictions != 2 {
+		t.Errorf("Expected the arena cache to keep only the last key, got %+v", c.GetStatistics())
+	}
+	if _, found := b.Get(2); !found || b.GetStatistics().Evictions != 2 {
+		t.Errorf("Expected the bytes cache to keep only the last key, got %+v", b.GetStatistics())
+	}
+}
+
+func TestBytesCacheRejectsOversizedValues(t *testing.T) {
+	c := NewBytesCache[string](3, 16)
+	c.maxValue = 32 // Stands in for the 2 GiB an int32 offset can address
+
+	c.Put("key", []byte("small"))
+	if existed := c.Put("key", make([]byte, 33)); !existed {
+		t.Error("Put should report that 'key' existed")
+	}
+	if _, found := c.Get("key"); found {
+		t.Error("An oversized value should not be cached, nor leave the old one behind")
+	}
+	c.Put("fits", make([]byte, 32))
+	if v, found := c.GetValue("fits"); !found || len(v) != 32 {
+		t.Errorf("Expected a value of exactly the limit to be cached, got %d bytes, %v", len(v), found)
+	}
+	if err := c.Validate(); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestBytesCacheCompaction(t *testing.T) {
+	c := NewBytesCache[int](8, 64)
+
+	// Overwrites leave garbage behind; compaction keeps it bounded
+	for i := 0; i < 1000; i++ {
+		key := i % 8
+		c.Put(key, []byte(fmt.Sprintf("value %d of key %d", i, key)))
+		if err := c.Validate(); err != nil {
+			t.Fatalf("Step %d: %v", i, err)
+		}
+	}
+
+	for key := 0; key < 8; key++ {
+		i := 992 + key
+		want := fmt.Sprintf("value %d of key %d", i, key)
+		if v, _ := c.GetValue(key); string(v) != want {
+			t.Errorf("Expected %q, got %q", want, v)
+		}
+	}
+	if c.used > 4*64 {
+		t.Errorf("Expected compaction to keep the chunks small, %d bytes used with %d garbage", c.used, c.garbage)
+	}
+}
+
+func TestBytesCacheConcurrent(t *testing.T) {
+	c := NewBytesCache[int](100, 256)
+
+	var wg sync.WaitGroup
+	for i := 0; i < 8; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for j := 0; j < 500; j++ {
+				key := (i*31 + j) % 200
+				switch j % 4 {
+				case 0:
+					c.Put(key, []byte(fmt.Sprint(key)))
+				case 1:
+					c.Delete(key)
+				default:
+					if v, found := c.GetValue(key); found && string(v) != fmt.Sprint(key) {
+						t.Errorf("Key %d has value %q", key, v)
+					}
+				}
+			}
+		}()
+	}
+	wg.Wait()
+
+	if err := c.Validate(); err != nil {
+		t.Error(err)
+	}
+}
@@ -0,0 +1,289 @@
+package cache
+
+import (
+	"concurrency/workload"
+	"fmt"
+	"io"
+	"runtime"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// The benchmark matrix runs every implementation across key types, read
+// ratios, hit ratios and GOMAXPROCS levels. Sub-benchmark names are
+// key=value pairs, so a slice of the matrix can be selected and compared
+// with benchstat, for example:
+//
+//	go test -bench 'Matrix/impl=sharded/key=string' -count 10 ./cache > new.txt
+//	benchstat old.txt new.txt
+
+// benchCapacity is the entry limit of every benchmarked cache
+const benchCapacity = 10_000
+
+// benchTraceLen is the length of the pre-generated trace each goroutine cycles through
+const benchTraceLen = 1 << 16
+
+// benchCache is what the benchmarks need from an implementation
+type benchCache[K comparable] interface {
+	Get(key K) (*int, bool)
+	Put(key K, value int) bool
+	GetStatistics() Statistics
+}
+
+// benchImpls lists the implementations in the matrix
+var benchImpls = []string{"mutex", "rwmutex", "sharded", "channel", "arena", "slru", "2q", "sieve", "s3fifo", "sampled"}
+
+func newBenchCache[K comparable](impl string) benchCache[K] {
+	switch impl {
+	case "mutex":
+		return NewCache[K, int](benchCapacity)
+	case "rwmutex":
+		return NewRWMutexCache[K, int](benchCapacity)
+	case "sharded":
+		return NewShardedCache[K, int](benchCapacity, 16)
+	case "channel":
+		return NewChannelCache[K, int](benchCapacity)
+	case "arena":
+		return NewArenaCache[K, int](benchCapacity)
+	case "slru":
+		return NewSLRUCache[K, int](benchCapacity, 0.8)
+	case "2q":
+		return NewTwoQueueCache[K, int](benchCapacity)
+	case "sieve":
+		return NewSIEVECache[K, int](benchCapacity)
+	case "s3fifo":
+		return NewS3FIFOCache[K, int](benchCapacity)
+	case "sampled":
+		return NewSampledCache[K, int](benchCapacity, 0)
+	default:
+		panic("unknown implementation " + impl)
+	}
+}
+
+// structKey is a composite key, as used for multi-tenant caches
+type structKey struct {
+	tenant uint32
+	id     uint64
+}
+
+// benchProcs returns the GOMAXPROCS levels to benchmark
+func benchProcs() []int {
+	levels := []int{1}
+	for _, n := range []int{4, runtime.NumCPU()} {
+		if n > levels[len(levels)-1] {
+			levels = append(levels, n)
+		}
+	}
+	return levels
+}
+
+func BenchmarkMatrix(b *testing.B) {
+	for _, impl := range benchImpls {
+		b.Run("impl="+impl, func(b *testing.B) {
+			b.Run("key=string", func(b *testing.B) {
+				benchmarkKeyType(b, impl, workload.Key)
+			})
+			b.Run("key=int", func(b *testing.B) {
+				benchmarkKeyType(b, impl, func(id uint64) int { return int(id) })
+			})
+			b.Run("key=struct", func(b *testing.B) {
+				benchmarkKeyType(b, impl, func(id uint64) structKey { return structKey{tenant: uint32(id % 7), id: id} })
+			})
+		})
+	}
+}
+
+func benchmarkKeyType[K comparable](b *testing.B, impl string, keyOf func(uint64) K) {
+	for _, readPct := range []int{50, 90, 99} {
+		for _, hitPct := range []int{50, 90} {
+			for _, procs := range benchProcs() {
+				name := fmt.Sprintf("reads=%d/hits=%d/procs=%d", readPct, hitPct, procs)
+				b.Run(name, func(b *testing.B) {
+					c := newBenchCache[K](impl)
+					if closer, ok := c.(io.Closer); ok {
+						defer closer.Close()
+					}
+					benchmarkWorkload(b, c, keyOf, readPct, hitPct, procs)
+				})
+			}
+		}
+	}
+}
+
+// benchmarkWorkload runs uniform traffic with the given read ratio over a key
+// space sized so that roughly hitPct of reads hit, with procs set as GOMAXPROCS
+func benchmarkWorkload[K comparable](b *testing.B, c benchCache[K], keyOf func(uint64) K, readPct, hitPct, procs int) {
+	defer runtime.GOMAXPROCS(runtime.GOMAXPROCS(procs))
+
+	numKeys := uint64(benchCapacity * 100 / hitPct)
+	keys := make([]K, numKeys)
+	for i := range keys {
+		keys[i] = keyOf(uint64(i))
+		c.Put(keys[i], i)
+	}
+
+	// One trace per goroutine, generated before the timer starts
+	mix := workload.Mix{Read: float64(readPct), Write: float64(100 - readPct)}
+	traces := make([][]workload.Operation, procs)
+	for i := range traces {
+		traces[i] = must(workload.New(must(workload.NewUniform(numKeys, uint64(i))), mix, uint64(i))).Trace(benchTraceLen)
+	}
+	before := c.GetStatistics()
+
+	var next atomic.Int32
+	b.ReportAllocs()
+	b.ResetTimer()
+
+	b.RunParallel(func(pb *testing.PB) {
+		trace := traces[int(next.Add(1)-1)%len(traces)]
+		for i := 0; pb.Next(); i++ {
+			op := trace[i&(benchTraceLen-1)]
+			if op.Kind == workload.Read {
+				c.Get(keys[op.Key])
+			} else {
+				c.Put(keys[op.Key], i)
+			}
+		}
+	})
+
+	b.StopTimer()
+	after := c.GetStatistics()
+	if reads := after.Reads - before.Reads; reads > 0 {
+		b.ReportMetric(float64(after.Hits-before.Hits)/float64(reads)*100, "hit%")
+	}
+}
+
+// BenchmarkReadHeavy compares the caches whose Get takes a read lock with
+// RWMutexCache, whose Get must take the write lock to reorder its LRU list,
+// on mostly read traffic where that difference decides scalability
+func BenchmarkReadHeavy(b *testing.B) {
+	for _, impl := range []string{"rwmutex", "sieve", "s3fifo", "sampled"} {
+		for _, readPct := range []int{95, 99, 100} {
+			for _, procs := range benchProcs() {
+				name := fmt.Sprintf("impl=%s/reads=%d/procs=%d", impl, readPct, procs)
+				b.Run(name, func(b *testing.B) {
+					c := newBenchCache[int](impl)
+					benchmarkWorkload(b, c, func(id uint64) int { return int(id) }, readPct, 90, procs)
+				})
+			}
+		}
+	}
+}
+
+// BenchmarkHotKeysRecord measures recording a use in HotKeys from several
+// goroutines on skewed traffic, the cost HotKeyCache adds to every Get and Put
+func BenchmarkHotKeysRecord(b *testing.B) {
+	for _, procs := range benchProcs() {
+		b.Run(fmt.Sprintf("procs=%d", procs), func(b *testing.B) {
+			defer runtime.GOMAXPROCS(runtime.GOMAXPROCS(procs))
+
+			traces := make([][]int, procs)
+			for i := range traces {
+				gen := must(workload.NewZipfian(100_000, 0.99, uint64(i)))
+				traces[i] = make([]int, benchTraceLen)
+				for j := range traces[i] {
+					traces[i][j] = int(gen.Next())
+				}
+			}
+			h := NewHotKeys[int](100)
+
+			var next atomic.Int32
+			b.ReportAllocs()
+			b.ResetTimer()
+
+			b.RunParallel(func(pb *testing.PB) {
+				trace := traces[int(next.Add(1)-1)%len(traces)]
+				for i := 0; pb.Next(); i++ {
+					h.Record(trace[i&(benchTraceLen-1)])
+				}
+			})
+		})
+	}
+}
+
+// BenchmarkMemoryPerEntry reports the heap held per entry of a full cache
+// with int keys and values, which is mostly the bookkeeping of the LRU
+func BenchmarkMemoryPerEntry(b *testing.B) {
+	for _, impl := range benchImpls {
+		b.Run("impl="+impl, func(b *testing.B) {
+			var perEntry float64
+			for i := 0; i < b.N; i++ {
+				var before, after runtime.MemStats
+				runtime.GC()
+				runtime.ReadMemStats(&before)
+
+				c := newBenchCache[int](impl)
+				for key := 0; key < benchCapacity; key++ {
+					c.Put(key, key)
+				}
+
+				runtime.GC()
+				runtime.ReadMemStats(&after)
+				perEntry = float64(int64(after.HeapAlloc)-int64(before.HeapAlloc)) / benchCapacity
+
+				runtime.KeepAlive(c)
+				if closer, ok := c.(io.Closer); ok {
+					closer.Close()
+				}
+			}
+			b.ReportMetric(perEntry, "B/entry")
+			b.ReportMetric(0, "ns/op")
+		})
+	}
+}
+
+// gcBenchEntries is the size of the caches in BenchmarkGCLargeCache
+const gcBenchEntries = 1 << 20
+
+// BenchmarkGCLargeCache fills caches with a million 64 byte values and
+// reports the heap they hold, how long a full collection takes with them
+// live, and the stop-the-world pause of that collection
+func BenchmarkGCLargeCache(b *testing.B) {
+	type filler interface {
+		Put(key int, value []byte) bool
+	}
+	impls := []struct {
+		name string
+		new  func() filler
+	}{
+		{"mutex", func() filler { return NewCache[int, []byte](gcBenchEntries) }},
+		{"sharded", func() filler { return NewShardedCache[int, []byte](gcBenchEntries, 16) }},
+		{"arena", func() filler { return NewArenaCache[int, []byte](gcBenchEntries) }},
+		{"bytes", func() filler { return NewBytesCache[int](gcBenchEntries, 0) }},
+	}
+
+	value := make([]byte, 64)
+	for _, impl := range impls {
+		b.Run("impl="+impl.name, func(b *testing.B) {
+			var heapMB, gcMs, pauseUs float64
+			for i := 0; i < b.N; i++ {
+				var before, filled, collected runtime.MemStats
+				runtime.GC()
+				runtime.ReadMemStats(&before)
+
+				c := impl.new()
+				for key := 0; key < gcBenchEntries; key++ {
+					// A fresh slice per entry, as values decoded from a request would be
+					c.Put(key, append([]byte(nil), value...))
+				}
+				runtime.GC()
+				runtime.ReadMemStats(&filled)
+
+				start := time.Now()
+				runtime.GC()
+				elapsed := time.Since(start)
+				runtime.ReadMemStats(&collected)
+
+				heapMB = float64(int64(filled.HeapAlloc)-int64(before.HeapAlloc)) / (1 << 20)
+				gcMs = float64(elapsed.Microseconds()) / 1000
+				pauseUs = float64(collected.PauseTotalNs-filled.PauseTotalNs) / 1000
+				runtime.KeepAlive(c)
+			}
+			b.ReportMetric(heapMB, "heap-MB")
+			b.ReportMetric(gcMs, "gc-ms")
+			b.ReportMetric(pauseUs, "pause-us")
+			b.ReportMetric(0, "ns/op")
+		})
+	}
+}
@@ -0,0 +1,250 @@
+package cache
+
+import (
+	"fmt"
+	"math"
+	"sync"
+)
+
+const (
+	// defaultChunkSize is the size of the buffers BytesCache stores values in
+	defaultChunkSize = 4 << 20
+
+	// maxChunkSize is the most a bytesRef's int32 offsets can address
+	maxChunkSize = math.MaxInt32
+)
+
+// bytesRef locates a value inside the chunks of a BytesCache
+type bytesRef struct {
+	chunk  int32
+	offset int32
+	length int32
+}
+
+// BytesCache implements an LRU cache of []byte values in the style of
+// bigcache: entries live in an arena as in ArenaCache, and the value bytes
+// are copied into large chunked buffers. With pointer-free keys, the garbage
+// collector sees a few chunks and the arena no matter how many entries there
+// are. Put copies the value in and Get copies it out, so callers never share
+// memory with the cache.
+//
+// Overwritten, deleted and evicted values leave garbage in the chunks. Once
+// garbage makes up more than half of the bytes written, the live values are
+// compacted into fresh chunks.
+type BytesCache[K comparable] struct {
+	mu         sync.Mutex
+	entryLimit int
+	lru        arenaLRU[K, bytesRef]
+	chunkSize  int
+	maxValue   int      // longest value Put accepts
+	chunks     [][]byte // values, appended to the last chunk
+	used       int      // bytes written into the chunks
+	garbage    int      // bytes of used that no entry refers to
+	stats      *Statistics
+}
+
+// NewBytesCache creates a new LRU cache of []byte values. chunkSize is the
+// size of the value buffers; zero or less selects 4 MiB, and it is capped at
+// 2 GiB. Values larger than a chunk get a chunk of their own. A limit below 1
+// holds one entry, as a Cache does.
+func NewBytesCache[K comparable](entryLimit, chunkSize int) *BytesCache[K] {
+	if chunkSize <= 0 {
+		chunkSize = defaultChunkSize
+	}
+	chunkSize = min(chunkSize, maxChunkSize)
+	entryLimit = max(entryLimit, 1)
+	return &BytesCache[K]{
+		entryLimit: entryLimit,
+		lru:        newArenaLRU[K, bytesRef](entryLimit),
+		chunkSize:  chunkSize,
+		maxValue:   maxChunkSize,
+		stats:      newStatistics(),
+	}
+}
+
+// Put copies the value into the cache, and returns a boolean to indicate
+// whether a value already existed in the cache for that key. Values of 2 GiB
+// or more don't fit in a chunk and aren't cached: the key's old value, if any,
+// is removed instead, as though the new one had been evicted straight away.
+func (c *BytesCache[K]) Put(key K, value []byte) bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if debugValidate {
+		defer c.debugCheckLocked()
+	}
+
+	c.stats.IncrementWrites()
+
+	i, exists := c.lru.index[key]
+	if len(value) > c.maxValue {
+		if exists {
+			c.garbage += int(c.lru.at(i).payload.length)
+			c.lru.delete(i)
+			c.maybeCompactLocked()
+		}
+		c.stats.IncrementEvictions()
+		return exists
+	}
+
+	if exists {
+		e := c.lru.at(i)
+		c.garbage += int(e.payload.length)
+		e.payload = c.writeLocked(value)
+		e.readAfterWrite = false
+		c.lru.moveToFront(i)
+		c.maybeCompactLocked()
+		return true
+	}
+
+	if c.lru.len >= c.entryLimit && c.lru.len > 0 {
+		tail := c.lru.at(c.lru.tail)
+		if !tail.readAfterWrite {
+			c.stats.IncrementNeverRead()
+		}
+		c.garbage += int(tail.payload.length)
+		c.lru.delete(c.lru.tail)
+		c.stats.IncrementEvictions()
+	}
+
+	c.lru.insert(key, c.writeLocked(value))
+	c.maybeCompactLocked()
+	return false
+}
+
+// Get returns a copy of the value associated with key
+func (c *BytesCache[K]) Get(key K) (*[]byte, bool) {
+	value, found := c.GetValue(key)
+	if !found {
+		return nil, false
+	}
+	return &value, true
+}
+
+// GetValue is Get returning the copy of the value itself
+func (c *BytesCache[K]) GetValue(key K) ([]byte, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.stats.IncrementReads()
+
+	i, exists := c.lru.index[key]
+	if !exists {
+		c.stats.IncrementMisses()
+		return nil, false
+	}
+
+	e := c.lru.at(i)
+	e.accessCount++
+	e.readAfterWrite = true
+	c.lru.moveToFront(i)
+	c.stats.IncrementHits()
+
+	return append([]byte(nil), c.readLocked(e.payload)...), true
+}
+
+// Delete removes the key from the cache, and returns a boolean to indicate
+// whether a value existed for that key
+func (c *BytesCache[K]) Delete(key K) bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	i, exists := c.lru.index[key]
+	if !exists {
+		return false
+	}
+
+	c.garbage += int(c.lru.at(i).payload.length)
+	c.lru.delete(i)
+	c.stats.IncrementDeletes()
+	c.maybeCompactLocked()
+	if debugValidate {
+		c.debugCheckLocked()
+	}
+
+	return true
+}
+
+// GetStatistics returns consistent statistics about the cache
+func (c *BytesCache[K]) GetStatistics() Statistics {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	stats := *c.stats
+	c.lru.statistics(&stats)
+	return stats
+}
+
+// writeLocked copies value into the chunks and returns where it went
+func (c *BytesCache[K]) writeLocked(value []byte) bytesRef {
+	last := len(c.chunks) - 1
+	if last < 0 || len(c.chunks[last])+len(value) > cap(c.chunks[last]) {
+		c.chunks = append(c.chunks, make([]byte, 0, max(c.chunkSize, len(value))))
+		last++
+	}
+
+	ref := bytesRef{chunk: int32(last), offset: int32(len(c.chunks[last])), length: int32(len(value))}
+	c.chunks[last] = append(c.chunks[last], value...)
+	c.used += len(value)
+	return ref
+}
+
+// readLocked returns the bytes of a value, still inside its chunk
+func (c *BytesCache[K]) readLocked(ref bytesRef) []byte {
+	return c.chunks[ref.chunk][ref.offset : ref.offset+ref.length]
+}
+
+// maybeCompactLocked compacts the chunks once more than half of the bytes
+// written are garbage, and there is more than a chunk of it
+func (c *BytesCache[K]) maybeCompactLocked() {
+	if c.garbage > c.chunkSize && c.garbage*2 > c.used {
+		c.compactLocked()
+	}
+}
+
+// compactLocked copies every live value into fresh chunks, least recently
+// used first, and drops the old chunks
+func (c *BytesCache[K]) compactLocked() {
+	old := c.chunks
+	c.chunks, c.used, c.garbage = nil, 0, 0
+
+	for i := c.lru.tail; i != arenaNil; i = c.lru.at(i).prev {
+		e := c.lru.at(i)
+		ref := e.payload
+		e.payload = c.writeLocked(old[ref.chunk][ref.offset : ref.offset+ref.length])
+	}
+}
+
+// Validate checks the arena like ArenaCache.Validate, that every value lies
+// inside its chunk, and that the garbage count matches the values in use
+func (c *BytesCache[K]) Validate() error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	return c.validateLocked()
+}
+
+func (c *BytesCache[K]) validateLocked() error {
+	if err := c.lru.validate(c.entryLimit); err != nil {
+		return err
+	}
+
+	live := 0
+	for i := c.lru.head; i != arenaNil; i = c.lru.at(i).next {
+		ref := c.lru.at(i).payload
+		if int(ref.chunk) >= len(c.chunks) || int(ref.offset+ref.length) > len(c.chunks[ref.chunk]) {
+			return fmt.Errorf("%w: value of %v lies outside its chunk", errInvalid, c.lru.at(i).key)
+		}
+		live += int(ref.length)
+	}
+	if live != c.used-c.garbage {
+		return fmt.Errorf("%w: %d live bytes but %d used and %d garbage", errInvalid, live, c.used, c.garbage)
+	}
+	return nil
+}
+
+// debugCheckLocked panics if the cache is invalid; the caller must hold c.mu
+func (c *BytesCache[K]) debugCheckLocked() {
+	if err := c.validateLocked(); err != nil {
+		panic(err)
+	}
+}
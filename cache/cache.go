@@ -8,35 +8,92 @@ import (
 type Cache[K comparable, V any] struct {
 	mu         sync.Mutex
 	entryLimit int
-	items      map[K]*entry[V]
-	lruList    *doublyLinkedList[K]
+	items      map[K]*entry[K, V]
+	lruList    entryList[K, V]
 	stats      *Statistics
+	onEvict    func(key K, value V) // called for every entry evicted to make room
+	cloner     cloning[V]           // deep copies values on Put and Get, set by SetCloner
+	loads      map[K]*loadCall[V]   // in-flight GetOrLoad calls, guarded by mu
+	tags       *tagIndex[K]         // created by the first PutWithTags
+	prefixes   *prefixIndex[K]      // created by the first InvalidatePrefix
+	sched      scheduler            // test hook controlling interleavings, nil in production
+	contention lockContention       // waits for mu by Put, Get and Delete
+	retired    bool                 // set by ShardedCache.Reshard once keys are no longer routed here, guarded by mu
 }
 
-// entry represents a cache entry with its value and metadata
-type entry[V any] struct {
-	value          V    // value of the entry
-	accessCount    int  // number of times the entry has been accessed
-	readAfterWrite bool // true if write happened after read
+// entry represents a cache entry with its value and metadata. It's also a
+// node of the LRU list, so one map lookup finds both.
+type entry[K comparable, V any] struct {
+	key            K            // key of the entry, to delete it from the map on eviction
+	value          V            // value of the entry
+	accessCount    int          // number of times the entry has been accessed
+	readAfterWrite bool         // true if write happened after read
+	segment        segment      // list holding the entry, in caches with more than one
+	prev, next     *entry[K, V] // neighbours in the LRU list, towards the head and the tail
 }
 
+// segment names the list an entry is on. Caches with a single list leave it
+// at segmentLRU.
+type segment uint8
+
+const (
+	segmentLRU       segment = iota // the only list of a plain LRU cache
+	segmentProbation                // SLRU entries read at most once
+	segmentProtected                // SLRU entries read again while on probation
+	segmentIn                       // 2Q A1in, a FIFO of new entries
+	segmentMain                     // 2Q Am, an LRU of entries seen twice
+	segmentGhost                    // 2Q A1out, keys recently dropped from A1in
+	segmentS3Small                  // S3-FIFO small queue of new entries
+	segmentS3Main                   // S3-FIFO main queue of entries read while in the small one
+)
+
 // NewCahce creates a new LRU cache with the given entry limit
 func NewCache[K comparable, V any](entryLimit int) *Cache[K, V] {
 	return &Cache[K, V]{
 		entryLimit: entryLimit,
-		items:      make(map[K]*entry[V]),
-		lruList:    newDoublyLinkedList[K](),
+		items:      make(map[K]*entry[K, V]),
 		stats:      newStatistics(),
+		loads:      make(map[K]*loadCall[V]),
 	}
 }
 
+// OnEvict registers a callback that receives every entry evicted to make room
+// for a new one. The callback runs while the cache lock is held, so it must not
+// call back into the cache.
+func (c *Cache[K, V]) OnEvict(fn func(key K, value V)) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.onEvict = fn
+}
+
+// SetCloner makes the cache deep copy values with clone when mode says so.
+// Without a Cloner, values are copied shallowly.
+func (c *Cache[K, V]) SetCloner(clone Cloner[V], mode CloneMode) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.cloner = cloning[V]{clone: clone, mode: mode}
+}
+
 // Put adds the value to the cache, and returns a boolean to indicate whether
 // a value already existed in the cache fo that key
 func (c *Cache[K, V]) Put(key K, value V) bool {
-	c.mu.Lock()
+	c.yield(yieldBeforeLock)
+	c.contention.lock(&c.mu)
 	defer c.mu.Unlock()
 
+	return c.putLocked(key, value)
+}
+
+// putLocked implements Put; the caller must hold c.mu
+func (c *Cache[K, V]) putLocked(key K, value V) bool {
+	if debugValidate {
+		defer c.debugCheckLocked()
+	}
 	c.stats.IncrementWrites()
+	value = c.cloner.onPut(value)
+	c.supersedeLoadLocked(key)
 
 	// check if the key already exists
 	existingEntry, exists := c.items[key]
@@ -45,7 +102,7 @@ func (c *Cache[K, V]) Put(key K, value V) bool {
 		existingEntry.value = value
 		existingEntry.readAfterWrite = false
 		// move to front of LRU list (most recently used)
-		c.lruList.moveToFront(key)
+		c.lruList.moveToFront(existingEntry)
 		return true
 	}
 
@@ -53,41 +110,74 @@ func (c *Cache[K, V]) Put(key K, value V) bool {
 	// if we're at capacity, remove the least recently used item
 
 	if len(c.items) >= c.entryLimit && len(c.items) > 0 {
-		lruKey := c.lruList.removeLast() // Remove from linked list
-
-		// Update stats before removing
-		if !c.items[lruKey].readAfterWrite {
-			c.stats.IncrementNeverRead()
-		}
-
-		delete(c.items, lruKey) // Remove from map
-		c.stats.IncrementEvictions()
+		c.evictLocked()
 	}
 
 	// Add the new entry
-	c.items[key] = &entry[V]{
+	newEntry := &entry[K, V]{
+		key:            key,
 		value:          value,
 		accessCount:    0,
 		readAfterWrite: false,
 	}
-	c.lruList.addToFront(key)
+	c.items[key] = newEntry
+	c.lruList.addToFront(newEntry)
+	if c.prefixes != nil {
+		c.prefixes.insert(key)
+	}
 
 	return false
 }
 
-// Get returns the value assiocated with the passed key, and a boolean to indicate
-// whether a value was known or not
+// evictLocked removes the least recently used entry to make room for another
+func (c *Cache[K, V]) evictLocked() {
+	lruEntry := c.lruList.removeLast() // Remove from linked list
 
+	// Update stats before removing
+	if !lruEntry.readAfterWrite {
+		c.stats.IncrementNeverRead()
+	}
+
+	delete(c.items, lruEntry.key) // Remove from map
+	c.unindexLocked(lruEntry.key)
+	c.stats.IncrementEvictions()
+
+	if c.onEvict != nil {
+		c.onEvict(lruEntry.key, lruEntry.value)
+	}
+}
+
+// Get returns a copy of the value assiocated with the passed key, and a
+// boolean to indicate whether a value was known or not
 func (c *Cache[K, V]) Get(key K) (*V, bool) {
-	c.mu.Lock()
+	value, found := c.GetValue(key)
+	if !found {
+		return nil, false
+	}
+	return &value, true
+}
+
+// GetValue is Get returning the copy of the value itself
+func (c *Cache[K, V]) GetValue(key K) (V, bool) {
+	c.yield(yieldBeforeLock)
+	c.contention.lock(&c.mu)
 	defer c.mu.Unlock()
 
+	return c.getLocked(key)
+}
+
+// getLocked implements GetValue; the caller must hold c.mu
+func (c *Cache[K, V]) getLocked(key K) (V, bool) {
+	if debugValidate {
+		defer c.debugCheckLocked()
+	}
 	c.stats.IncrementReads()
 
 	entry, exists := c.items[key]
 	if !exists {
 		c.stats.IncrementMisses()
-		return nil, false
+		var zero V
+		return zero, false
 	}
 
 	// Update entry metadata
@@ -95,10 +185,65 @@ func (c *Cache[K, V]) Get(key K) (*V, bool) {
 	entry.readAfterWrite = true
 
 	// Move to front of LRU list (most recently used)
-	c.lruList.moveToFront(key)
+	c.lruList.moveToFront(entry)
 
 	c.stats.IncrementHits()
-	return &entry.value, true
+	return c.cloner.onGet(entry.value), true
+}
+
+// Delete removes the key from the cache, and returns a boolean to indicate
+// whether a value existed for that key
+func (c *Cache[K, V]) Delete(key K) bool {
+	c.yield(yieldBeforeLock)
+	c.contention.lock(&c.mu)
+	defer c.mu.Unlock()
+
+	return c.deleteLocked(key)
+}
+
+// deleteLocked implements Delete; the caller must hold c.mu
+func (c *Cache[K, V]) deleteLocked(key K) bool {
+	entry, exists := c.items[key]
+	if !exists {
+		// A load finishing after the Delete mustn't bring the key back
+		c.supersedeLoadLocked(key)
+		return false
+	}
+
+	c.removeLocked(entry)
+	c.stats.IncrementDeletes()
+
+	return true
+}
+
+// removeLocked removes an entry from the cache and from the lookup indexes
+func (c *Cache[K, V]) removeLocked(e *entry[K, V]) {
+	c.lruList.remove(e)
+	delete(c.items, e.key)
+	c.unindexLocked(e.key)
+	c.supersedeLoadLocked(e.key)
+
+	if debugValidate {
+		c.debugCheckLocked()
+	}
+}
+
+// unindexLocked drops a removed key from the tag and prefix indexes
+func (c *Cache[K, V]) unindexLocked(key K) {
+	if c.tags != nil {
+		c.tags.remove(key)
+	}
+	if c.prefixes != nil {
+		c.prefixes.remove(key)
+	}
+}
+
+// Len returns the number of entries in the cache
+func (c *Cache[K, V]) Len() int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	return len(c.items)
 }
 
 // GetStatistics returns consistent statistics about the cache
@@ -130,99 +275,83 @@ func (c *Cache[K, V]) GetStatistics() Statistics {
 	return stats
 }
 
-// Node for our doubly linked list
-type node[K comparable] struct {
-	key  K
-	prev *node[K]
-	next *node[K]
+// entryList is an intrusive doubly linked list of entries for LRU tracking,
+// most recently used at the head. The links live in the entries, so the list
+// needs no index of its own.
+type entryList[K comparable, V any] struct {
+	head *entry[K, V]
+	tail *entry[K, V]
+	len  int
 }
 
-// doublyLinkedList implements a doubly linked list for LRU tracking
-type doublyLinkedList[K comparable] struct {
-	head    *node[K]
-	tail    *node[K]
-	nodeMap map[K]*node[K] // For O(1) lookups
-}
-
-func newDoublyLinkedList[K comparable]() *doublyLinkedList[K] {
-	return &doublyLinkedList[K]{
-		nodeMap: make(map[K]*node[K]),
-	}
-}
-
-// addToFront adds a key to the front of the list (most recenly used)
-func (l *doublyLinkedList[K]) addToFront(key K) {
-	newNode := &node[K]{key: key}
-
-	// Map the key to its node for quick lookup
-	l.nodeMap[key] = newNode
+// addToFront adds an entry to the front of the list (most recenly used)
+func (l *entryList[K, V]) addToFront(e *entry[K, V]) {
+	l.len++
 
 	if l.head == nil {
 		// List is empty
-		l.head = newNode
-		l.tail = newNode
+		e.prev, e.next = nil, nil
+		l.head = e
+		l.tail = e
 		return
 	}
 
 	// Add to head
-	newNode.next = l.head
-	l.head.prev = newNode
-	l.head = newNode
+	e.prev = nil
+	e.next = l.head
+	l.head.prev = e
+	l.head = e
 }
 
-// moveToFront moves a node to the front of the list
-func (l *doublyLinkedList[K]) moveToFront(key K) {
-	node, exists := l.nodeMap[key]
-	if !exists {
+// addToBack adds an entry to the back of the list (least recently used)
+func (l *entryList[K, V]) addToBack(e *entry[K, V]) {
+	if l.tail == nil {
+		l.addToFront(e)
 		return
 	}
 
+	l.len++
+	e.prev = l.tail
+	e.next = nil
+	l.tail.next = e
+	l.tail = e
+}
+
+// moveToFront moves an entry already in the list to the front
+func (l *entryList[K, V]) moveToFront(e *entry[K, V]) {
 	// Already at front
-	if node == l.head {
+	if e == l.head {
 		return
 	}
 
-	// Remove from current position
-	if node == l.tail {
-		l.tail = node.prev
-	}
-
-	if node.prev != nil {
-		node.prev.next = node.next
-	}
-
-	if node.next != nil {
-		node.next.prev = node.prev
-	}
-
-	// Add to front
-	node.prev = nil
-	node.next = l.head
-	l.head.prev = node
-	l.head = node
+	l.remove(e)
+	l.addToFront(e)
 }
 
-// removeLast removes and returns the key at the end of the list (least recently used)
-func (l *doublyLinkedList[K]) removeLast() K {
-	if l.tail == nil {
-		var zero K
-		return zero
+// remove unlinks an entry from the list
+func (l *entryList[K, V]) remove(e *entry[K, V]) {
+	if e.prev != nil {
+		e.prev.next = e.next
+	} else {
+		l.head = e.next
 	}
 
-	lastKey := l.tail.key
-
-	//udpate tail
-	l.tail = l.tail.prev
-
-	// if this was the last node
-	if l.tail == nil {
-		l.head = nil
+	if e.next != nil {
+		e.next.prev = e.prev
 	} else {
-		l.tail.next = nil
+		l.tail = e.prev
 	}
 
-	// remove from map
-	delete(l.nodeMap, lastKey)
+	e.prev, e.next = nil, nil
+	l.len--
+}
 
-	return lastKey
+// removeLast removes and returns the entry at the end of the list (least
+// recently used), or nil if the list is empty
+func (l *entryList[K, V]) removeLast() *entry[K, V] {
+	last := l.tail
+	if last != nil {
+		l.remove(last)
+	}
+	return last
 }
@@ -127,3 +127,44 @@ func TestRWMtexCache(t *testing.T) {
 		t.Error("Key 'one' should have been ev")
 	}
 }
+
+func TestCacheDelete(t *testing.T) {
+	cache := NewCache[string, int](2)
+
+	cache.Put("one", 1)
+	cache.Put("two", 2)
+
+	if !cache.Delete("one") {
+		t.Error("Delete should report that 'one' existed")
+	}
+	if cache.Delete("one") {
+		t.Error("Deleting 'one' twice should report it missing")
+	}
+
+	// The freed slot is reused without evicting "two"
+	cache.Put("three", 3)
+	if _, found := cache.Get("two"); !found {
+		t.Error("Key 'two' should not have been evicted")
+	}
+
+	stats := cache.GetStatistics()
+	if stats.Deletes != 1 || stats.Evictions != 0 {
+		t.Errorf("Expected 1 delete and 0 evictions, got %d and %d", stats.Deletes, stats.Evictions)
+	}
+}
+
+func TestShardedCacheHoldsEveryKey(t *testing.T) {
+	// Masking hashes with the shard count instead of count-1 indexed past
+	// the last shard
+	for _, count := range []int{1, 2, 8, 5} {
+		c := NewShardedCache[int, int](100_000, count) // room for every key in any shard
+		for key := 0; key < 1000; key++ {
+			c.Put(key, key)
+		}
+		for key := 0; key < 1000; key++ {
+			if value, found := c.Get(key); !found || *value != key {
+				t.Errorf("%d shards: expected key %d to be cached", count, key)
+			}
+		}
+	}
+}
@@ -0,0 +1,314 @@
+package cache
+
+import (
+	"sync"
+)
+
+// channelCacheQueue is how many requests can wait for the owner goroutine
+// before callers block on the send
+const channelCacheQueue = 256
+
+// ChannelCache implements an LRU cache without locks: a single owner
+// goroutine holds the LRU state and serves every operation sent to it over a
+// channel. It shares memory by communicating, where the other
+// implementations communicate by sharing memory.
+type ChannelCache[K comparable, V any] struct {
+	requests  chan channelRequest[K, V]
+	quit      chan struct{} // closed by Close
+	done      chan struct{} // closed when the owner goroutine has stopped
+	closeOnce sync.Once
+	replies   sync.Pool // of chan channelReply[V]
+
+	// Owned by the goroutine running serve
+	entryLimit int
+	items      map[K]*entry[K, V]
+	lruList    entryList[K, V]
+	stats      *Statistics
+	cloner     cloning[V]
+
+	final Statistics // statistics when the owner stopped, readable once done is closed
+}
+
+type channelOp uint8
+
+const (
+	chanGet channelOp = iota
+	chanPut
+	chanDelete
+	chanStats
+	chanValidate
+	chanSetCloner
+)
+
+// channelRequest is one operation sent to the owner goroutine
+type channelRequest[K comparable, V any] struct {
+	op     channelOp
+	key    K
+	value  V
+	cloner cloning[V] // for chanSetCloner
+	reply  chan channelReply[V]
+}
+
+// channelReply is the owner goroutine's answer to a request
+type channelReply[V any] struct {
+	value V
+	found bool
+	stats Statistics
+	err   error
+}
+
+// NewChannelCache creates a new LRU cache and starts its owner goroutine.
+// Close stops the goroutine.
+func NewChannelCache[K comparable, V any](entryLimit int) *ChannelCache[K, V] {
+	c := &ChannelCache[K, V]{
+		requests:   make(chan channelRequest[K, V], channelCacheQueue),
+		quit:       make(chan struct{}),
+		done:       make(chan struct{}),
+		entryLimit: entryLimit,
+		items:      make(map[K]*entry[K, V]),
+		stats:      newStatistics(),
+	}
+	c.replies.New = func() any { return make(chan channelReply[V], 1) }
+
+	go c.serve()
+	return c
+}
+
+// serve is the owner goroutine. It answers requests one at a time in the
+// order they were queued; callers queue up to channelCacheQueue of them
+// without waiting for the owner.
+func (c *ChannelCache[K, V]) serve() {
+	defer close(c.done)
+
+	for {
+		select {
+		case req := <-c.requests:
+			req.reply <- c.handle(req)
+		case <-c.quit:
+			// Serve whatever was queued before Close
+			for {
+				select {
+				case req := <-c.requests:
+					req.reply <- c.handle(req)
+				default:
+					c.final = c.statisticsLocked()
+					return
+				}
+			}
+		}
+	}
+}
+
+// handle serves one request on the owner goroutine
+func (c *ChannelCache[K, V]) handle(req channelRequest[K, V]) channelReply[V] {
+	switch req.op {
+	case chanGet:
+		value, found := c.getLocked(req.key)
+		return channelReply[V]{value: value, found: found}
+	case chanPut:
+		return channelReply[V]{found: c.putLocked(req.key, req.value)}
+	case chanDelete:
+		return channelReply[V]{found: c.deleteLocked(req.key)}
+	case chanStats:
+		return channelReply[V]{stats: c.statisticsLocked()}
+	case chanValidate:
+		return channelReply[V]{err: validateLRU(c.items, &c.lruList, c.entryLimit)}
+	case chanSetCloner:
+		c.cloner = req.cloner
+		return channelReply[V]{}
+	default:
+		panic("cache: unknown channel cache operation")
+	}
+}
+
+// call sends a request to the owner goroutine and waits for the reply. It
+// reports false if the cache is closed before the request is served.
+func (c *ChannelCache[K, V]) call(req channelRequest[K, V]) (channelReply[V], bool) {
+	reply := c.replies.Get().(chan channelReply[V])
+	req.reply = reply
+
+	select {
+	case c.requests <- req:
+	case <-c.done:
+		c.replies.Put(reply)
+		return channelReply[V]{}, false
+	}
+
+	select {
+	case r := <-reply:
+		c.replies.Put(reply)
+		return r, true
+	case <-c.done:
+		// The owner may have answered just before stopping
+		select {
+		case r := <-reply:
+			c.replies.Put(reply)
+			return r, true
+		default:
+			// Never served; the owner is gone, so nothing will write to reply
+			c.replies.Put(reply)
+			return channelReply[V]{}, false
+		}
+	}
+}
+
+// Put adds the value to the cache, and returns a boolean to indicate whether
+// a value already existed in the cache for that key. It returns false once
+// the cache is closed.
+func (c *ChannelCache[K, V]) Put(key K, value V) bool {
+	r, _ := c.call(channelRequest[K, V]{op: chanPut, key: key, value: value})
+	return r.found
+}
+
+// Get returns a copy of the value associated with key, and whether it was
+// found. It reports a miss once the cache is closed.
+func (c *ChannelCache[K, V]) Get(key K) (*V, bool) {
+	value, found := c.GetValue(key)
+	if !found {
+		return nil, false
+	}
+	return &value, true
+}
+
+// GetValue is Get returning the copy of the value itself
+func (c *ChannelCache[K, V]) GetValue(key K) (V, bool) {
+	r, _ := c.call(channelRequest[K, V]{op: chanGet, key: key})
+	return r.value, r.found
+}
+
+// SetCloner makes the cache deep copy values with clone when mode says so.
+// The clones are made on the owner goroutine.
+func (c *ChannelCache[K, V]) SetCloner(clone Cloner[V], mode CloneMode) {
+	c.call(channelRequest[K, V]{op: chanSetCloner, cloner: cloning[V]{clone: clone, mode: mode}})
+}
+
+// Delete removes the key from the cache, and returns a boolean to indicate
+// whether a value existed for that key
+func (c *ChannelCache[K, V]) Delete(key K) bool {
+	r, _ := c.call(channelRequest[K, V]{op: chanDelete, key: key})
+	return r.found
+}
+
+// GetStatistics returns consistent statistics about the cache. After Close it
+// returns the statistics at the time the cache stopped.
+func (c *ChannelCache[K, V]) GetStatistics() Statistics {
+	r, ok := c.call(channelRequest[K, V]{op: chanStats})
+	if !ok {
+		<-c.done
+		return c.final
+	}
+	return r.stats
+}
+
+// Close serves the requests already queued and stops the owner goroutine.
+// Operations after Close don't change the cache and report misses.
+func (c *ChannelCache[K, V]) Close() error {
+	c.closeOnce.Do(func() { close(c.quit) })
+	<-c.done
+	return nil
+}
+
+// The methods below run only on the owner goroutine. They keep the Locked
+// suffix of their Cache counterparts: owning the state is what the lock
+// gives the other implementations.
+
+// putLocked implements Put
+func (c *ChannelCache[K, V]) putLocked(key K, value V) bool {
+	c.stats.IncrementWrites()
+	value = c.cloner.onPut(value)
+
+	if existingEntry, exists := c.items[key]; exists {
+		existingEntry.value = value
+		existingEntry.readAfterWrite = false
+		c.lruList.moveToFront(existingEntry)
+		return true
+	}
+
+	if len(c.items) >= c.entryLimit && len(c.items) > 0 {
+		lruEntry := c.lruList.removeLast()
+		if !lruEntry.readAfterWrite {
+			c.stats.IncrementNeverRead()
+		}
+		delete(c.items, lruEntry.key)
+		c.stats.IncrementEvictions()
+	}
+
+	newEntry := &entry[K, V]{key: key, value: value}
+	c.items[key] = newEntry
+	c.lruList.addToFront(newEntry)
+	if debugValidate {
+		c.debugCheckLocked()
+	}
+
+	return false
+}
+
+// getLocked implements GetValue
+func (c *ChannelCache[K, V]) getLocked(key K) (V, bool) {
+	c.stats.IncrementReads()
+
+	entry, exists := c.items[key]
+	if !exists {
+		c.stats.IncrementMisses()
+		var zero V
+		return zero, false
+	}
+
+	entry.accessCount++
+	entry.readAfterWrite = true
+	c.lruList.moveToFront(entry)
+	c.stats.IncrementHits()
+
+	return c.cloner.onGet(entry.value), true
+}
+
+// deleteLocked implements Delete
+func (c *ChannelCache[K, V]) deleteLocked(key K) bool {
+	entry, exists := c.items[key]
+	if !exists {
+		return false
+	}
+
+	c.lruList.remove(entry)
+	delete(c.items, key)
+	c.stats.IncrementDeletes()
+	if debugValidate {
+		c.debugCheckLocked()
+	}
+
+	return true
+}
+
+// statisticsLocked implements GetStatistics
+func (c *ChannelCache[K, V]) statisticsLocked() Statistics {
+	stats := *c.stats
+
+	if len(c.items) > 0 {
+		totalAccesses := 0
+		for _, e := range c.items {
+			totalAccesses += e.accessCount
+			if !e.readAfterWrite {
+				stats.CurrentNeverRead++
+			}
+		}
+		stats.AverageAccessCount = float64(totalAccesses) / float64(len(c.items))
+	}
+
+	return stats
+}
+
+// Validate checks the internal invariants of the cache, like Cache.Validate
+func (c *ChannelCache[K, V]) Validate() error {
+	r, ok := c.call(channelRequest[K, V]{op: chanValidate})
+	if !ok {
+		return nil
+	}
+	return r.err
+}
+
+// debugCheckLocked panics if the cache is invalid; it runs on the owner goroutine
+func (c *ChannelCache[K, V]) debugCheckLocked() {
+	if err := validateLRU(c.items, &c.lruList, c.entryLimit); err != nil {
+		panic(err)
+	}
+}
@@ -0,0 +1,129 @@
+package cache
+
+import (
+	"fmt"
+	"sync"
+	"testing"
+)
+
+func TestChannelCacheOperations(t *testing.T) {
+	c := NewChannelCache[string, int](3)
+	defer c.Close()
+
+	if c.Put("one", 1) {
+		t.Error("Key shouldn't exist on first Put")
+	}
+	if !c.Put("one", 100) {
+		t.Error("Key should exist on second Put")
+	}
+	if v, found := c.Get("one"); !found || *v != 100 {
+		t.Errorf("Expected value 100, got %v, %v", deref(v), found)
+	}
+
+	// The returned value is a copy owned by the caller
+	v, _ := c.Get("one")
+	*v = 7
+	if v, _ := c.Get("one"); *v != 100 {
+		t.Errorf("Changing a returned value changed the cache to %d", *v)
+	}
+
+	c.Put("two", 2)
+	c.Put("three", 3)
+	c.Get("one")
+	c.Put("four", 4) // Should evict "two"
+
+	if _, found := c.Get("two"); found {
+		t.Error("Key 'two' should have been evicted")
+	}
+	if !c.Delete("one") || c.Delete("one") {
+		t.Error("Expected the first Delete of 'one' to succeed and the second to fail")
+	}
+
+	stats := c.GetStatistics()
+	if stats.Reads != 5 || stats.Hits != 4 || stats.Misses != 1 {
+		t.Errorf("Expected 5 reads, 4 hits and 1 miss, got %+v", stats)
+	}
+	if stats.Evictions != 1 || stats.NeverReadCount != 1 || stats.Deletes != 1 {
+		t.Errorf("Expected 1 eviction of a never read entry and 1 delete, got %+v", stats)
+	}
+	if err := c.Validate(); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestChannelCacheConcurrent(t *testing.T) {
+	c := NewChannelCache[string, int](50)
+	defer c.Close()
+
+	var wg sync.WaitGroup
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for j := 0; j < 200; j++ {
+				key := fmt.Sprintf("key%d", (i*7+j)%100)
+				if j%3 == 0 {
+					c.Put(key, j)
+				} else {
+					c.Get(key)
+				}
+			}
+		}()
+	}
+	wg.Wait()
+
+	stats := c.GetStatistics()
+	if stats.Reads+stats.Writes != 2000 {
+		t.Errorf("Expected 2000 operations, got %d reads and %d writes", stats.Reads, stats.Writes)
+	}
+	if stats.Hits+stats.Misses != stats.Reads {
+		t.Errorf("%d hits and %d misses for %d reads", stats.Hits, stats.Misses, stats.Reads)
+	}
+	if err := c.Validate(); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestChannelCacheClose(t *testing.T) {
+	c := NewChannelCache[string, int](3)
+	c.Put("one", 1)
+	c.Get("one")
+
+	// Operations racing with Close either run or report a miss, and never hang
+	var wg sync.WaitGroup
+	for i := 0; i < 8; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for j := 0; j < 100; j++ {
+				c.Put("one", j)
+				c.Get("one")
+			}
+		}()
+	}
+	if err := c.Close(); err != nil {
+		t.Fatal(err)
+	}
+	wg.Wait()
+
+	if err := c.Close(); err != nil {
+		t.Errorf("Second Close returned %v", err)
+	}
+
+	before := c.GetStatistics()
+	if c.Put("two", 2) {
+		t.Error("Put after Close reported an existing key")
+	}
+	if _, found := c.Get("one"); found {
+		t.Error("Get after Close should miss")
+	}
+	if c.Delete("one") {
+		t.Error("Delete after Close should report a missing key")
+	}
+	if after := c.GetStatistics(); after != before {
+		t.Errorf("Statistics changed after Close: %+v, then %+v", before, after)
+	}
+	if before.Reads < 1 || before.Hits < 1 {
+		t.Errorf("Expected the statistics from before Close, got %+v", before)
+	}
+}
@@ -0,0 +1,53 @@
+package cache
+
+// Every cache implementation has value semantics: Get returns a pointer to a
+// copy of the cached value, never to the cache's own storage, so a caller
+// changing what it got back can't race with other readers and writers or
+// corrupt the entry. GetValue returns the copy itself and saves the
+// allocation of the pointer.
+//
+// The copy is shallow. For values that share memory when copied, such as
+// slices, maps or structs holding pointers, install a Cloner with SetCloner
+// to copy them deeply on the way in, on the way out, or both.
+
+// Cloner returns a deep copy of value
+type Cloner[V any] func(value V) V
+
+// CloneMode selects when a cache calls its Cloner
+type CloneMode uint8
+
+const (
+	// CloneOnPut stores a clone, so the caller may keep changing the value it
+	// passed to Put
+	CloneOnPut CloneMode = 1 << iota
+
+	// CloneOnGet returns a clone, so the caller may change the value Get
+	// returned
+	CloneOnGet
+
+	// CloneAlways clones on both Put and Get
+	CloneAlways = CloneOnPut | CloneOnGet
+)
+
+// cloning is the Cloner of a cache and when to call it. The zero value never
+// clones.
+type cloning[V any] struct {
+	clone Cloner[V]
+	mode  CloneMode
+}
+
+// onPut returns the value to store for a value passed to Put
+func (c cloning[V]) onPut(value V) V {
+	if c.clone != nil && c.mode&CloneOnPut != 0 {
+		return c.clone(value)
+	}
+	return value
+}
+
+// onGet returns the value to hand out for a stored value
+func (c cloning[V]) onGet(value V) V {
+	if c.clone != nil && c.mode&CloneOnGet != 0 {
+		return c.clone(value)
+	}
+	return value
+}
@@ -0,0 +1,159 @@
+package cache
+
+import (
+	"context"
+	"slices"
+	"sync"
+	"testing"
+)
+
+func TestCloneModes(t *testing.T) {
+	for _, tc := range []struct {
+		mode           CloneMode
+		putIsolated    bool // changing the slice passed to Put leaves the entry alone
+		getIsolated    bool // changing the slice Get returned leaves the entry alone
+		expectedClones int
+	}{
+		{0, false, false, 0},
+		{CloneOnPut, true, false, 1},
+		{CloneOnGet, false, true, 2},
+		{CloneAlways, true, true, 3},
+	} {
+		for name, c := range isolatedCaches(t) {
+			clones := 0
+			c.SetCloner(func(v []int) []int {
+				clones++
+				return slices.Clone(v)
+			}, tc.mode)
+
+			value := []int{1, 2}
+			c.Put("key", value)
+			value[0] = 100
+
+			got, _ := c.GetValue("key")
+			if isolated := got[0] == 1; isolated != tc.putIsolated {
+				t.Errorf("%s, mode %d: expected Put isolation %v, the entry is %v", name, tc.mode, tc.putIsolated, got)
+			}
+			got[1] = 200
+
+			again, _ := c.Get("key")
+			if isolated := (*again)[1] == 2; isolated != tc.getIsolated {
+				t.Errorf("%s, mode %d: expected Get isolation %v, the entry is %v", name, tc.mode, tc.getIsolated, *again)
+			}
+			if clones != tc.expectedClones {
+				t.Errorf("%s, mode %d: expected %d clones, got %d", name, tc.mode, tc.expectedClones, clones)
+			}
+		}
+	}
+}
+
+func TestGetOrLoadClonesSharedResult(t *testing.T) {
+	c := NewCache[string, []int](10)
+	c.SetCloner(slices.Clone[[]int], CloneOnGet)
+
+	v, err := c.GetOrLoad(context.Background(), "key", func(context.Context) ([]int, error) {
+		return []int{1, 2, 3}, nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	(*v)[0] = 100
+
+	if cached, _ := c.GetValue("key"); cached[0] != 1 {
+		t.Errorf("Changing the loaded value changed the entry to %v", cached)
+	}
+}
+
+// sliceStore is a Store that keeps the very slices it is given
+type sliceStore struct {
+	mu     sync.Mutex
+	values map[string][]int
+}
+
+func (s *sliceStore) Load(key string) ([]int, bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	value, found := s.values[key]
+	return value, found, nil
+}
+
+func (s *sliceStore) Store(key string, value []int) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.values[key] = value
+	return nil
+}
+
+func (s *sliceStore) Delete(key string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	delete(s.values, key)
+	return nil
+}
+
+// checkIsolated changes the value passed to put and the value get returned,
+// and expects neither change to reach the entry
+func checkIsolated(t *testing.T, name string, put func(value []int), get func() []int) {
+	t.Helper()
+
+	value := []int{1, 2}
+	put(value)
+	value[0] = 100
+	get()[1] = 200
+
+	if got := get(); !slices.Equal(got, []int{1, 2}) {
+		t.Errorf("%s: expected the entry to stay [1 2], got %v", name, got)
+	}
+}
+
+func TestWrapperClones(t *testing.T) {
+	durable, err := OpenDurableCache[string, []int](10, t.TempDir(), WALOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer durable.Close()
+	durable.SetCloner(slices.Clone[[]int], CloneAlways)
+	checkIsolated(t, "durable",
+		func(value []int) { durable.Put("key", value) },
+		func() []int { v, _ := durable.Get("key"); return *v })
+
+	store := &sliceStore{values: make(map[string][]int)}
+	behind := NewStoreCache[string, []int](10, store, StoreOptions{Mode: WriteBehind})
+	defer behind.Close()
+	behind.SetCloner(slices.Clone[[]int], CloneAlways)
+	checkIsolated(t, "write-behind",
+		func(value []int) { behind.Put("key", value) },
+		func() []int { v, _, _ := behind.Get("key"); return *v })
+	behind.Flush()
+	if stored, _, _ := store.Load("key"); !slices.Equal(stored, []int{1, 2}) {
+		t.Errorf("Expected [1 2] to be written to the store, got %v", stored)
+	}
+
+	// A value loaded from the store is cloned on the way out too
+	store.Store("loaded", []int{1, 2})
+	loading := NewStoreCache[string, []int](10, store, StoreOptions{})
+	defer loading.Close()
+	loading.SetCloner(slices.Clone[[]int], CloneOnGet)
+	v, _, _ := loading.Get("loaded")
+	(*v)[0] = 100
+	if stored, _, _ := store.Load("loaded"); stored[0] != 1 {
+		t.Errorf("Changing a loaded value changed the store's copy to %v", stored)
+	}
+
+	// With one entry in L1, every other Get is a promotion from L2
+	tiered, err := NewTieredCache[string, []int](NewCache[string, []int](1), t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer tiered.Close()
+	tiered.SetCloner(slices.Clone[[]int], CloneAlways)
+	checkIsolated(t, "tiered",
+		func(value []int) { tiered.Put("key", value) },
+		func() []int { tiered.Put("other", nil); v, _ := tiered.Get("key"); return *v })
+	if stats := tiered.GetStatistics(); stats.Promotions != 2 {
+		t.Errorf("Expected both Gets to promote, got %d promotions", stats.Promotions)
+	}
+}
@@ -0,0 +1,205 @@
+package cache
+
+import (
+	"context"
+	"fmt"
+	"sync"
+)
+
+// lockCtx acquires mu, giving up with ctx.Err() if ctx is done first. The
+// uncontended case costs a single TryLock; only a contended lock pays for
+// the helper goroutine that waits on the mutex.
+func lockCtx(ctx context.Context, mu *sync.Mutex) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	if mu.TryLock() {
+		return nil
+	}
+
+	acquired := make(chan struct{})
+	go func() {
+		mu.Lock()
+		close(acquired)
+	}()
+
+	select {
+	case <-acquired:
+		return nil
+	case <-ctx.Done():
+		// The helper still gets the lock eventually; release it on our behalf
+		go func() {
+			<-acquired
+			mu.Unlock()
+		}()
+		return ctx.Err()
+	}
+}
+
+// loadCall is a GetOrLoad loader shared by every caller asking for the same key
+type loadCall[V any] struct {
+	done  chan struct{} // closed when the loader returns
+	value V
+	err   error
+	stale bool // the key was written or removed while loading, guarded by the cache's mu
+}
+
+// GetCtx is Get that gives up with ctx.Err() if ctx is done before the cache
+// lock is acquired
+func (c *Cache[K, V]) GetCtx(ctx context.Context, key K) (*V, bool, error) {
+	c.yield(yieldBeforeLock)
+	if err := c.contention.lockCtx(ctx, &c.mu); err != nil {
+		return nil, false, err
+	}
+	defer c.mu.Unlock()
+
+	value, found := c.getLocked(key)
+	if !found {
+		return nil, false, nil
+	}
+	return &value, true, nil
+}
+
+// PutCtx is Put that gives up with ctx.Err() if ctx is done before the cache
+// lock is acquired, in which case the value is not stored
+func (c *Cache[K, V]) PutCtx(ctx context.Context, key K, value V) (bool, error) {
+	c.yield(yieldBeforeLock)
+	if err := c.contention.lockCtx(ctx, &c.mu); err != nil {
+		return false, err
+	}
+	defer c.mu.Unlock()
+
+	return c.putLocked(key, value), nil
+}
+
+// GetOrLoad returns the cached value for key, calling loader to produce and
+// cache it on a miss. Concurrent callers missing on the same key share a single
+// loader call. The loader runs with a context that is not cancelled when the
+// caller gives up, so other callers can still use its result; each caller stops
+// waiting with ctx.Err() once its own ctx is done. Loader errors are returned
+// to every waiting caller and are not cached.
+func (c *Cache[K, V]) GetOrLoad(ctx context.Context, key K, loader func(ctx context.Context) (V, error)) (*V, error) {
+	c.yield(yieldBeforeLock)
+	if err := c.contention.lockCtx(ctx, &c.mu); err != nil {
+		return nil, err
+	}
+	return c.getOrLoadLocked(ctx, key, loader)
+}
+
+// getOrLoadLocked implements GetOrLoad. The caller must hold c.mu, which is
+// released before waiting for the loader.
+func (c *Cache[K, V]) getOrLoadLocked(ctx context.Context, key K, loader func(ctx context.Context) (V, error)) (*V, error) {
+	if value, found := c.getLocked(key); found {
+		c.mu.Unlock()
+		return &value, nil
+	}
+	cloner := c.cloner
+
+	call, loading := c.loads[key]
+	if !loading {
+		call = &loadCall[V]{done: make(chan struct{})}
+		c.loads[key] = call
+		go c.load(context.WithoutCancel(ctx), key, call, loader)
+	}
+	c.mu.Unlock()
+
+	select {
+	case <-call.done:
+		if call.err != nil {
+			return nil, call.err
+		}
+		// Every waiting caller gets its own copy of the shared result
+		value := cloner.onGet(call.value)
+		return &value, nil
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+}
+
+// load runs a loader and caches its result. A panicking loader fails the
+// call like an error does, so the callers waiting on it aren't stranded.
+func (c *Cache[K, V]) load(ctx context.Context, key K, call *loadCall[V], loader func(ctx context.Context) (V, error)) {
+	defer c.finishLoad(key, call)
+	defer func() {
+		if r := recover(); r != nil {
+			call.err = fmt.Errorf("cache: loader for key %v panicked: %v", key, r)
+		}
+	}()
+
+	call.value, call.err = loader(ctx)
+}
+
+// finishLoad caches a load's result and wakes its callers. A Put, Delete or
+// invalidation of the key while the loader ran is newer than the loaded
+// value, so the value is only handed to the waiting callers then; the same
+// goes for a shard retired by a reshard, which no longer serves the key.
+func (c *Cache[K, V]) finishLoad(key K, call *loadCall[V]) {
+	c.mu.Lock()
+	delete(c.loads, key)
+	if _, exists := c.items[key]; call.err == nil && !call.stale && !exists && !c.retired {
+		c.putLocked(key, call.value)
+	}
+	c.mu.Unlock()
+
+	close(call.done)
+}
+
+// supersedeLoadLocked marks an in-flight load of key as stale; the caller
+// must hold c.mu
+func (c *Cache[K, V]) supersedeLoadLocked(key K) {
+	if len(c.loads) == 0 {
+		return
+	}
+	if call, loading := c.loads[key]; loading {
+		call.stale = true
+	}
+}
+
+// GetCtx is Get that gives up with ctx.Err() if ctx is done before the
+// shard's lock is acquired
+func (c *ShardedCache[K, V]) GetCtx(ctx context.Context, key K) (*V, bool, error) {
+	old, shard, err := c.lockKey(key, true, lockShardCtx[K, V](ctx))
+	if err != nil {
+		return nil, false, err
+	}
+	defer unlockKey(old, shard)
+
+	value, found := shard.getLocked(key)
+	if !found {
+		return nil, false, nil
+	}
+	return &value, true, nil
+}
+
+// PutCtx is Put that gives up with ctx.Err() if ctx is done before the
+// shard's lock is acquired
+func (c *ShardedCache[K, V]) PutCtx(ctx context.Context, key K, value V) (bool, error) {
+	old, shard, err := c.lockKey(key, true, lockShardCtx[K, V](ctx))
+	if err != nil {
+		return false, err
+	}
+	defer unlockKey(old, shard)
+
+	return shard.putLocked(key, value), nil
+}
+
+// GetOrLoad returns the cached value for key, loading it on a miss with a
+// single loader call per key; see Cache.GetOrLoad
+func (c *ShardedCache[K, V]) GetOrLoad(ctx context.Context, key K, loader func(ctx context.Context) (V, error)) (*V, error) {
+	old, shard, err := c.lockKey(key, true, lockShardCtx[K, V](ctx))
+	if err != nil {
+		return nil, err
+	}
+	if old != nil {
+		old.mu.Unlock()
+	}
+	return shard.getOrLoadLocked(ctx, key, loader)
+}
+
+// lockShardCtx returns a lockKey lock that gives up once ctx is done
+func lockShardCtx[K comparable, V any](ctx context.Context) func(shard *Cache[K, V]) error {
+	return func(shard *Cache[K, V]) error {
+		shard.yield(yieldBeforeLock)
+		return shard.contention.lockCtx(ctx, &shard.mu)
+	}
+}
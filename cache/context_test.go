@@ -0,0 +1,222 @@
+package cache
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestContextAbandonsContendedLock(t *testing.T) {
+	cache := NewCache[string, int](10)
+	cache.Put("one", 1)
+
+	// Simulate a long operation holding the cache lock
+	cache.mu.Lock()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+
+	start := time.Now()
+	_, _, err := cache.GetCtx(ctx, "one")
+	if !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("Expected DeadlineExceeded, got %v", err)
+	}
+	if elapsed := time.Since(start); elapsed > time.Second {
+		t.Errorf("GetCtx should give up at the deadline, took %v", elapsed)
+	}
+
+	ctx, cancel = context.WithCancel(context.Background())
+	cancel()
+	if _, err := cache.PutCtx(ctx, "two", 2); !errors.Is(err, context.Canceled) {
+		t.Errorf("Expected Canceled, got %v", err)
+	}
+
+	cache.mu.Unlock()
+
+	// The abandoned lock attempts hand the lock back, so the cache still works
+	val, found, err := cache.GetCtx(context.Background(), "one")
+	if err != nil || !found || *val != 1 {
+		t.Errorf("Expected 'one'=1 once the lock is free, got %v %v %v", val, found, err)
+	}
+	if _, found := cache.Get("two"); found {
+		t.Error("A cancelled PutCtx should not store its value")
+	}
+}
+
+func TestGetOrLoadSharesLoader(t *testing.T) {
+	cache := NewShardedCache[string, int](10, 2)
+
+	var calls atomic.Int32
+	release := make(chan struct{})
+	loader := func(ctx context.Context) (int, error) {
+		calls.Add(1)
+		<-release
+		return 42, nil
+	}
+
+	var wg sync.WaitGroup
+	numWorkers := 10
+	for i := 0; i < numWorkers; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+
+			val, err := cache.GetOrLoad(context.Background(), "answer", loader)
+			if err != nil || *val != 42 {
+				t.Errorf("Expected 42, got %v %v", val, err)
+			}
+		}()
+	}
+
+	time.Sleep(10 * time.Millisecond) // let every worker join the in-flight load
+	close(release)
+	wg.Wait()
+
+	if calls.Load() != 1 {
+		t.Errorf("Expected 1 loader call, got %d", calls.Load())
+	}
+
+	// The loaded value is now cached
+	val, found := cache.Get("answer")
+	if !found || *val != 42 {
+		t.Errorf("Expected the loaded value to be cached, got %v %v", val, found)
+	}
+}
+
+func TestGetOrLoadAbandonsSlowLoader(t *testing.T) {
+	cache := NewCache[string, int](10)
+
+	release := make(chan struct{})
+	loader := func(ctx context.Context) (int, error) {
+		<-release
+		return 7, ctx.Err()
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	if _, err := cache.GetOrLoad(ctx, "slow", loader); !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("Expected DeadlineExceeded while the loader runs, got %v", err)
+	}
+
+	// A patient caller joins the same load and gets its result
+	done := make(chan *int)
+	go func() {
+		val, _ := cache.GetOrLoad(context.Background(), "slow", loader)
+		done <- val
+	}()
+	close(release)
+
+	if val := <-done; val == nil || *val != 7 {
+		t.Errorf("Expected 7 from the shared load, got %v", val)
+	}
+}
+
+func TestGetOrLoadDoesNotCacheErrors(t *testing.T) {
+	cache := NewCache[string, int](10)
+
+	errBackend := errors.New("backend unavailable")
+	_, err := cache.GetOrLoad(context.Background(), "key", func(ctx context.Context) (int, error) {
+		return 0, errBackend
+	})
+	if !errors.Is(err, errBackend) {
+		t.Errorf("Expected the loader error, got %v", err)
+	}
+
+	val, err := cache.GetOrLoad(context.Background(), "key", func(ctx context.Context) (int, error) {
+		return 1, nil
+	})
+	if err != nil || *val != 1 {
+		t.Errorf("Expected a retried load to succeed, got %v %v", val, err)
+	}
+}
+
+func TestGetOrLoadDropsSupersededResult(t *testing.T) {
+	writes := map[string]func(c *Cache[string, int]){
+		"put": func(c *Cache[string, int]) {
+			c.Put("key", 2)
+			c.Delete("key")
+		},
+		"delete": func(c *Cache[string, int]) {
+			c.Delete("key")
+		},
+		"invalidate": func(c *Cache[string, int]) {
+			c.PutWithTags("key", 2, "tag")
+			c.InvalidateTag("tag")
+		},
+	}
+
+	for name, write := range writes {
+		cache := NewCache[string, int](10)
+		started := make(chan struct{})
+		release := make(chan struct{})
+		loader := func(ctx context.Context) (int, error) {
+			close(started)
+			<-release
+			return 1, nil
+		}
+
+		done := make(chan *int)
+		go func() {
+			val, _ := cache.GetOrLoad(context.Background(), "key", loader)
+			done <- val
+		}()
+
+		// Write the key while the loader is blocked
+		<-started
+		write(cache)
+		close(release)
+
+		if val := <-done; val == nil || *val != 1 {
+			t.Errorf("%s: expected the waiting caller to get the loaded 1, got %v", name, val)
+		}
+		if val, found := cache.Get("key"); found {
+			t.Errorf("%s: expected the stale loaded value not to be cached, got %d", name, *val)
+		}
+	}
+
+	// A Put during the load wins over the loaded value
+	cache := NewCache[string, int](10)
+	started := make(chan struct{})
+	release := make(chan struct{})
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		cache.GetOrLoad(context.Background(), "key", func(ctx context.Context) (int, error) {
+			close(started)
+			<-release
+			return 1, nil
+		})
+	}()
+	<-started
+	cache.Put("key", 2)
+	close(release)
+	<-done
+
+	if val, found := cache.Get("key"); !found || *val != 2 {
+		t.Errorf("Expected the Put during the load to stay cached, got %v %v", val, found)
+	}
+}
+
+func TestGetOrLoadRecoversLoaderPanic(t *testing.T) {
+	cache := NewCache[string, int](10)
+
+	_, err := cache.GetOrLoad(context.Background(), "key", func(ctx context.Context) (int, error) {
+		panic("backend exploded")
+	})
+	if err == nil {
+		t.Fatal("Expected a panicking loader to fail the call")
+	}
+
+	// The failed load no longer blocks the key
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	val, err := cache.GetOrLoad(ctx, "key", func(ctx context.Context) (int, error) {
+		return 1, nil
+	})
+	if err != nil || *val != 1 {
+		t.Errorf("Expected a later load to succeed, got %v %v", val, err)
+	}
+}
@@ -0,0 +1,7 @@
+//go:build !cachedebug
+
+package cache
+
+// debugValidate makes every mutation validate the cache invariants. Build with
+// -tags cachedebug to turn it on.
+const debugValidate = false
@@ -0,0 +1,6 @@
+//go:build cachedebug
+
+package cache
+
+// debugValidate makes every mutation validate the cache invariants
+const debugValidate = true
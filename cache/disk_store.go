@@ -0,0 +1,419 @@
+package cache
+
+import (
+	"bufio"
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+)
+
+const (
+	// defaultSegmentSize is the size at which the active segment file is rolled over
+	defaultSegmentSize = 64 << 20
+
+	// minCompactBytes keeps tiny stores from being compacted over and over
+	minCompactBytes = 1 << 20
+
+	segmentExt = ".seg"
+)
+
+// diskLocation points at a record inside one of the segment files
+type diskLocation struct {
+	segment int   // segment id
+	offset  int64 // offset of the record header
+	size    int64 // header + payload size
+}
+
+// diskStore is an append-only, file-backed key/value store. Every write
+// appends a record to the active segment file and the in-memory index points
+// at the latest record for each key. Overwritten and deleted records become
+// garbage that compaction reclaims by rewriting the live records into fresh
+// segments.
+type diskStore[K comparable, V any] struct {
+	mu             sync.Mutex
+	dir            string
+	segments       map[int]*os.File // all open segments by id
+	activeID       int
+	activeSize     int64
+	index          map[K]diskLocation
+	liveBytes      int64 // bytes used by records the index points at
+	totalBytes     int64 // bytes used by all segment files
+	maxSegmentSize int64
+	stats          *Statistics
+	compactErrors  int64 // automatic compactions that failed
+}
+
+// openDiskStore opens the store in dir, creating the directory if needed and
+// rebuilding the index from any segments left behind by a previous run.
+func openDiskStore[K comparable, V any](dir string) (*diskStore[K, V], error) {
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return nil, fmt.Errorf("cache: creating store directory: %w", err)
+	}
+
+	s := &diskStore[K, V]{
+		dir:            dir,
+		segments:       make(map[int]*os.File),
+		index:          make(map[K]diskLocation),
+		maxSegmentSize: defaultSegmentSize,
+		stats:          newStatistics(),
+	}
+
+	ids, err := s.segmentIDs()
+	if err != nil {
+		return nil, err
+	}
+	for _, id := range ids {
+		if err := s.loadSegment(id); err != nil {
+			s.close()
+			return nil, err
+		}
+	}
+
+	// Always start appending to a fresh segment
+	next := 0
+	if len(ids) > 0 {
+		next = ids[len(ids)-1] + 1
+	}
+	if err := s.openSegment(next); err != nil {
+		s.close()
+		return nil, err
+	}
+
+	return s, nil
+}
+
+// segmentIDs returns the ids of the segment files in the store directory, in order
+func (s *diskStore[K, V]) segmentIDs() ([]int, error) {
+	names, err := filepath.Glob(filepath.Join(s.dir, "*"+segmentExt))
+	if err != nil {
+		return nil, err
+	}
+
+	ids := make([]int, 0, len(names))
+	for _, name := range names {
+		var id int
+		if _, err := fmt.Sscanf(filepath.Base(name), "%06d"+segmentExt, &id); err == nil {
+			ids = append(ids, id)
+		}
+	}
+	sort.Ints(ids)
+	return ids, nil
+}
+
+func (s *diskStore[K, V]) segmentPath(id int) string {
+	return filepath.Join(s.dir, fmt.Sprintf("%06d%s", id, segmentExt))
+}
+
+// loadSegment replays a segment into the index. A damaged tail, for example
+// from a crash in the middle of a write, is truncated away.
+func (s *diskStore[K, V]) loadSegment(id int) error {
+	f, err := os.OpenFile(s.segmentPath(id), os.O_RDWR, 0o644)
+	if err != nil {
+		return fmt.Errorf("cache: opening segment: %w", err)
+	}
+	s.segments[id] = f
+
+	r := bufio.NewReader(f)
+	var offset int64
+	for {
+		rec, n, err := readRecord[K, V](r)
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			// Drop everything from the first bad record onwards
+			if err := f.Truncate(offset); err != nil {
+				return fmt.Errorf("cache: truncating damaged segment: %w", err)
+			}
+			break
+		}
+
+		if old, exists := s.index[rec.Key]; exists {
+			s.liveBytes -= old.size
+		}
+		if rec.Deleted {
+			delete(s.index, rec.Key)
+		} else {
+			s.index[rec.Key] = diskLocation{segment: id, offset: offset, size: n}
+			s.liveBytes += n
+		}
+		offset += n
+	}
+
+	s.totalBytes += offset
+	return nil
+}
+
+// openSegment creates a new empty segment and makes it the active one
+func (s *diskStore[K, V]) openSegment(id int) error {
+	f, err := os.OpenFile(s.segmentPath(id), os.O_RDWR|os.O_CREATE|os.O_TRUNC, 0o644)
+	if err != nil {
+		return fmt.Errorf("cache: creating segment: %w", err)
+	}
+	s.segments[id] = f
+	s.activeID = id
+	s.activeSize = 0
+	return nil
+}
+
+// appendLocked writes a framed record to the active segment, rolling over to
+// a new segment when the active one is full
+func (s *diskStore[K, V]) appendLocked(data []byte) (diskLocation, error) {
+	if s.activeSize > 0 && s.activeSize+int64(len(data)) > s.maxSegmentSize {
+		if err := s.openSegment(s.activeID + 1); err != nil {
+			return diskLocation{}, err
+		}
+	}
+
+	loc := diskLocation{segment: s.activeID, offset: s.activeSize, size: int64(len(data))}
+	if _, err := s.segments[s.activeID].WriteAt(data, loc.offset); err != nil {
+		return diskLocation{}, fmt.Errorf("cache: writing segment: %w", err)
+	}
+
+	s.activeSize += loc.size
+	s.totalBytes += loc.size
+	return loc, nil
+}
+
+// put stores the value for key, replacing any previous value
+func (s *diskStore[K, V]) put(key K, value V) error {
+	data, err := encodeRecord(diskRecord[K, V]{Key: key, Value: value})
+	if err != nil {
+		return err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.stats.IncrementWrites()
+
+	loc, err := s.appendLocked(data)
+	if err != nil {
+		return err
+	}
+	if old, exists := s.index[key]; exists {
+		s.liveBytes -= old.size
+	}
+	s.index[key] = loc
+	s.liveBytes += loc.size
+
+	s.maybeCompactLocked()
+	return nil
+}
+
+// take reads the value for key and removes it from the store, so a value
+// lives in exactly one tier at a time
+func (s *diskStore[K, V]) take(key K) (V, bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var zero V
+	s.stats.IncrementReads()
+
+	loc, exists := s.index[key]
+	if !exists {
+		s.stats.IncrementMisses()
+		return zero, false, nil
+	}
+
+	rec, err := s.readLocked(loc)
+	if err != nil {
+		s.stats.IncrementMisses()
+		return zero, false, err
+	}
+
+	if err := s.deleteLocked(key); err != nil {
+		return zero, false, err
+	}
+
+	s.stats.IncrementHits()
+	return rec.Value, true, nil
+}
+
+// delete removes key from the store and reports whether it was present
+func (s *diskStore[K, V]) delete(key K) (bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, exists := s.index[key]; !exists {
+		return false, nil
+	}
+	return true, s.deleteLocked(key)
+}
+
+// deleteLocked writes a tombstone for key so a reopened store does not
+// resurrect it, then drops it from the index
+func (s *diskStore[K, V]) deleteLocked(key K) error {
+	data, err := encodeRecord(diskRecord[K, V]{Key: key, Deleted: true})
+	if err != nil {
+		return err
+	}
+	if _, err := s.appendLocked(data); err != nil {
+		return err
+	}
+
+	s.liveBytes -= s.index[key].size
+	delete(s.index, key)
+
+	s.maybeCompactLocked()
+	return nil
+}
+
+// readLocked reads and verifies the record at loc
+func (s *diskStore[K, V]) readLocked(loc diskLocation) (diskRecord[K, V], error) {
+	buf := make([]byte, loc.size)
+	if _, err := s.segments[loc.segment].ReadAt(buf, loc.offset); err != nil {
+		return diskRecord[K, V]{}, fmt.Errorf("cache: reading segment: %w", err)
+	}
+
+	rec, _, err := readRecord[K, V](bytes.NewReader(buf))
+	return rec, err
+}
+
+// maybeCompactLocked compacts once at least half of the bytes on disk are
+// garbage. The write that got here has already succeeded and a failed
+// compaction leaves the store as it was, so a failure is only counted.
+func (s *diskStore[K, V]) maybeCompactLocked() {
+	if s.totalBytes < minCompactBytes || s.liveBytes*2 > s.totalBytes {
+		return
+	}
+	if err := s.compactLocked(); err != nil {
+		s.compactErrors++
+	}
+}
+
+// compact rewrites the live records into fresh segments and deletes the old ones
+func (s *diskStore[K, V]) compact() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return s.compactLocked()
+}
+
+func (s *diskStore[K, V]) compactLocked() error {
+	old := s.segments
+	activeID, activeSize := s.activeID, s.activeSize
+	totalBytes, liveBytes := s.totalBytes, s.liveBytes
+
+	index, err := s.rewriteLocked(old)
+	if err != nil {
+		// Drop the partial copy and keep using the old segments
+		for id, f := range s.segments {
+			f.Close()
+			os.Remove(s.segmentPath(id))
+		}
+		s.segments = old
+		s.activeID, s.activeSize = activeID, activeSize
+		s.totalBytes, s.liveBytes = totalBytes, liveBytes
+		return err
+	}
+	s.index = index
+
+	// Old segments are removed oldest first, stopping at the first failure:
+	// if a crash or an error stops this part way, the ones left are the
+	// newest, so no record in them is older than a tombstone that's gone
+	ids := make([]int, 0, len(old))
+	var errs []error
+	for id, f := range old {
+		ids = append(ids, id)
+		errs = append(errs, f.Close())
+	}
+	sort.Ints(ids)
+	for _, id := range ids {
+		if err := os.Remove(s.segmentPath(id)); err != nil {
+			errs = append(errs, err)
+			break
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// rewriteLocked copies the live records from the old segments into fresh ones
+// and returns an index of the copies. s.index is left alone, so on failure it
+// still points into the old segments.
+func (s *diskStore[K, V]) rewriteLocked(old map[int]*os.File) (map[K]diskLocation, error) {
+	s.segments = make(map[int]*os.File, 1)
+	s.totalBytes = 0
+	s.liveBytes = 0
+
+	// Segments written from here on get ids above every old one
+	if err := s.openSegment(s.activeID + 1); err != nil {
+		return nil, err
+	}
+
+	index := make(map[K]diskLocation, len(s.index))
+	for key, loc := range s.index {
+		buf := make([]byte, loc.size)
+		if _, err := old[loc.segment].ReadAt(buf, loc.offset); err != nil {
+			return nil, fmt.Errorf("cache: compacting segment: %w", err)
+		}
+
+		newLoc, err := s.appendLocked(buf)
+		if err != nil {
+			return nil, err
+		}
+		index[key] = newLoc
+		s.liveBytes += newLoc.size
+	}
+
+	// The copies must be on disk before the originals go
+	for _, f := range s.segments {
+		if err := f.Sync(); err != nil {
+			return nil, fmt.Errorf("cache: syncing segment: %w", err)
+		}
+	}
+	if err := syncDir(s.dir); err != nil {
+		return nil, err
+	}
+	return index, nil
+}
+
+// len returns the number of keys in the store
+func (s *diskStore[K, V]) len() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return len(s.index)
+}
+
+// compactionErrors returns how many automatic compactions failed
+func (s *diskStore[K, V]) compactionErrors() int64 {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return s.compactErrors
+}
+
+// size returns the number of bytes used by the segment files
+func (s *diskStore[K, V]) size() int64 {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return s.totalBytes
+}
+
+// statistics returns a snapshot of the store's counters
+func (s *diskStore[K, V]) statistics() Statistics {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return *s.stats
+}
+
+// close closes every segment file
+func (s *diskStore[K, V]) close() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var errs []error
+	for _, f := range s.segments {
+		errs = append(errs, f.Close())
+	}
+	s.segments = make(map[int]*os.File)
+	return errors.Join(errs...)
+}
@@ -0,0 +1,94 @@
+package cache
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestDiskStoreCompactionFailure(t *testing.T) {
+	dir := t.TempDir()
+	s, err := openDiskStore[int, int](dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer s.close()
+	s.maxSegmentSize = 1024 // Force several segments
+
+	for i := 0; i < 200; i++ {
+		if err := s.put(i%20, i); err != nil {
+			t.Fatal(err)
+		}
+	}
+	totalBytes, liveBytes := s.totalBytes, s.liveBytes
+	files, _ := filepath.Glob(filepath.Join(dir, "*"+segmentExt))
+
+	// Fail reads from the segment holding key 0, as a broken disk would
+	broken := s.index[0].segment
+	s.segments[broken].Close()
+	if err := s.compact(); err == nil {
+		t.Fatal("Expected compaction to fail")
+	}
+
+	if s.totalBytes != totalBytes || s.liveBytes != liveBytes {
+		t.Errorf("Expected %d total and %d live bytes to be restored, got %d and %d", totalBytes, liveBytes, s.totalBytes, s.liveBytes)
+	}
+	for key, loc := range s.index {
+		if _, open := s.segments[loc.segment]; !open {
+			t.Errorf("Key %d points at segment %d, which is no longer open", key, loc.segment)
+		}
+	}
+	if after, _ := filepath.Glob(filepath.Join(dir, "*"+segmentExt)); len(after) != len(files) {
+		t.Errorf("Expected the partial copy to be removed, had %d segments and now %d", len(files), len(after))
+	}
+
+	// Once the disk recovers, nothing has been lost
+	f, err := os.OpenFile(s.segmentPath(broken), os.O_RDWR, 0o644)
+	if err != nil {
+		t.Fatal(err)
+	}
+	s.segments[broken] = f
+	if err := s.compact(); err != nil {
+		t.Fatal(err)
+	}
+	for key := 0; key < 20; key++ {
+		value, found, err := s.take(key)
+		if err != nil || !found || value != 180+key {
+			t.Errorf("Expected key %d=%d after compaction, got %v %v %v", key, 180+key, value, found, err)
+		}
+	}
+}
+
+func TestDiskStoreWriteSurvivesFailedCompaction(t *testing.T) {
+	s, err := openDiskStore[int, string](t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer s.close()
+	s.maxSegmentSize = 64 << 10
+
+	value := strings.Repeat("x", 1024)
+	write := func(i int) {
+		if err := s.put(i%10, fmt.Sprint(i, value)); err != nil {
+			t.Fatalf("Put %d: expected the write to succeed, got %v", i, err)
+		}
+	}
+	if err := s.put(100, value); err != nil {
+		t.Fatal(err)
+	}
+	for i := 0; i < 900; i++ {
+		write(i)
+	}
+
+	// Overwrites pass the automatic compaction threshold while the first
+	// segment, which holds key 100, can't be read
+	s.segments[s.index[100].segment].Close()
+	for i := 900; s.compactErrors == 0; i++ {
+		if i == 2000 {
+			t.Fatal("Expected an automatic compaction to be attempted")
+		}
+		write(i)
+	}
+}
@@ -0,0 +1,90 @@
+package cache
+
+import (
+	"concurrency/workload"
+	"testing"
+)
+
+// policyCache is what the hit ratio tests need from an eviction policy
+type policyCache interface {
+	Get(key int) (*int, bool)
+	Put(key int, value int) bool
+	GetStatistics() Statistics
+}
+
+// policies lists the eviction policies compared on hit ratio
+var policies = []struct {
+	name string
+	new  func(capacity int) policyCache
+}{
+	{"lru", func(capacity int) policyCache { return NewCache[int, int](capacity) }},
+	{"slru", func(capacity int) policyCache { return NewSLRUCache[int, int](capacity, 0.8) }},
+	{"2q", func(capacity int) policyCache { return NewTwoQueueCache[int, int](capacity) }},
+	{"sieve", func(capacity int) policyCache { return NewSIEVECache[int, int](capacity) }},
+	{"s3fifo", func(capacity int) policyCache { return NewS3FIFOCache[int, int](capacity) }},
+}
+
+// scanTrace returns n keys where every other one comes from a Zipfian hot
+// set of hotKeys and the rest are one-hit wonders never seen again
+func scanTrace(n int, hotKeys uint64, seed uint64) []int {
+	hot := must(workload.NewZipfian(hotKeys, 0.9, seed))
+	keys := make([]int, n)
+	for i := range keys {
+		if i%2 == 0 {
+			keys[i] = int(hot.Next())
+		} else {
+			keys[i] = int(hotKeys) + i
+		}
+	}
+	return keys
+}
+
+// hitRatio replays keys against c, filling it on every miss as a cache in
+// front of a slower store would, and returns the fraction of reads that hit
+func hitRatio(c policyCache, keys []int) float64 {
+	for i, key := range keys {
+		if _, found := c.Get(key); !found {
+			c.Put(key, i)
+		}
+	}
+	stats := c.GetStatistics()
+	return stats.GetHitRate()
+}
+
+func TestHitRatioWithOneHitWonders(t *testing.T) {
+	keys := scanTrace(200_000, 1000, 1)
+
+	ratios := make(map[string]float64)
+	for _, p := range policies {
+		ratios[p.name] = hitRatio(p.new(200), keys)
+		t.Logf("%-6s hit ratio %.3f", p.name, ratios[p.name])
+	}
+
+	// Half the trace are one-hit wonders, so even a perfect policy hits
+	// less than half the time. LRU lets them push out the hot keys; the
+	// scan resistant policies keep the hot keys apart.
+	for name, ratio := range ratios {
+		if name != "lru" && ratio < ratios["lru"]*1.2 {
+			t.Errorf("Expected %s to beat LRU's hit ratio of %.3f by 20%%, got %.3f", name, ratios["lru"], ratio)
+		}
+	}
+}
+
+func TestHitRatioWithoutScans(t *testing.T) {
+	hot := must(workload.NewZipfian(1000, 0.9, 2))
+	keys := make([]int, 100_000)
+	for i := range keys {
+		keys[i] = int(hot.Next())
+	}
+
+	// Without one-hit wonders to filter out, the other policies should do
+	// about as well as LRU
+	lru := hitRatio(NewCache[int, int](200), keys)
+	for _, p := range policies[1:] {
+		ratio := hitRatio(p.new(200), keys)
+		t.Logf("%-6s hit ratio %.3f, LRU %.3f", p.name, ratio, lru)
+		if ratio < lru*0.9 {
+			t.Errorf("Expected %s to stay within 10%% of LRU's hit ratio of %.3f, got %.3f", p.name, lru, ratio)
+		}
+	}
+}
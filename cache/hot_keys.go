@@ -0,0 +1,267 @@
+package cache
+
+import (
+	"container/heap"
+	"fmt"
+	"math/rand/v2"
+	"sort"
+	"sync"
+	"sync/atomic"
+)
+
+// hotKeyStripes is how many independent summaries a HotKeys records into, so
+// concurrent callers rarely wait for one another
+const hotKeyStripes = 8
+
+// HotKey is a key reported by HotKeys.TopKeys. The key was used between
+// Count-Error and Count times.
+type HotKey[K comparable] struct {
+	Key   K
+	Count int64 // estimated uses, never less than the true count
+	Error int64 // most the estimate can be over by
+}
+
+// HotKeys finds the keys used most often in a stream, in memory bounded by
+// its capacity, with the Space-Saving algorithm: it counts up to capacity
+// keys, and a key it isn't counting takes over the smallest counter, whose
+// count becomes the new key's error.
+//
+// To keep recording cheap under contention, HotKeys keeps several summaries
+// of capacity keys each. A use is recorded in whichever summary is free, and
+// TopKeys merges them. Every estimate is off by at most Total/capacity, and
+// any key used more than Total/capacity times is always counted somewhere.
+type HotKeys[K comparable] struct {
+	stripes [hotKeyStripes]spaceSaving[K]
+	total   atomic.Int64
+}
+
+// NewHotKeys creates a tracker that counts up to capacity keys per summary.
+// It panics if capacity is less than 1.
+func NewHotKeys[K comparable](capacity int) *HotKeys[K] {
+	if capacity < 1 {
+		panic(fmt.Sprintf("cache: hot key capacity %d is less than 1", capacity))
+	}
+
+	h := &HotKeys[K]{}
+	for i := range h.stripes {
+		h.stripes[i] = spaceSaving[K]{capacity: capacity, counters: make(map[K]*keyCounter[K], capacity)}
+	}
+	return h
+}
+
+// Record counts one use of key. It starts at a random summary and takes the
+// first one that isn't locked, and only waits if they all are.
+func (h *HotKeys[K]) Record(key K) {
+	h.total.Add(1)
+
+	start := rand.IntN(hotKeyStripes)
+	for i := 0; i < hotKeyStripes; i++ {
+		s := &h.stripes[(start+i)%hotKeyStripes]
+		if s.mu.TryLock() {
+			s.recordLocked(key)
+			s.mu.Unlock()
+			return
+		}
+	}
+
+	s := &h.stripes[start]
+	s.mu.Lock()
+	s.recordLocked(key)
+	s.mu.Unlock()
+}
+
+// Total returns how many uses have been recorded
+func (h *HotKeys[K]) Total() int64 {
+	return h.total.Load()
+}
+
+// TopKeys returns up to n of the most used keys, most used first, and none
+// when n is zero or less. A summary that doesn't count a key may still have
+// seen it as often as its smallest count, so that is added to both the key's
+// count and its error. Summaries are read one after the other, not atomically.
+func (h *HotKeys[K]) TopKeys(n int) []HotKey[K] {
+	if n <= 0 {
+		return nil
+	}
+
+	var snapshots [hotKeyStripes]map[K]keyCounter[K]
+	var floors [hotKeyStripes]int64
+	for i := range h.stripes {
+		snapshots[i], floors[i] = h.stripes[i].snapshot()
+	}
+
+	merged := make(map[K]HotKey[K])
+	for _, snapshot := range snapshots {
+		for key := range snapshot {
+			if _, done := merged[key]; done {
+				continue
+			}
+
+			estimate := HotKey[K]{Key: key}
+			for i, other := range snapshots {
+				if c, counted := other[key]; counted {
+					estimate.Count += c.count
+					estimate.Error += c.err
+				} else {
+					estimate.Count += floors[i]
+					estimate.Error += floors[i]
+				}
+			}
+			merged[key] = estimate
+		}
+	}
+
+	top := make([]HotKey[K], 0, len(merged))
+	for _, estimate := range merged {
+		top = append(top, estimate)
+	}
+	sort.Slice(top, func(i, j int) bool {
+		if top[i].Count != top[j].Count {
+			return top[i].Count > top[j].Count
+		}
+		return top[i].Error < top[j].Error
+	})
+
+	if n < len(top) {
+		top = top[:n]
+	}
+	return top
+}
+
+// keyCounter is one counter of a Space-Saving summary
+type keyCounter[K comparable] struct {
+	key   K
+	count int64
+	err   int64 // count the key inherited when it took the counter over
+	index int   // position in the summary's heap
+}
+
+// spaceSaving is a Space-Saving summary of up to capacity keys
+type spaceSaving[K comparable] struct {
+	mu       sync.Mutex
+	capacity int
+	counters map[K]*keyCounter[K]
+	heap     counterHeap[K] // the same counters, smallest count first
+}
+
+// recordLocked counts one use of key; the caller must hold s.mu
+func (s *spaceSaving[K]) recordLocked(key K) {
+	if c, counted := s.counters[key]; counted {
+		c.count++
+		heap.Fix(&s.heap, c.index)
+		return
+	}
+
+	if len(s.heap) < s.capacity {
+		c := &keyCounter[K]{key: key, count: 1}
+		s.counters[key] = c
+		heap.Push(&s.heap, c)
+		return
+	}
+
+	// The key may have been used as often as the smallest count before, so
+	// it takes that counter over with the old count as its error
+	c := s.heap[0]
+	delete(s.counters, c.key)
+	c.key, c.err = key, c.count
+	c.count++
+	s.counters[key] = c
+	heap.Fix(&s.heap, 0)
+}
+
+// snapshot copies the counters, along with the most a key that isn't counted
+// can have been used: the smallest count once the summary is full, else 0
+func (s *spaceSaving[K]) snapshot() (map[K]keyCounter[K], int64) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	counters := make(map[K]keyCounter[K], len(s.counters))
+	for key, c := range s.counters {
+		counters[key] = *c
+	}
+
+	var floor int64
+	if len(s.heap) == s.capacity {
+		floor = s.heap[0].count
+	}
+	return counters, floor
+}
+
+// counterHeap orders counters by count for container/heap
+type counterHeap[K comparable] []*keyCounter[K]
+
+func (h counterHeap[K]) Len() int           { return len(h) }
+func (h counterHeap[K]) Less(i, j int) bool { return h[i].count < h[j].count }
+
+func (h counterHeap[K]) Swap(i, j int) {
+	h[i], h[j] = h[j], h[i]
+	h[i].index = i
+	h[j].index = j
+}
+
+func (h *counterHeap[K]) Push(x any) {
+	c := x.(*keyCounter[K])
+	c.index = len(*h)
+	*h = append(*h, c)
+}
+
+func (h *counterHeap[K]) Pop() any {
+	old := *h
+	c := old[len(old)-1]
+	*h = old[:len(old)-1]
+	return c
+}
+
+// TrackedCache is the behaviour HotKeyCache needs from the cache it wraps.
+// Every in-memory cache in this package satisfies it.
+type TrackedCache[K comparable, V any] interface {
+	Put(key K, value V) bool
+	Get(key K) (*V, bool)
+	Delete(key K) bool
+	GetStatistics() Statistics
+}
+
+// HotKeyCache wraps a cache and records every key passed to Get and Put,
+// hits and misses alike, so TopKeys can report which keys dominate traffic.
+// Delete and GetStatistics are passed straight through.
+type HotKeyCache[K comparable, V any] struct {
+	cache TrackedCache[K, V]
+	hot   *HotKeys[K]
+}
+
+// NewHotKeyCache wraps c, tracking keys with a HotKeys of the given capacity
+func NewHotKeyCache[K comparable, V any](c TrackedCache[K, V], capacity int) *HotKeyCache[K, V] {
+	return &HotKeyCache[K, V]{cache: c, hot: NewHotKeys[K](capacity)}
+}
+
+// Put records the key and adds the value to the wrapped cache
+func (c *HotKeyCache[K, V]) Put(key K, value V) bool {
+	c.hot.Record(key)
+	return c.cache.Put(key, value)
+}
+
+// Get records the key and looks it up in the wrapped cache
+func (c *HotKeyCache[K, V]) Get(key K) (*V, bool) {
+	c.hot.Record(key)
+	return c.cache.Get(key)
+}
+
+// Delete removes the key from the wrapped cache without recording it
+func (c *HotKeyCache[K, V]) Delete(key K) bool {
+	return c.cache.Delete(key)
+}
+
+// GetStatistics returns the wrapped cache's statistics
+func (c *HotKeyCache[K, V]) GetStatistics() Statistics {
+	return c.cache.GetStatistics()
+}
+
+// TopKeys returns up to n of the most used keys; see HotKeys.TopKeys
+func (c *HotKeyCache[K, V]) TopKeys(n int) []HotKey[K] {
+	return c.hot.TopKeys(n)
+}
+
+// HotKeys returns the tracker fed by the cache
+func (c *HotKeyCache[K, V]) HotKeys() *HotKeys[K] {
+	return c.hot
+}
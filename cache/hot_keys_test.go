@@ -0,0 +1,147 @@
+package cache
+
+import (
+	"concurrency/workload"
+	"sync"
+	"testing"
+)
+
+// checkHotKeys checks that every estimate brackets the true count and is off
+// by no more than the tracker promises
+func checkHotKeys(t *testing.T, h *HotKeys[int], capacity int, counts map[int]int64) {
+	t.Helper()
+
+	bound := h.Total() / int64(capacity)
+	for _, hk := range h.TopKeys(hotKeyStripes * capacity) {
+		if actual := counts[hk.Key]; actual > hk.Count || actual < hk.Count-hk.Error {
+			t.Errorf("Key %d was used %d times, outside the estimate %d-%d", hk.Key, actual, hk.Count-hk.Error, hk.Count)
+		}
+		if hk.Error > bound {
+			t.Errorf("Key %d has error %d, more than Total/capacity = %d", hk.Key, hk.Error, bound)
+		}
+	}
+}
+
+func TestHotKeysExact(t *testing.T) {
+	// With fewer keys than counters, every count is exact
+	h := NewHotKeys[int](10)
+	for key := 0; key < 5; key++ {
+		for i := 0; i <= key; i++ {
+			h.Record(key)
+		}
+	}
+
+	top := h.TopKeys(3)
+	if len(top) != 3 {
+		t.Fatalf("Expected 3 keys, got %+v", top)
+	}
+	for i, hk := range top {
+		if want := int64(5 - i); hk.Key != 4-i || hk.Count != want || hk.Error != 0 {
+			t.Errorf("Expected key %d used exactly %d times in place %d, got %+v", 4-i, want, i, hk)
+		}
+	}
+	if h.Total() != 15 {
+		t.Errorf("Expected 15 uses recorded, got %d", h.Total())
+	}
+	for _, n := range []int{0, -1} {
+		if top := h.TopKeys(n); len(top) != 0 {
+			t.Errorf("Expected no keys for n=%d, got %+v", n, top)
+		}
+	}
+}
+
+func TestHotKeysZipfian(t *testing.T) {
+	const capacity = 50
+	h := NewHotKeys[int](capacity)
+	counts := make(map[int]int64)
+
+	gen := must(workload.NewZipfian(100_000, 0.99, 1))
+	for i := 0; i < 200_000; i++ {
+		key := int(gen.Next())
+		counts[key]++
+		h.Record(key)
+	}
+	checkHotKeys(t, h, capacity, counts)
+
+	// The five most used keys are clear of the error and must all be found
+	top := h.TopKeys(10)
+	found := make(map[int]bool)
+	for _, hk := range top {
+		found[hk.Key] = true
+	}
+	for key := 0; key < 5; key++ {
+		if !found[key] {
+			t.Errorf("Expected key %d (used %d times) among the top keys, got %+v", key, counts[key], top)
+		}
+	}
+}
+
+func TestHotKeysConcurrent(t *testing.T) {
+	const capacity = 20
+	h := NewHotKeys[int](capacity)
+
+	var mu sync.Mutex
+	counts := make(map[int]int64)
+	var wg sync.WaitGroup
+	for i := 0; i < 8; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			gen := must(workload.NewZipfian(1000, 0.9, uint64(i)))
+			local := make(map[int]int64)
+			for j := 0; j < 5000; j++ {
+				key := int(gen.Next())
+				local[key]++
+				h.Record(key)
+				if j%1000 == 0 {
+					h.TopKeys(5)
+				}
+			}
+
+			mu.Lock()
+			defer mu.Unlock()
+			for key, n := range local {
+				counts[key] += n
+			}
+		}()
+	}
+	wg.Wait()
+
+	if h.Total() != 40_000 {
+		t.Errorf("Expected 40000 uses recorded, got %d", h.Total())
+	}
+	checkHotKeys(t, h, capacity, counts)
+}
+
+func TestHotKeyCache(t *testing.T) {
+	caches := map[string]TrackedCache[int, int]{
+		"mutex":   NewCache[int, int](10),
+		"sharded": NewShardedCache[int, int](10, 4),
+		"sieve":   NewSIEVECache[int, int](10),
+	}
+	for name, inner := range caches {
+		c := NewHotKeyCache(inner, 10)
+		c.Put(1, 1)
+		for i := 0; i < 5; i++ {
+			c.Get(1)
+			c.Get(2) // misses count as traffic too
+		}
+		c.Put(3, 3)
+
+		if value, found := c.Get(1); !found || *value != 1 {
+			t.Errorf("%s: expected the wrapped cache to serve key 1", name)
+		}
+		top := c.TopKeys(2)
+		if len(top) != 2 || top[0].Key != 1 || top[0].Count != 7 || top[1].Key != 2 || top[1].Count != 5 {
+			t.Errorf("%s: expected keys 1 and 2 used 7 and 5 times, got %+v", name, top)
+		}
+
+		// Delete and GetStatistics reach the wrapped cache
+		if !c.Delete(1) || c.Delete(1) {
+			t.Errorf("%s: expected the first Delete of key 1 to succeed and the second to fail", name)
+		}
+		if stats := c.GetStatistics(); stats.Reads != 11 || stats.Hits != 6 || stats.Deletes != 1 {
+			t.Errorf("%s: expected the wrapped cache's statistics, got %+v", name, stats)
+		}
+	}
+}
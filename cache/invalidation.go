@@ -0,0 +1,232 @@
+package cache
+
+// tagIndex maps tags to the keys carrying them and back
+type tagIndex[K comparable] struct {
+	keys map[string]map[K]struct{} // tag -> keys with that tag
+	tags map[K][]string            // key -> its tags
+}
+
+func newTagIndex[K comparable]() *tagIndex[K] {
+	return &tagIndex[K]{
+		keys: make(map[string]map[K]struct{}),
+		tags: make(map[K][]string),
+	}
+}
+
+// set replaces the tags of key
+func (t *tagIndex[K]) set(key K, tags []string) {
+	t.remove(key)
+	if len(tags) == 0 {
+		return
+	}
+
+	for _, tag := range tags {
+		keys, exists := t.keys[tag]
+		if !exists {
+			keys = make(map[K]struct{})
+			t.keys[tag] = keys
+		}
+		keys[key] = struct{}{}
+	}
+	t.tags[key] = append([]string(nil), tags...)
+}
+
+// remove drops every tag of key
+func (t *tagIndex[K]) remove(key K) {
+	for _, tag := range t.tags[key] {
+		delete(t.keys[tag], key)
+		if len(t.keys[tag]) == 0 {
+			delete(t.keys, tag)
+		}
+	}
+	delete(t.tags, key)
+}
+
+// trieNode is a node of the prefix index; a node ending a key holds that key
+type trieNode[K comparable] struct {
+	children map[byte]*trieNode[K]
+	key      K
+	terminal bool
+}
+
+// prefixIndex is a byte trie over string keys used to find every key with a
+// given prefix without scanning the whole cache
+type prefixIndex[K comparable] struct {
+	root      *trieNode[K]
+	keyString func(K) string // the key as a string; K is always a string type
+}
+
+// newPrefixIndex returns an empty index over keys of a string type K
+func newPrefixIndex[K ~string]() *prefixIndex[K] {
+	return &prefixIndex[K]{
+		root:      &trieNode[K]{},
+		keyString: func(key K) string { return string(key) },
+	}
+}
+
+func (p *prefixIndex[K]) insert(key K) {
+	n := p.root
+	s := p.keyString(key)
+	for i := 0; i < len(s); i++ {
+		if n.children == nil {
+			n.children = make(map[byte]*trieNode[K])
+		}
+		child, exists := n.children[s[i]]
+		if !exists {
+			child = &trieNode[K]{}
+			n.children[s[i]] = child
+		}
+		n = child
+	}
+	n.key = key
+	n.terminal = true
+}
+
+func (p *prefixIndex[K]) remove(key K) {
+	s := p.keyString(key)
+
+	// Remember the path so empty nodes can be pruned on the way back up
+	path := make([]*trieNode[K], 0, len(s)+1)
+	n := p.root
+	for i := 0; i < len(s); i++ {
+		path = append(path, n)
+		n = n.children[s[i]]
+		if n == nil {
+			return
+		}
+	}
+	n.terminal = false
+
+	for i := len(s) - 1; i >= 0 && !n.terminal && len(n.children) == 0; i-- {
+		parent := path[i]
+		delete(parent.children, s[i])
+		n = parent
+	}
+}
+
+// withPrefix returns every key starting with prefix
+func (p *prefixIndex[K]) withPrefix(prefix string) []K {
+	n := p.root
+	for i := 0; i < len(prefix); i++ {
+		n = n.children[prefix[i]]
+		if n == nil {
+			return nil
+		}
+	}
+
+	var keys []K
+	stack := []*trieNode[K]{n}
+	for len(stack) > 0 {
+		n := stack[len(stack)-1]
+		stack = stack[:len(stack)-1]
+		if n.terminal {
+			keys = append(keys, n.key)
+		}
+		for _, child := range n.children {
+			stack = append(stack, child)
+		}
+	}
+	return keys
+}
+
+// PutWithTags adds the value to the cache like Put and replaces the tags of
+// the entry with the given ones, so that InvalidateTag can remove it later.
+// A plain Put of an existing key keeps its tags.
+func (c *Cache[K, V]) PutWithTags(key K, value V, tags ...string) bool {
+	c.contention.lock(&c.mu)
+	defer c.mu.Unlock()
+
+	return c.putWithTagsLocked(key, value, tags)
+}
+
+// putWithTagsLocked implements PutWithTags; the caller must hold c.mu
+func (c *Cache[K, V]) putWithTagsLocked(key K, value V, tags []string) bool {
+	existed := c.putLocked(key, value)
+
+	if c.tags == nil {
+		c.tags = newTagIndex[K]()
+	}
+	c.tags.set(key, tags)
+
+	return existed
+}
+
+// InvalidateTag removes every entry tagged with tag and returns how many were
+// removed
+func (c *Cache[K, V]) InvalidateTag(tag string) int {
+	c.contention.lock(&c.mu)
+	defer c.mu.Unlock()
+
+	if c.tags == nil {
+		return 0
+	}
+
+	keys := c.tags.keys[tag]
+	removed := len(keys)
+	for key := range keys {
+		c.removeLocked(c.items[key])
+	}
+
+	c.stats.AddInvalidations(int64(removed))
+	return removed
+}
+
+// InvalidatePrefix removes every entry of c whose key starts with prefix and
+// returns how many were removed. The prefix index is built on the first call
+// and maintained from then on. It is a function rather than a method so that
+// only caches with string keys accept it.
+func InvalidatePrefix[K ~string, V any](c *Cache[K, V], prefix string) int {
+	c.contention.lock(&c.mu)
+	defer c.mu.Unlock()
+
+	if c.prefixes == nil {
+		c.prefixes = newPrefixIndex[K]()
+		for key := range c.items {
+			c.prefixes.insert(key)
+		}
+	}
+
+	keys := c.prefixes.withPrefix(prefix)
+	for _, key := range keys {
+		c.removeLocked(c.items[key])
+	}
+
+	c.stats.AddInvalidations(int64(len(keys)))
+	return len(keys)
+}
+
+// PutWithTags adds the value to the key's shard and replaces its tags
+func (c *ShardedCache[K, V]) PutWithTags(key K, value V, tags ...string) bool {
+	old, shard, _ := c.lockKey(key, true, lockShard[K, V])
+	defer unlockKey(old, shard)
+
+	return shard.putWithTagsLocked(key, value, tags)
+}
+
+// InvalidateTag removes every entry tagged with tag from all shards. Shards
+// are invalidated one after the other, not atomically.
+func (c *ShardedCache[K, V]) InvalidateTag(tag string) int {
+	return c.invalidate(func(shard *Cache[K, V]) int { return shard.InvalidateTag(tag) })
+}
+
+// InvalidateShardedPrefix removes every entry whose key starts with prefix
+// from all shards of c, as InvalidatePrefix
+func InvalidateShardedPrefix[K ~string, V any](c *ShardedCache[K, V], prefix string) int {
+	return c.invalidate(func(shard *Cache[K, V]) int { return InvalidatePrefix(shard, prefix) })
+}
+
+// invalidate runs fn on every shard and adds up what it removed. If a Reshard
+// starts or finishes meanwhile, entries may have moved past fn, so it goes
+// over the new set again.
+func (c *ShardedCache[K, V]) invalidate(fn func(shard *Cache[K, V]) int) int {
+	removed := 0
+	for set := c.set.Load(); ; {
+		set.each(func(shard *Cache[K, V]) { removed += fn(shard) })
+
+		next := c.set.Load()
+		if next == set {
+			return removed
+		}
+		set = next
+	}
+}
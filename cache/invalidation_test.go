@@ -0,0 +1,153 @@
+package cache
+
+import (
+	"fmt"
+	"sort"
+	"sync"
+	"testing"
+)
+
+func TestInvalidateTag(t *testing.T) {
+	cache := NewCache[string, string](10)
+
+	cache.PutWithTags("user:1:profile", "alice", "user:1")
+	cache.PutWithTags("user:1:avatar", "alice.png", "user:1", "images")
+	cache.PutWithTags("user:2:profile", "bob", "user:2")
+	cache.Put("settings", "dark")
+
+	if removed := cache.InvalidateTag("user:1"); removed != 2 {
+		t.Errorf("Expected 2 entries removed, got %d", removed)
+	}
+	for _, key := range []string{"user:1:profile", "user:1:avatar"} {
+		if _, found := cache.Get(key); found {
+			t.Errorf("Key %q should have been invalidated", key)
+		}
+	}
+	for _, key := range []string{"user:2:profile", "settings"} {
+		if _, found := cache.Get(key); !found {
+			t.Errorf("Key %q should not have been invalidated", key)
+		}
+	}
+
+	// The invalidated avatar is gone from its other tag too
+	if removed := cache.InvalidateTag("images"); removed != 0 {
+		t.Errorf("Expected nothing left tagged 'images', got %d", removed)
+	}
+
+	stats := cache.GetStatistics()
+	if stats.Invalidations != 2 {
+		t.Errorf("Expected 2 invalidations, got %d", stats.Invalidations)
+	}
+}
+
+func TestInvalidateTagAfterEviction(t *testing.T) {
+	cache := NewCache[string, int](2)
+
+	cache.PutWithTags("one", 1, "numbers")
+	cache.PutWithTags("two", 2, "numbers")
+	cache.Put("three", 3) // evicts "one"
+
+	// Retagging an entry replaces its tags
+	cache.PutWithTags("two", 22, "even")
+
+	if removed := cache.InvalidateTag("numbers"); removed != 0 {
+		t.Errorf("Evicted and retagged entries should not be invalidated, got %d", removed)
+	}
+	if removed := cache.InvalidateTag("even"); removed != 1 {
+		t.Errorf("Expected 1 entry tagged 'even', got %d", removed)
+	}
+
+	// The freed slot is reused without an eviction
+	cache.Put("four", 4)
+	if _, found := cache.Get("three"); !found {
+		t.Error("Key 'three' should still be cached")
+	}
+	if stats := cache.GetStatistics(); stats.Evictions != 1 {
+		t.Errorf("Expected 1 eviction, got %d", stats.Evictions)
+	}
+}
+
+func TestInvalidatePrefix(t *testing.T) {
+	cache := NewCache[string, int](10)
+
+	cache.Put("user:1", 1)
+	cache.Put("user:10", 10)
+	cache.Put("user:2", 2)
+	cache.Put("group:1", 100)
+
+	if removed := InvalidatePrefix(cache, "user:1"); removed != 2 {
+		t.Errorf("Expected 2 entries removed, got %d", removed)
+	}
+
+	// Keys added after the index was built are indexed too
+	cache.Put("user:3", 3)
+	if removed := InvalidatePrefix(cache, "user:"); removed != 2 {
+		t.Errorf("Expected 2 entries removed, got %d", removed)
+	}
+
+	if _, found := cache.Get("group:1"); !found {
+		t.Error("Key 'group:1' should not have been invalidated")
+	}
+	if removed := InvalidatePrefix(cache, "nothing"); removed != 0 {
+		t.Errorf("Expected nothing removed, got %d", removed)
+	}
+	if removed := InvalidatePrefix(cache, ""); removed != 1 {
+		t.Errorf("The empty prefix should match every key, got %d", removed)
+	}
+}
+
+func TestPrefixIndex(t *testing.T) {
+	type userID string
+	index := newPrefixIndex[userID]()
+
+	for _, key := range []userID{"a", "ab", "abc", "abd", "b"} {
+		index.insert(key)
+	}
+	index.remove("abc")
+	index.remove("missing")
+
+	got := index.withPrefix("ab")
+	sort.Slice(got, func(i, j int) bool { return got[i] < got[j] })
+	if fmt.Sprint(got) != "[ab abd]" {
+		t.Errorf("Expected [ab abd], got %v", got)
+	}
+
+	index.remove("abd")
+	index.remove("ab")
+	if _, exists := index.root.children['a'].children['b']; exists {
+		t.Error("Empty trie branches should be pruned")
+	}
+}
+
+func TestShardedCacheInvalidation(t *testing.T) {
+	cache := NewShardedCache[string, int](8000, 8)
+
+	var wg sync.WaitGroup
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+		go func(userID int) {
+			defer wg.Done()
+
+			for j := 0; j < 20; j++ {
+				key := fmt.Sprintf("user:%d:item%d", userID, j)
+				cache.PutWithTags(key, j, fmt.Sprintf("user:%d", userID))
+			}
+		}(i)
+	}
+	wg.Wait()
+
+	if removed := cache.InvalidateTag("user:3"); removed != 20 {
+		t.Errorf("Expected 20 entries removed across shards, got %d", removed)
+	}
+	if removed := InvalidateShardedPrefix(cache, "user:4:"); removed != 20 {
+		t.Errorf("Expected 20 entries removed across shards, got %d", removed)
+	}
+
+	stats := cache.GetStatistics()
+	if stats.Invalidations != 40 {
+		t.Errorf("Expected 40 invalidations, got %d", stats.Invalidations)
+	}
+	if stats.CurrentNeverRead != 160 {
+		t.Errorf("Expected 160 entries left, got %d", stats.CurrentNeverRead)
+	}
+}
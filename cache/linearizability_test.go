@@ -0,0 +1,416 @@
+package cache
+
+import (
+	"fmt"
+	"math/rand/v2"
+	"sort"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+)
+
+// This file is a small linearizability checker in the style of Wing & Gong,
+// with the memoization used by Lowe and Porcupine. Goroutines record every
+// call and return with a shared logical clock; the checker then searches for
+// an order of the operations that respects real time (an operation that
+// returned before another was called comes first) and that a sequential LRU
+// model accepts step by step.
+
+// histKind is the kind of a recorded operation
+type histKind uint8
+
+const (
+	histPut histKind = iota
+	histGet
+	histDelete
+)
+
+// operation is one recorded call with its inputs, outputs and timestamps
+type operation struct {
+	client int
+	kind   histKind
+	key    int
+	value  int  // Put input
+	found  bool // Get hit, or whether Put/Delete found an existing value
+	got    int  // Get output
+	call   int64
+	ret    int64
+}
+
+func (op operation) String() string {
+	switch op.kind {
+	case histPut:
+		return fmt.Sprintf("c%d Put(%d, %d) = %v [%d,%d]", op.client, op.key, op.value, op.found, op.call, op.ret)
+	case histGet:
+		return fmt.Sprintf("c%d Get(%d) = %d, %v [%d,%d]", op.client, op.key, op.got, op.found, op.call, op.ret)
+	default:
+		return fmt.Sprintf("c%d Delete(%d) = %v [%d,%d]", op.client, op.key, op.found, op.call, op.ret)
+	}
+}
+
+// historyRecorder collects operations from concurrent goroutines
+type historyRecorder struct {
+	clock atomic.Int64
+	mu    sync.Mutex
+	ops   []operation
+}
+
+// record timestamps op around do, which performs it and fills in its outputs
+func (h *historyRecorder) record(op operation, do func(op *operation)) {
+	op.call = h.clock.Add(1)
+	do(&op)
+	op.ret = h.clock.Add(1)
+
+	h.mu.Lock()
+	h.ops = append(h.ops, op)
+	h.mu.Unlock()
+}
+
+// kv is an entry of the sequential model
+type kv struct {
+	key, value int
+}
+
+// lruModel is the sequential specification: entries ordered from most to
+// least recently used
+type lruModel struct {
+	capacity int
+	entries  []kv
+}
+
+func (m lruModel) index(key int) int {
+	for i, e := range m.entries {
+		if e.key == key {
+			return i
+		}
+	}
+	return -1
+}
+
+// step applies op to the model and reports whether op's outputs are the ones
+// the model produces. The receiver is not modified.
+func (m lruModel) step(op operation) (lruModel, bool) {
+	i := m.index(op.key)
+	next := lruModel{capacity: m.capacity}
+
+	switch op.kind {
+	case histPut:
+		if op.found != (i >= 0) {
+			return m, false
+		}
+		next.entries = append(next.entries, kv{op.key, op.value})
+		for j, e := range m.entries {
+			if j != i {
+				next.entries = append(next.entries, e)
+			}
+		}
+		if len(next.entries) > m.capacity {
+			next.entries = next.entries[:m.capacity]
+		}
+
+	case histGet:
+		if i < 0 {
+			return m, !op.found
+		}
+		if !op.found || op.got != m.entries[i].value {
+			return m, false
+		}
+		next.entries = append(next.entries, m.entries[i])
+		for j, e := range m.entries {
+			if j != i {
+				next.entries = append(next.entries, e)
+			}
+		}
+
+	case histDelete:
+		if op.found != (i >= 0) {
+			return m, false
+		}
+		if i < 0 {
+			return m, true
+		}
+		next.entries = append(next.entries, m.entries[:i]...)
+		next.entries = append(next.entries, m.entries[i+1:]...)
+	}
+
+	return next, true
+}
+
+func (m lruModel) String() string {
+	var sb strings.Builder
+	for _, e := range m.entries {
+		fmt.Fprintf(&sb, "%d=%d,", e.key, e.value)
+	}
+	return sb.String()
+}
+
+// histNode is a call or return event in the doubly linked event list
+type histNode struct {
+	op         int // index into the history
+	isCall     bool
+	match      *histNode // the return of a call
+	prev, next *histNode
+}
+
+// buildEventList returns a sentinel-headed list of the call and return
+// events of ops ordered by time
+func buildEventList(ops []operation) *histNode {
+	type event struct {
+		op     int
+		isCall bool
+		time   int64
+	}
+	events := make([]event, 0, 2*len(ops))
+	for i, op := range ops {
+		events = append(events, event{i, true, op.call}, event{i, false, op.ret})
+	}
+	sort.Slice(events, func(i, j int) bool { return events[i].time < events[j].time })
+
+	head := &histNode{}
+	tail := head
+	calls := make(map[int]*histNode)
+	for _, e := range events {
+		n := &histNode{op: e.op, isCall: e.isCall, prev: tail}
+		tail.next = n
+		tail = n
+		if e.isCall {
+			calls[e.op] = n
+		} else {
+			calls[e.op].match = n
+		}
+	}
+	return head
+}
+
+// lift removes a call and its return from the list
+func lift(call *histNode) {
+	call.prev.next = call.next
+	if call.next != nil {
+		call.next.prev = call.prev
+	}
+	ret := call.match
+	ret.prev.next = ret.next
+	if ret.next != nil {
+		ret.next.prev = ret.prev
+	}
+}
+
+// unlift puts a lifted call and its return back, in reverse order
+func unlift(call *histNode) {
+	ret := call.match
+	ret.prev.next = ret
+	if ret.next != nil {
+		ret.next.prev = ret
+	}
+	call.prev.next = call
+	if call.next != nil {
+		call.next.prev = call
+	}
+}
+
+// checkLinearizable reports whether the history is linearizable with respect
+// to an LRU cache of the given capacity
+func checkLinearizable(ops []operation, capacity int) bool {
+	head := buildEventList(ops)
+	linearized := make([]byte, (len(ops)+7)/8)
+	seen := make(map[string]bool)
+
+	type frame struct {
+		call  *histNode
+		state lruModel
+	}
+	var stack []frame
+
+	state := lruModel{capacity: capacity}
+	n := head.next
+	for head.next != nil {
+		if n.isCall {
+			next, ok := state.step(ops[n.op])
+			if ok {
+				linearized[n.op/8] |= 1 << (n.op % 8)
+				key := string(linearized) + "|" + next.String()
+				if !seen[key] {
+					seen[key] = true
+					stack = append(stack, frame{n, state})
+					state = next
+					lift(n)
+					n = head.next
+					continue
+				}
+				linearized[n.op/8] &^= 1 << (n.op % 8)
+			}
+			n = n.next
+			continue
+		}
+
+		// A return was reached before its call could be linearized: backtrack
+		if len(stack) == 0 {
+			return false
+		}
+		f := stack[len(stack)-1]
+		stack = stack[:len(stack)-1]
+		state = f.state
+		linearized[f.call.op/8] &^= 1 << (f.call.op % 8)
+		unlift(f.call)
+		n = f.call.next
+	}
+	return true
+}
+
+// linearizabilityCache is what the history generator drives
+type linearizabilityCache interface {
+	Put(key int, value int) bool
+	Get(key int) (*int, bool)
+	Delete(key int) bool
+}
+
+// recordHistory runs random operations from several goroutines against c
+func recordHistory(c linearizabilityCache, seed uint64, clients, opsPerClient, keys int) []operation {
+	h := &historyRecorder{}
+
+	var wg sync.WaitGroup
+	for client := 0; client < clients; client++ {
+		wg.Add(1)
+		go func(client int) {
+			defer wg.Done()
+
+			rng := rand.New(rand.NewPCG(seed, uint64(client)))
+			for i := 0; i < opsPerClient; i++ {
+				op := operation{client: client, key: rng.IntN(keys)}
+				switch p := rng.IntN(10); {
+				case p < 4:
+					op.kind = histPut
+					op.value = client*1000 + i // unique, so stale reads are caught
+					h.record(op, func(op *operation) { op.found = c.Put(op.key, op.value) })
+				case p < 9:
+					op.kind = histGet
+					h.record(op, func(op *operation) {
+						if v, found := c.Get(op.key); found {
+							op.found, op.got = true, *v
+						}
+					})
+				default:
+					op.kind = histDelete
+					h.record(op, func(op *operation) { op.found = c.Delete(op.key) })
+				}
+			}
+		}(client)
+	}
+	wg.Wait()
+
+	return h.ops
+}
+
+func TestLinearizabilityChecker(t *testing.T) {
+	// Sequential: Put(1,10) then Get(1) = 10
+	ok := []operation{
+		{kind: histPut, key: 1, value: 10, call: 1, ret: 2},
+		{kind: histGet, key: 1, found: true, got: 10, call: 3, ret: 4},
+	}
+	if !checkLinearizable(ok, 2) {
+		t.Error("A sequential history should be linearizable")
+	}
+
+	// Overlapping: the Get may take effect before the Put
+	overlapping := []operation{
+		{kind: histPut, key: 1, value: 10, call: 1, ret: 4},
+		{kind: histGet, key: 1, found: false, call: 2, ret: 3},
+	}
+	if !checkLinearizable(overlapping, 2) {
+		t.Error("A Get overlapping a Put may miss")
+	}
+
+	// Stale read: the Get starts after the Put returned but misses
+	stale := []operation{
+		{kind: histPut, key: 1, value: 10, call: 1, ret: 2},
+		{kind: histGet, key: 1, found: false, call: 3, ret: 4},
+	}
+	if checkLinearizable(stale, 2) {
+		t.Error("A read missing a completed Put should not be linearizable")
+	}
+
+	// Wrong eviction order: with capacity 1, key 1 must be evicted by Put(2)
+	evicted := []operation{
+		{kind: histPut, key: 1, value: 10, call: 1, ret: 2},
+		{kind: histPut, key: 2, value: 20, call: 3, ret: 4},
+		{kind: histGet, key: 1, found: true, got: 10, call: 5, ret: 6},
+	}
+	if checkLinearizable(evicted, 1) {
+		t.Error("Reading an evicted key should not be linearizable")
+	}
+}
+
+func TestLinearizableCache(t *testing.T) {
+	for seed := uint64(0); seed < 50; seed++ {
+		c := NewCache[int, int](3)
+		ops := recordHistory(c, seed, 4, 25, 6)
+		if !checkLinearizable(ops, 3) {
+			t.Fatalf("Seed %d: history is not linearizable:\n%s", seed, formatHistory(ops))
+		}
+	}
+}
+
+func TestLinearizableRWMutexCache(t *testing.T) {
+	for seed := uint64(0); seed < 50; seed++ {
+		c := NewRWMutexCache[int, int](3)
+		ops := recordHistory(c, seed, 4, 25, 6)
+		if !checkLinearizable(ops, 3) {
+			t.Fatalf("Seed %d: history is not linearizable:\n%s", seed, formatHistory(ops))
+		}
+	}
+}
+
+func TestLinearizableChannelCache(t *testing.T) {
+	for seed := uint64(0); seed < 50; seed++ {
+		c := NewChannelCache[int, int](3)
+		ops := recordHistory(c, seed, 4, 25, 6)
+		c.Close()
+		if !checkLinearizable(ops, 3) {
+			t.Fatalf("Seed %d: history is not linearizable:\n%s", seed, formatHistory(ops))
+		}
+	}
+}
+
+func TestLinearizableArenaCache(t *testing.T) {
+	for seed := uint64(0); seed < 50; seed++ {
+		c := NewArenaCache[int, int](3)
+		ops := recordHistory(c, seed, 4, 25, 6)
+		if !checkLinearizable(ops, 3) {
+			t.Fatalf("Seed %d: history is not linearizable:\n%s", seed, formatHistory(ops))
+		}
+	}
+}
+
+func TestLinearizableShardedCache(t *testing.T) {
+	// Each shard is an independent LRU, so the history is checked one shard at
+	// a time (linearizability is compositional)
+	for seed := uint64(0); seed < 50; seed++ {
+		c := NewShardedCache[int, int](8, 4)
+		ops := recordHistory(c, seed, 4, 25, 12)
+
+		partitions := make(map[*Cache[int, int]][]operation)
+		for _, op := range ops {
+			shard := c.getShard(op.key)
+			partitions[shard] = append(partitions[shard], op)
+		}
+		for shard, part := range partitions {
+			if !checkLinearizable(part, shard.entryLimit) {
+				t.Fatalf("Seed %d: shard history is not linearizable:\n%s", seed, formatHistory(part))
+			}
+		}
+	}
+}
+
+// formatHistory prints a history ordered by call time
+func formatHistory(ops []operation) string {
+	sorted := append([]operation(nil), ops...)
+	sort.Slice(sorted, func(i, j int) bool { return sorted[i].call < sorted[j].call })
+
+	var sb strings.Builder
+	for _, op := range sorted {
+		sb.WriteString(op.String())
+		sb.WriteByte('\n')
+	}
+	return sb.String()
+}
@@ -0,0 +1,347 @@
+package cache
+
+import (
+	"fmt"
+	"math/rand/v2"
+	"slices"
+	"strconv"
+	"testing"
+)
+
+// refEntry is an entry of the reference LRU
+type refEntry struct {
+	key, value int
+	read       bool // read since it was last written
+}
+
+// refLRU is a trivially correct LRU cache: a slice ordered from most to least
+// recently used, with the statistics the real caches are expected to keep
+type refLRU struct {
+	capacity int
+	entries  []refEntry
+	stats    Statistics
+}
+
+func (r *refLRU) index(key int) int {
+	return slices.IndexFunc(r.entries, func(e refEntry) bool { return e.key == key })
+}
+
+// touch moves the entry at i to the front
+func (r *refLRU) touch(i int) {
+	e := r.entries[i]
+	copy(r.entries[1:i+1], r.entries[:i])
+	r.entries[0] = e
+}
+
+// put returns whether the key existed, and the evicted key or -1
+func (r *refLRU) put(key, value int) (bool, int) {
+	r.stats.Writes++
+	if i := r.index(key); i >= 0 {
+		r.entries[i].value = value
+		r.entries[i].read = false
+		r.touch(i)
+		return true, -1
+	}
+
+	evicted := -1
+	if len(r.entries) >= r.capacity {
+		last := r.entries[len(r.entries)-1]
+		r.entries = r.entries[:len(r.entries)-1]
+		if !last.read {
+			r.stats.NeverReadCount++
+		}
+		r.stats.Evictions++
+		evicted = last.key
+	}
+	r.entries = slices.Insert(r.entries, 0, refEntry{key: key, value: value})
+	return false, evicted
+}
+
+func (r *refLRU) get(key int) (int, bool) {
+	r.stats.Reads++
+	i := r.index(key)
+	if i < 0 {
+		r.stats.Misses++
+		return 0, false
+	}
+	r.stats.Hits++
+	r.entries[i].read = true
+	r.touch(i)
+	return r.entries[0].value, true
+}
+
+func (r *refLRU) delete(key int) bool {
+	i := r.index(key)
+	if i < 0 {
+		return false
+	}
+	r.entries = slices.Delete(r.entries, i, i+1)
+	r.stats.Deletes++
+	return true
+}
+
+func (r *refLRU) keys() []int {
+	keys := make([]int, len(r.entries))
+	for i, e := range r.entries {
+		keys[i] = e.key
+	}
+	return keys
+}
+
+// listKeys walks a list from head to tail, giving up after limit nodes so a
+// corrupted list can't hang the test
+func listKeys(l *entryList[int, int], limit int) []int {
+	var keys []int
+	for e := l.head; e != nil && len(keys) <= limit; e = e.next {
+		keys = append(keys, e.key)
+	}
+	return keys
+}
+
+// arenaKeys walks an arena list from head to tail, giving up after limit
+// slots as listKeys does
+func arenaKeys[P any](a *arenaLRU[int, P], limit int) []int {
+	var keys []int
+	for i := a.head; i != arenaNil && len(keys) <= limit; i = a.entries[i].next {
+		keys = append(keys, a.entries[i].key)
+	}
+	return keys
+}
+
+// intBytesCache stores the model's int values as decimal strings, so a
+// BytesCache can be checked against the same reference
+type intBytesCache struct {
+	*BytesCache[int]
+}
+
+func (c intBytesCache) Put(key, value int) bool {
+	return c.BytesCache.Put(key, []byte(strconv.Itoa(value)))
+}
+
+func (c intBytesCache) Get(key int) (*int, bool) {
+	b, found := c.GetValue(key)
+	if !found {
+		return nil, false
+	}
+	value, err := strconv.Atoi(string(b))
+	if err != nil {
+		panic(fmt.Sprintf("BytesCache returned %q for key %d", b, key))
+	}
+	return &value, true
+}
+
+// modelSubject is an implementation under test together with its references.
+// A sharded cache has one reference per shard.
+type modelSubject struct {
+	name    string
+	cache   linearizabilityCache
+	stats   func() Statistics
+	ref     func(key int) *refLRU
+	order   func(key int) []int // keys of the key's LRU list, most recent first
+	refs    []*refLRU
+	evicted *[]int // keys passed to OnEvict, nil if the cache has no callback
+}
+
+func newModelSubjects(capacity int) []*modelSubject {
+	var subjects []*modelSubject
+	limit := capacity * 4 // longest list walk before it counts as corrupted
+
+	c := NewCache[int, int](capacity)
+	cRef := &refLRU{capacity: capacity}
+	cEvicted := new([]int)
+	c.OnEvict(func(key, _ int) { *cEvicted = append(*cEvicted, key) })
+	subjects = append(subjects, &modelSubject{
+		name:    "mutex",
+		cache:   c,
+		stats:   c.GetStatistics,
+		ref:     func(int) *refLRU { return cRef },
+		order:   func(int) []int { return listKeys(&c.lruList, limit) },
+		refs:    []*refLRU{cRef},
+		evicted: cEvicted,
+	})
+
+	rw := NewRWMutexCache[int, int](capacity)
+	rwRef := &refLRU{capacity: capacity}
+	subjects = append(subjects, &modelSubject{
+		name:  "rwmutex",
+		cache: rw,
+		stats: rw.GetStatistics,
+		ref:   func(int) *refLRU { return rwRef },
+		order: func(int) []int { return listKeys(&rw.lrulist, limit) },
+		refs:  []*refLRU{rwRef},
+	})
+
+	const shards = 4
+	sc := NewShardedCache[int, int](capacity*shards, shards)
+	shardRefs := make(map[*Cache[int, int]]*refLRU)
+	scRefs := make([]*refLRU, 0, shards)
+	for _, shard := range sc.set.Load().shards {
+		ref := &refLRU{capacity: shard.entryLimit}
+		shardRefs[shard] = ref
+		scRefs = append(scRefs, ref)
+	}
+	scEvicted := new([]int)
+	sc.OnEvict(func(key, _ int) { *scEvicted = append(*scEvicted, key) })
+	subjects = append(subjects, &modelSubject{
+		name:    "sharded",
+		cache:   sc,
+		stats:   sc.GetStatistics,
+		ref:     func(key int) *refLRU { return shardRefs[sc.getShard(key)] },
+		order:   func(key int) []int { return listKeys(&sc.getShard(key).lruList, limit) },
+		refs:    scRefs,
+		evicted: scEvicted,
+	})
+
+	arena := NewArenaCache[int, int](capacity)
+	arenaRef := &refLRU{capacity: capacity}
+	subjects = append(subjects, &modelSubject{
+		name:  "arena",
+		cache: arena,
+		stats: arena.GetStatistics,
+		ref:   func(int) *refLRU { return arenaRef },
+		order: func(int) []int { return arenaKeys(&arena.lru, limit) },
+		refs:  []*refLRU{arenaRef},
+	})
+
+	bc := NewBytesCache[int](capacity, 64)
+	bcRef := &refLRU{capacity: capacity}
+	subjects = append(subjects, &modelSubject{
+		name:  "bytes",
+		cache: intBytesCache{bc},
+		stats: bc.GetStatistics,
+		ref:   func(int) *refLRU { return bcRef },
+		order: func(int) []int { return arenaKeys(&bc.lru, limit) },
+		refs:  []*refLRU{bcRef},
+	})
+
+	// The owner goroutine is idle between calls, and each reply orders its
+	// changes before the walk of its list
+	cc := NewChannelCache[int, int](capacity)
+	ccRef := &refLRU{capacity: capacity}
+	subjects = append(subjects, &modelSubject{
+		name:  "channel",
+		cache: cc,
+		stats: cc.GetStatistics,
+		ref:   func(int) *refLRU { return ccRef },
+		order: func(int) []int { return listKeys(&cc.lruList, limit) },
+		refs:  []*refLRU{ccRef},
+	})
+
+	return subjects
+}
+
+// runModel decodes data into operations and checks every implementation
+// against the reference after each one. The first byte picks the capacity,
+// then every pair of bytes is an operation and a key.
+func runModel(t *testing.T, data []byte) {
+	if len(data) == 0 {
+		return
+	}
+	capacity := 1 + int(data[0]%8)
+	subjects := newModelSubjects(capacity)
+	for _, s := range subjects {
+		if c, ok := s.cache.(interface{ Close() error }); ok {
+			defer c.Close()
+		}
+	}
+
+	for step, i := 0, 1; i+1 < len(data); step, i = step+1, i+2 {
+		key := int(data[i+1] % 16)
+		for _, s := range subjects {
+			ref := s.ref(key)
+			var desc string
+
+			switch data[i] % 4 {
+			case 0, 1:
+				desc = fmt.Sprintf("Get(%d)", key)
+				want, wantFound := ref.get(key)
+				got, found := s.cache.Get(key)
+				if found != wantFound || (found && *got != want) {
+					t.Fatalf("%s step %d: %s = %v, %v, expected %d, %v", s.name, step, desc, deref(got), found, want, wantFound)
+				}
+			case 2:
+				desc = fmt.Sprintf("Put(%d, %d)", key, step)
+				wantExisted, wantEvicted := ref.put(key, step)
+				before := 0
+				if s.evicted != nil {
+					before = len(*s.evicted)
+				}
+				if existed := s.cache.Put(key, step); existed != wantExisted {
+					t.Fatalf("%s step %d: %s = %v, expected %v", s.name, step, desc, existed, wantExisted)
+				}
+				if s.evicted != nil {
+					got := (*s.evicted)[before:]
+					if (wantEvicted < 0 && len(got) != 0) || (wantEvicted >= 0 && !slices.Equal(got, []int{wantEvicted})) {
+						t.Fatalf("%s step %d: %s evicted %v, expected %d", s.name, step, desc, got, wantEvicted)
+					}
+				}
+			case 3:
+				desc = fmt.Sprintf("Delete(%d)", key)
+				want := ref.delete(key)
+				if existed := s.cache.Delete(key); existed != want {
+					t.Fatalf("%s step %d: %s = %v, expected %v", s.name, step, desc, existed, want)
+				}
+			}
+
+			if got, want := s.order(key), ref.keys(); !slices.Equal(got, want) {
+				t.Fatalf("%s step %d: after %s LRU order is %v, expected %v", s.name, step, desc, got, want)
+			}
+			checkModelStats(t, s, step, desc)
+		}
+	}
+}
+
+// checkModelStats compares the counters with the sum of the references
+func checkModelStats(t *testing.T, s *modelSubject, step int, desc string) {
+	var want Statistics
+	for _, ref := range s.refs {
+		want.Reads += ref.stats.Reads
+		want.Writes += ref.stats.Writes
+		want.Hits += ref.stats.Hits
+		want.Misses += ref.stats.Misses
+		want.Evictions += ref.stats.Evictions
+		want.Deletes += ref.stats.Deletes
+		want.NeverReadCount += ref.stats.NeverReadCount
+		for _, e := range ref.entries {
+			if !e.read {
+				want.CurrentNeverRead++
+			}
+		}
+	}
+
+	got := s.stats()
+	got.AverageAccessCount = 0
+	if got != want {
+		t.Fatalf("%s step %d: after %s statistics are %+v, expected %+v", s.name, step, desc, got, want)
+	}
+}
+
+func deref(v *int) any {
+	if v == nil {
+		return nil
+	}
+	return *v
+}
+
+func FuzzCacheModel(f *testing.F) {
+	f.Add([]byte{0})
+	f.Add([]byte{1, 2, 1, 2, 2, 0, 1, 2, 3, 0, 1})
+	f.Add([]byte{2, 2, 1, 2, 2, 2, 3, 0, 1, 2, 4, 0, 2, 3, 1})
+	f.Add([]byte{0, 2, 5, 2, 6, 0, 5, 2, 7, 3, 6, 0, 7})
+	f.Add([]byte("put get delete put put get"))
+
+	f.Fuzz(runModel)
+}
+
+// TestCacheModelRandomized runs the model check over random operation streams,
+// so the ordinary test run covers far more than the fuzz seeds
+func TestCacheModelRandomized(t *testing.T) {
+	for seed := uint64(0); seed < 200; seed++ {
+		rng := rand.New(rand.NewPCG(seed, 0))
+		data := make([]byte, 1+2*rng.IntN(200))
+		for i := range data {
+			data[i] = byte(rng.Uint32())
+		}
+		runModel(t, data)
+	}
+}
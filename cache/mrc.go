@@ -0,0 +1,382 @@
+package cache
+
+import (
+	"container/heap"
+	"fmt"
+	"sync"
+	"sync/atomic"
+)
+
+const (
+	// mrcModulus is the range key hashes are reduced to; a key is sampled
+	// when its hash falls below the estimator's threshold
+	mrcModulus = 1 << 24
+
+	// defaultMRCSamples is how many distinct keys an MRCEstimator tracks by
+	// default, which SHARDS found enough for an error around 0.01
+	defaultMRCSamples = 8192
+
+	// mrcBuckets is the resolution of the reuse distance histogram
+	mrcBuckets = 1024
+)
+
+// MRCPoint is the hit ratio an LRU cache of Size entries would have had
+type MRCPoint struct {
+	Size     int
+	HitRatio float64
+}
+
+// MRCEstimator estimates the miss ratio curve of a stream of keys: the hit
+// ratio an LRU cache would get at every size, from one pass over the stream.
+// An LRU cache of size C hits a key exactly when fewer than C other keys were
+// used since its previous use, its reuse distance, so a histogram of reuse
+// distances gives the whole curve.
+//
+// Measuring every distance would take memory for every key, so it uses
+// fixed-size SHARDS: only keys whose hash falls below a threshold are
+// tracked, and each sampled distance is scaled up by the sampling rate. When
+// more than maxSamples keys are tracked, the threshold is lowered and the
+// keys above it dropped, so memory stays bounded however many keys there
+// are. Keys that aren't sampled cost one hash and no lock. Sizes that hold
+// few sampled keys, under about 10/SampleRate entries, are estimated from the
+// handful of hottest keys that happen to be sampled and are less reliable.
+type MRCEstimator[K comparable] struct {
+	threshold  atomic.Uint64 // keys hashing below this are sampled
+	references atomic.Int64  // keys recorded, sampled or not
+
+	mu         sync.Mutex
+	maxSamples int
+	last       map[K]mrcSample // when each sampled key was last used
+	byHash     hashHeap[K]     // the sampled keys, highest hash first
+	times      fenwickTree     // marks the last use of every sampled key
+	clock      int             // next time to hand out
+
+	hist     [mrcBuckets]float64 // weight of scaled distances, width per bucket
+	width    int
+	weight   float64 // weight of every sampled use, first uses included
+	farthest float64 // longest scaled distance seen
+}
+
+// mrcSample is a key tracked by an MRCEstimator
+type mrcSample struct {
+	time int    // when the key was last used
+	hash uint64 // the key's hash, reduced to mrcModulus
+}
+
+// NewMRCEstimator creates an estimator that tracks at most maxSamples keys;
+// zero or less selects 8192. More samples make a more accurate curve.
+func NewMRCEstimator[K comparable](maxSamples int) *MRCEstimator[K] {
+	if maxSamples <= 0 {
+		maxSamples = defaultMRCSamples
+	}
+
+	m := &MRCEstimator[K]{
+		maxSamples: maxSamples,
+		last:       make(map[K]mrcSample),
+		times:      newFenwickTree(2 * maxSamples),
+		width:      1,
+	}
+	m.threshold.Store(mrcModulus)
+	return m
+}
+
+// Record adds a use of key to the stream
+func (m *MRCEstimator[K]) Record(key K) {
+	m.references.Add(1)
+
+	hash := mrcHash(key) % mrcModulus
+	if hash >= m.threshold.Load() {
+		return
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	threshold := m.threshold.Load()
+	if hash >= threshold {
+		return
+	}
+	rate := float64(threshold) / mrcModulus
+	m.weight += 1 / rate
+
+	if m.clock == m.times.len() {
+		m.compactLocked()
+	}
+
+	if s, seen := m.last[key]; seen {
+		distance := m.times.sum(m.clock) - m.times.sum(s.time+1)
+		m.times.add(s.time, -1)
+		m.addDistanceLocked(float64(distance)/rate, 1/rate)
+	} else {
+		heap.Push(&m.byHash, hashedKey[K]{key: key, hash: hash})
+	}
+
+	m.times.add(m.clock, 1)
+	m.last[key] = mrcSample{time: m.clock, hash: hash}
+	m.clock++
+
+	if len(m.last) > m.maxSamples {
+		m.shrinkLocked()
+	}
+}
+
+// addDistanceLocked adds weight to the bucket of a scaled reuse distance,
+// halving the resolution if it's beyond the last bucket
+func (m *MRCEstimator[K]) addDistanceLocked(distance, weight float64) {
+	m.farthest = max(m.farthest, distance)
+
+	bucket := int(distance) / m.width
+	for bucket >= mrcBuckets {
+		for i := 0; i < mrcBuckets/2; i++ {
+			m.hist[i] = m.hist[2*i] + m.hist[2*i+1]
+		}
+		clear(m.hist[mrcBuckets/2:])
+		m.width *= 2
+		bucket = int(distance) / m.width
+	}
+	m.hist[bucket] += weight
+}
+
+// shrinkLocked lowers the threshold until at most maxSamples keys are tracked
+func (m *MRCEstimator[K]) shrinkLocked() {
+	threshold := m.threshold.Load()
+	for len(m.last) > m.maxSamples || (len(m.byHash) > 0 && m.byHash[0].hash >= threshold) {
+		top := heap.Pop(&m.byHash).(hashedKey[K])
+		threshold = top.hash
+		m.times.add(m.last[top.key].time, -1)
+		delete(m.last, top.key)
+	}
+	m.threshold.Store(threshold)
+}
+
+// compactLocked renumbers the last uses of the sampled keys from 0, in the
+// same order, once the clock has run through the tree
+func (m *MRCEstimator[K]) compactLocked() {
+	byTime := make([]K, m.times.len())
+	used := make([]bool, m.times.len())
+	for key, s := range m.last {
+		byTime[s.time] = key
+		used[s.time] = true
+	}
+
+	m.times = newFenwickTree(m.times.len())
+	m.clock = 0
+	for time, key := range byTime {
+		if !used[time] {
+			continue
+		}
+		s := m.last[key]
+		s.time = m.clock
+		m.last[key] = s
+		m.times.add(m.clock, 1)
+		m.clock++
+	}
+}
+
+// HitRatio returns the estimated hit ratio of an LRU cache of size entries
+// on the stream recorded so far
+func (m *MRCEstimator[K]) HitRatio(size int) float64 {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	return m.hitRatioLocked(size)
+}
+
+func (m *MRCEstimator[K]) hitRatioLocked(size int) float64 {
+	references := float64(m.references.Load())
+	if references == 0 || size <= 0 {
+		return 0
+	}
+
+	// Distances below size hit; the bucket size falls in counts in part
+	var hits float64
+	for i := 0; i < mrcBuckets && i*m.width < size; i++ {
+		covered := min(size-i*m.width, m.width)
+		hits += m.hist[i] * float64(covered) / float64(m.width)
+	}
+
+	// As in SHARDS_adj, references the sample over or under represents are
+	// taken to be reuses at the shortest distance
+	hits += references - m.weight
+
+	return min(max(hits/references, 0), 1)
+}
+
+// Curve returns the estimated hit ratio at each of sizes. Without sizes, it
+// returns the whole curve at powers of two, up to the first size beyond the
+// longest reuse distance seen, where it levels off.
+func (m *MRCEstimator[K]) Curve(sizes ...int) []MRCPoint {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if len(sizes) == 0 {
+		for size := 1; ; size *= 2 {
+			sizes = append(sizes, size)
+			if float64(size) > m.farthest {
+				break
+			}
+		}
+	}
+
+	curve := make([]MRCPoint, len(sizes))
+	for i, size := range sizes {
+		curve[i] = MRCPoint{Size: size, HitRatio: m.hitRatioLocked(size)}
+	}
+	return curve
+}
+
+// SampleRate returns the fraction of keys currently being sampled
+func (m *MRCEstimator[K]) SampleRate() float64 {
+	return float64(m.threshold.Load()) / mrcModulus
+}
+
+// mrcHash hashes a key for sampling. Sampling needs every part of the hash
+// range to get its share of keys, so unlike anyToHash it mixes the bits.
+func mrcHash[K comparable](key K) uint64 {
+	var h uint64
+	switch k := any(key).(type) {
+	case int:
+		h = uint64(k)
+	case int64:
+		h = uint64(k)
+	case uint64:
+		h = k
+	case string:
+		h = fnv1a(k)
+	default:
+		h = fnv1a(fmt.Sprintf("%v", key))
+	}
+
+	// splitmix64 finalizer
+	h ^= h >> 30
+	h *= 0xbf58476d1ce4e5b9
+	h ^= h >> 27
+	h *= 0x94d049bb133111eb
+	h ^= h >> 31
+	return h
+}
+
+// fnv1a returns the 64-bit FNV-1a hash of s
+func fnv1a(s string) uint64 {
+	h := uint64(14695981039346656037)
+	for i := 0; i < len(s); i++ {
+		h ^= uint64(s[i])
+		h *= 1099511628211
+	}
+	return h
+}
+
+// fenwickTree counts marks at positions and sums them over prefixes, both in
+// O(log n)
+type fenwickTree []int32
+
+func newFenwickTree(n int) fenwickTree {
+	return make(fenwickTree, n+1)
+}
+
+// len returns how many positions the tree has
+func (t fenwickTree) len() int {
+	return len(t) - 1
+}
+
+// add adds delta at position i
+func (t fenwickTree) add(i int, delta int32) {
+	for i++; i < len(t); i += i & -i {
+		t[i] += delta
+	}
+}
+
+// sum returns the total of positions below i
+func (t fenwickTree) sum(i int) int {
+	total := 0
+	for ; i > 0; i -= i & -i {
+		total += int(t[i])
+	}
+	return total
+}
+
+// hashedKey is a sampled key in an MRCEstimator's eviction heap
+type hashedKey[K comparable] struct {
+	key  K
+	hash uint64
+}
+
+// hashHeap orders sampled keys highest hash first for container/heap
+type hashHeap[K comparable] []hashedKey[K]
+
+func (h hashHeap[K]) Len() int           { return len(h) }
+func (h hashHeap[K]) Less(i, j int) bool { return h[i].hash > h[j].hash }
+func (h hashHeap[K]) Swap(i, j int)      { h[i], h[j] = h[j], h[i] }
+
+func (h *hashHeap[K]) Push(x any) {
+	*h = append(*h, x.(hashedKey[K]))
+}
+
+func (h *hashHeap[K]) Pop() any {
+	old := *h
+	k := old[len(old)-1]
+	*h = old[:len(old)-1]
+	return k
+}
+
+// SizingStatistics adds predicted hit ratios at other sizes to a cache's
+// statistics
+type SizingStatistics struct {
+	Statistics            // the wrapped cache's own counters
+	Predicted  []MRCPoint // estimated LRU hit ratio at each candidate size
+	SampleRate float64    // fraction of keys the estimate is based on
+}
+
+// SizingCache wraps a cache and feeds the keys of its Gets to an
+// MRCEstimator, to tell whether a larger or smaller entry limit would pay.
+// Puts aren't recorded: filling a key after a miss is the same use as the
+// Get that missed. Delete and GetStatistics are passed straight through.
+type SizingCache[K comparable, V any] struct {
+	cache TrackedCache[K, V]
+	mrc   *MRCEstimator[K]
+	sizes []int
+}
+
+// NewSizingCache wraps c with an estimator tracking at most maxSamples keys,
+// as NewMRCEstimator. SizingStatistics predicts the hit ratio at each of
+// sizes, or along the whole curve without sizes.
+func NewSizingCache[K comparable, V any](c TrackedCache[K, V], maxSamples int, sizes ...int) *SizingCache[K, V] {
+	return &SizingCache[K, V]{cache: c, mrc: NewMRCEstimator[K](maxSamples), sizes: sizes}
+}
+
+// Put adds the value to the wrapped cache
+func (c *SizingCache[K, V]) Put(key K, value V) bool {
+	return c.cache.Put(key, value)
+}
+
+// Get records the key and looks it up in the wrapped cache
+func (c *SizingCache[K, V]) Get(key K) (*V, bool) {
+	c.mrc.Record(key)
+	return c.cache.Get(key)
+}
+
+// Delete removes the key from the wrapped cache without recording it
+func (c *SizingCache[K, V]) Delete(key K) bool {
+	return c.cache.Delete(key)
+}
+
+// PredictHitRatio returns the estimated hit ratio of an LRU cache of size
+// entries on the Gets seen so far
+func (c *SizingCache[K, V]) PredictHitRatio(size int) float64 {
+	return c.mrc.HitRatio(size)
+}
+
+// GetStatistics returns the wrapped cache's statistics
+func (c *SizingCache[K, V]) GetStatistics() Statistics {
+	return c.cache.GetStatistics()
+}
+
+// SizingStatistics returns the wrapped cache's statistics along with the hit
+// ratios predicted at the candidate sizes
+func (c *SizingCache[K, V]) SizingStatistics() SizingStatistics {
+	return SizingStatistics{
+		Statistics: c.cache.GetStatistics(),
+		Predicted:  c.mrc.Curve(c.sizes...),
+		SampleRate: c.mrc.SampleRate(),
+	}
+}
@@ -0,0 +1,169 @@
+package cache
+
+import (
+	"concurrency/workload"
+	"math"
+	"sync"
+	"testing"
+)
+
+// must returns g, panicking if its constructor rejected valid test parameters
+func must[G any](g G, err error) G {
+	if err != nil {
+		panic(err)
+	}
+	return g
+}
+
+// zipfTrace returns n keys drawn from a Zipfian distribution over keys
+func zipfTrace(n int, keys uint64, skew float64, seed uint64) []int {
+	gen := must(workload.NewZipfian(keys, skew, seed))
+	trace := make([]int, n)
+	for i := range trace {
+		trace[i] = int(gen.Next())
+	}
+	return trace
+}
+
+func TestMRCEstimatorReuseDistance(t *testing.T) {
+	m := NewMRCEstimator[string](0)
+	for _, key := range []string{"a", "b", "c", "a", "a"} {
+		m.Record(key)
+	}
+
+	// The second "a" follows 2 other keys and hits from size 3; the third
+	// follows none and hits at any size
+	for size, want := range map[int]float64{0: 0, 1: 0.2, 2: 0.2, 3: 0.4, 100: 0.4} {
+		if got := m.HitRatio(size); math.Abs(got-want) > 1e-9 {
+			t.Errorf("Expected a hit ratio of %.2f at size %d, got %.2f", want, size, got)
+		}
+	}
+	if m.SampleRate() != 1 {
+		t.Errorf("Expected every key to be sampled, got rate %v", m.SampleRate())
+	}
+}
+
+func TestMRCEstimatorMatchesLRU(t *testing.T) {
+	if debugValidate {
+		t.Skip("validating caches of up to 50k entries after every Put is too slow")
+	}
+
+	tests := []struct {
+		name       string
+		keys       []int
+		maxSamples int
+		sizes      []int
+		tolerance  float64
+	}{
+		// Tracking every key measures every distance exactly
+		{"exact", zipfTrace(200_000, 10_000, 0.9, 1), 1 << 20, []int{100, 1000, 5000, 20_000}, 0.005},
+		{"one-hit wonders", scanTrace(200_000, 1000, 2), 1 << 20, []int{100, 1000, 5000, 20_000}, 0.005},
+		// Sampling a few thousand of 100k keys stays close at sizes that
+		// hold many sampled keys
+		{"sampled", zipfTrace(500_000, 100_000, 0.99, 3), 4096, []int{1000, 5000, 20_000, 50_000}, 0.02},
+	}
+
+	for _, tt := range tests {
+		m := NewMRCEstimator[int](tt.maxSamples)
+		for _, key := range tt.keys {
+			m.Record(key)
+		}
+
+		for _, size := range tt.sizes {
+			exact := hitRatio(NewCache[int, int](size), tt.keys)
+			predicted := m.HitRatio(size)
+			t.Logf("%s: size %5d: exact %.3f, predicted %.3f (rate %.3f)", tt.name, size, exact, predicted, m.SampleRate())
+			if math.Abs(predicted-exact) > tt.tolerance {
+				t.Errorf("%s: expected a hit ratio within %.3f of %.3f at size %d, got %.3f", tt.name, tt.tolerance, exact, size, predicted)
+			}
+		}
+	}
+}
+
+func TestMRCEstimatorBoundedSamples(t *testing.T) {
+	m := NewMRCEstimator[int](100)
+	for _, key := range zipfTrace(100_000, 50_000, 0.9, 4) {
+		m.Record(key)
+	}
+
+	if len(m.last) > 100 || len(m.byHash) != len(m.last) {
+		t.Errorf("Expected at most 100 keys tracked, got %d (%d in the heap)", len(m.last), len(m.byHash))
+	}
+	if rate := m.SampleRate(); rate >= 0.1 {
+		t.Errorf("Expected the sampling rate to drop well below 0.1, got %v", rate)
+	}
+	for key, s := range m.last {
+		if s.hash >= m.threshold.Load() || mrcHash(key)%mrcModulus != s.hash {
+			t.Errorf("Key %d with hash %d shouldn't be sampled below threshold %d", key, s.hash, m.threshold.Load())
+		}
+	}
+
+	// The curve rises to the levelling off point
+	curve := m.Curve()
+	for i := 1; i < len(curve); i++ {
+		if curve[i].HitRatio < curve[i-1].HitRatio {
+			t.Errorf("Expected the curve to never fall, got %+v", curve)
+			break
+		}
+	}
+}
+
+func TestMRCEstimatorConcurrent(t *testing.T) {
+	m := NewMRCEstimator[int](500)
+
+	var wg sync.WaitGroup
+	for i := 0; i < 8; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for j, key := range zipfTrace(10_000, 5000, 0.9, uint64(i)) {
+				m.Record(key)
+				if j%1000 == 0 {
+					m.Curve(10, 100, 1000)
+				}
+			}
+		}()
+	}
+	wg.Wait()
+
+	if got := m.references.Load(); got != 80_000 {
+		t.Errorf("Expected 80000 references, got %d", got)
+	}
+	if ratio := m.HitRatio(5000); ratio < 0.5 || ratio > 1 {
+		t.Errorf("Expected most references to hit with room for every key, got %.3f", ratio)
+	}
+}
+
+func TestSizingCache(t *testing.T) {
+	c := NewSizingCache[int, int](NewCache[int, int](500), 0, 250, 500, 1000)
+	keys := zipfTrace(100_000, 5000, 0.9, 5)
+	for i, key := range keys {
+		if _, found := c.Get(key); !found {
+			c.Put(key, i)
+		}
+	}
+
+	stats := c.SizingStatistics()
+	if len(stats.Predicted) != 3 || stats.Predicted[1].Size != 500 {
+		t.Fatalf("Expected predictions at 250, 500 and 1000 entries, got %+v", stats.Predicted)
+	}
+	if !(stats.Predicted[0].HitRatio < stats.Predicted[1].HitRatio && stats.Predicted[1].HitRatio < stats.Predicted[2].HitRatio) {
+		t.Errorf("Expected larger caches to hit more, got %+v", stats.Predicted)
+	}
+
+	// With fewer keys than samples, the prediction at the wrapped cache's
+	// own size is what it did
+	if actual := stats.GetHitRate(); math.Abs(stats.Predicted[1].HitRatio-actual) > 0.005 {
+		t.Errorf("Expected a predicted hit ratio of %.3f at size 500, got %.3f", actual, stats.Predicted[1].HitRatio)
+	}
+
+	// A SizingCache is itself a TrackedCache, so it can be wrapped in turn
+	var tracked TrackedCache[int, int] = c
+	hot := NewHotKeyCache(tracked, 10)
+	if !hot.Delete(keys[len(keys)-1]) {
+		t.Errorf("Expected Delete to reach the wrapped cache")
+	}
+	if got := hot.GetStatistics(); got.Deletes != 1 {
+		t.Errorf("Expected the wrapped cache to count one delete, got %+v", got)
+	}
+}
@@ -10,58 +10,87 @@ import (
 type RWMutexCache[K comparable, V any] struct {
 	mu        sync.RWMutex // Use RWMutex instead of Mutex
 	entryLimt int
-	items     map[K]*entry[V]
-	lrulist   *doublyLinkedList[K]
+	items     map[K]*entry[K, V]
+	lrulist   entryList[K, V]
 	stats     *Statistics
+	cloner    cloning[V] // deep copies values on Put and Get, set by SetCloner
+	sched     scheduler  // test hook controlling interleavings, nil in production
 }
 
 // NewRWMutexCache creates a new LRU cache with RWMutex
 func NewRWMutexCache[K comparable, V any](entryLimit int) *RWMutexCache[K, V] {
 	return &RWMutexCache[K, V]{
 		entryLimt: entryLimit,
-		items:     make(map[K]*entry[V]),
-		lrulist:   newDoublyLinkedList[K](),
+		items:     make(map[K]*entry[K, V]),
 		stats:     newStatistics(),
 	}
 }
 
+// SetCloner makes the cache deep copy values with clone when mode says so
+func (c *RWMutexCache[K, V]) SetCloner(clone Cloner[V], mode CloneMode) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.cloner = cloning[V]{clone: clone, mode: mode}
+}
+
 // Put adds a value to the cache
 func (c *RWMutexCache[K, V]) Put(key K, value V) bool {
+	c.yield(yieldBeforeLock)
 	c.mu.Lock() // Need exclusive lock for writes
 	defer c.mu.Unlock()
+	if debugValidate {
+		defer c.debugCheckLocked()
+	}
 
 	c.stats.IncrementWrites()
+	value = c.cloner.onPut(value)
 
 	//Implementation same as regular Cache.Put
 	existingEntry, exists := c.items[key]
 	if exists {
 		existingEntry.value = value
 		existingEntry.readAfterWrite = false
-		c.lrulist.moveToFront(key)
+		c.lrulist.moveToFront(existingEntry)
 		return true
 	}
 
 	if len(c.items) >= c.entryLimt && len(c.items) > 0 {
-		lruKey := c.lrulist.removeLast()
-		if !c.items[lruKey].readAfterWrite {
+		lruEntry := c.lrulist.removeLast()
+		if !lruEntry.readAfterWrite {
 			c.stats.IncrementNeverRead()
 		}
-		delete(c.items, lruKey)
+		delete(c.items, lruEntry.key)
 		c.stats.IncrementEvictions()
 	}
 
-	c.items[key] = &entry[V]{
+	newEntry := &entry[K, V]{
+		key:            key,
 		value:          value,
 		accessCount:    0,
 		readAfterWrite: false,
 	}
-	c.lrulist.addToFront(key)
+	c.items[key] = newEntry
+	c.lrulist.addToFront(newEntry)
 
 	return false
 }
 
+// Get returns a copy of the value associated with key
 func (c *RWMutexCache[K, V]) Get(key K) (*V, bool) {
+	value, found := c.GetValue(key)
+	if !found {
+		return nil, false
+	}
+	return &value, true
+}
+
+// GetValue is Get returning the copy of the value itself
+func (c *RWMutexCache[K, V]) GetValue(key K) (V, bool) {
+	var zero V
+
 	// First try a read lock for the lookup
+	c.yield(yieldBeforeLock)
 	c.mu.RLock()
 	entry, exists := c.items[key]
 	c.mu.RUnlock()
@@ -70,30 +99,55 @@ func (c *RWMutexCache[K, V]) Get(key K) (*V, bool) {
 
 	if !exists {
 		c.stats.IncrementMisses()
-		return nil, false
+		return zero, false
 	}
 
 	// Now we need to update the LRU list and Metadata, which requires a write lock
+	c.yield(yieldBetweenLocks)
 	c.mu.Lock()
 	// Double-check the entry still exists (it might have been evicted in between locks)
 	entry, stillExist := c.items[key]
 	if !stillExist {
 		c.mu.Unlock()
 		c.stats.IncrementMisses()
-		return nil, false
+		return zero, false
 	}
 
 	// Update entry metadata
 	entry.accessCount++
 	entry.readAfterWrite = true
-	c.lrulist.moveToFront(key)
+	c.lrulist.moveToFront(entry)
 	c.stats.IncrementHits()
+	if debugValidate {
+		c.debugCheckLocked()
+	}
 
 	// Make a copy of the value to return
-	result := entry.value
+	result := c.cloner.onGet(entry.value)
 	c.mu.Unlock()
 
-	return &result, true
+	return result, true
+}
+
+// Delete removes the key from the cache
+func (c *RWMutexCache[K, V]) Delete(key K) bool {
+	c.yield(yieldBeforeLock)
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	entry, exists := c.items[key]
+	if !exists {
+		return false
+	}
+
+	c.lrulist.remove(entry)
+	delete(c.items, key)
+	c.stats.IncrementDeletes()
+	if debugValidate {
+		c.debugCheckLocked()
+	}
+
+	return true
 }
 
 // GetStatistics returns consistent statistics about the cache
@@ -125,39 +179,20 @@ func (c *RWMutexCache[K, V]) GetStatistics() Statistics {
 
 // SharededCache implements an LRU cache with mutiple shards for reduced lock contention
 type ShardedCache[K comparable, V any] struct {
-	shards     []*Cache[K, V]
-	shardCount int
-	shardMask  int
-	stats      atomic.Pointer[Statistics]
+	set        atomic.Pointer[shardSet[K, V]] // shards in use, replaced by Reshard
+	entryLimit int
+	stats      atomic.Pointer[Statistics] // counters of the shards retired by Reshard
+
+	reshardMu  sync.Mutex           // serializes Reshard with GetStatistics and the setters below
+	resharding bool                 // a Reshard is moving entries
+	onEvict    func(key K, value V) // set on every shard, including those Reshard creates
+	cloner     cloning[V]
+	sched      scheduler
 }
 
 func NewShardedCache[K comparable, V any](entryLimit int, shardCount int) *ShardedCache[K, V] {
-	// Make sure shardCount is a power of 2 for efficient modulo
-	if shardCount&(shardCount-1) != 0 {
-		// Find next power of 2
-		shardCount--
-		shardCount |= shardCount >> 1
-		shardCount |= shardCount >> 2
-		shardCount |= shardCount >> 4
-		shardCount |= shardCount >> 8
-		shardCount |= shardCount >> 16
-		shardCount++
-	}
-
-	entriesPerShard := entryLimit / shardCount
-	if entriesPerShard < 1 {
-		entriesPerShard = 1
-	}
-
-	cache := &ShardedCache[K, V]{
-		shards:     make([]*Cache[K, V], shardCount),
-		shardCount: shardCount,
-		shardMask:  shardCount,
-	}
-
-	for i := 0; i < shardCount; i++ {
-		cache.shards[i] = NewCache[K, V](entriesPerShard)
-	}
+	cache := &ShardedCache[K, V]{entryLimit: entryLimit}
+	cache.set.Store(newShardSet[K, V](entryLimit, shardCount))
 
 	// Initialize stats
 	initialStats := newStatistics()
@@ -168,10 +203,7 @@ func NewShardedCache[K comparable, V any](entryLimit int, shardCount int) *Shard
 
 // getShard returns the apporpriate shard for a key
 func (c *ShardedCache[K, V]) getShard(key K) *Cache[K, V] {
-	// we need a hash function for the key
-	// This is a simple one; a better implementation would use a good hash function
-	h := anyToHash(key)
-	return c.shards[h&c.shardMask]
+	return c.set.Load().shardFor(key)
 }
 
 // anyToHash converts any comparable to a unit64 hash
@@ -189,22 +221,79 @@ func anyToHash[K comparable](key K) int {
 	return h
 }
 
+// OnEvict registers a callback that receives every entry evicted from any shard.
+// The callback runs while that shard's lock is held, so it must not call back
+// into the cache.
+func (c *ShardedCache[K, V]) OnEvict(fn func(key K, value V)) {
+	c.reshardMu.Lock()
+	defer c.reshardMu.Unlock()
+
+	c.onEvict = fn
+	c.set.Load().each(func(shard *Cache[K, V]) { shard.OnEvict(fn) })
+}
+
 // Put adds a value to the cache
 func (c *ShardedCache[K, V]) Put(key K, value V) bool {
-	return c.getShard(key).Put(key, value)
+	old, shard, _ := c.lockKey(key, true, lockShard[K, V])
+	defer unlockKey(old, shard)
+
+	return shard.putLocked(key, value)
 }
 
 // Get retrieves a value from the cache
 func (c *ShardedCache[K, V]) Get(key K) (*V, bool) {
-	return c.getShard(key).Get(key)
+	value, found := c.GetValue(key)
+	if !found {
+		return nil, false
+	}
+	return &value, true
+}
+
+// GetValue retrieves a copy of a value from the cache
+func (c *ShardedCache[K, V]) GetValue(key K) (V, bool) {
+	old, shard, _ := c.lockKey(key, true, lockShard[K, V])
+	defer unlockKey(old, shard)
+
+	return shard.getLocked(key)
+}
+
+// SetCloner makes every shard deep copy values with clone when mode says so
+func (c *ShardedCache[K, V]) SetCloner(clone Cloner[V], mode CloneMode) {
+	c.reshardMu.Lock()
+	defer c.reshardMu.Unlock()
+
+	c.cloner = cloning[V]{clone: clone, mode: mode}
+	c.set.Load().each(func(shard *Cache[K, V]) { shard.SetCloner(clone, mode) })
+}
+
+// Delete removes a value from the cache
+func (c *ShardedCache[K, V]) Delete(key K) bool {
+	old, shard, _ := c.lockKey(key, false, lockShard[K, V])
+	defer unlockKey(old, shard)
+
+	// A key still waiting to move is deleted where it is, rather than moved
+	// into a full shard at the cost of another entry
+	if old != nil {
+		if e, exists := old.items[key]; exists {
+			old.removeLocked(e)
+			shard.stats.IncrementDeletes()
+			return true
+		}
+	}
+	return shard.deleteLocked(key)
 }
 
-// GetStatistics returns aggregate statistics about the cache
+// GetStatistics returns aggregate statistics about the cache. Counters carry
+// on across a Reshard.
 func (c *ShardedCache[K, V]) GetStatistics() Statistics {
-	// Collect stats from all shards
-	aggregateStats := Statistics{}
+	c.reshardMu.Lock()
+	defer c.reshardMu.Unlock()
 
-	for _, shard := range c.shards {
+	// Collect stats from all shards, and from the shards retired so far
+	aggregateStats := *c.stats.Load()
+	set := c.set.Load()
+
+	set.each(func(shard *Cache[K, V]) {
 		shardStats := shard.GetStatistics()
 
 		// Aggregate counters
@@ -213,14 +302,53 @@ func (c *ShardedCache[K, V]) GetStatistics() Statistics {
 		aggregateStats.Hits += shardStats.Hits
 		aggregateStats.Misses += shardStats.Misses
 		aggregateStats.Evictions += shardStats.Evictions
+		aggregateStats.Deletes += shardStats.Deletes
+		aggregateStats.Invalidations += shardStats.Invalidations
 		aggregateStats.NeverReadCount += shardStats.NeverReadCount
 		aggregateStats.CurrentNeverRead += shardStats.CurrentNeverRead
-	}
+	})
 
 	// Calculate hit rate
 	if aggregateStats.Reads > 0 {
-		aggregateStats.AverageAccessCount = float64(aggregateStats.Hits) / float64(c.shardCount)
+		aggregateStats.AverageAccessCount = float64(aggregateStats.Hits) / float64(len(set.shards))
 	}
 
 	return aggregateStats
 }
+
+// Validate checks the internal invariants of the cache, like Cache.Validate
+func (c *RWMutexCache[K, V]) Validate() error {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	return validateLRU(c.items, &c.lrulist, c.entryLimt)
+}
+
+// debugCheckLocked panics if the cache is invalid; the caller must hold the write lock
+func (c *RWMutexCache[K, V]) debugCheckLocked() {
+	if err := validateLRU(c.items, &c.lrulist, c.entryLimt); err != nil {
+		panic(err)
+	}
+}
+
+// Validate checks the internal invariants of every shard, and that every key
+// lives in the shard it hashes to. During a Reshard it checks the old shards
+// too, and that no key is in both an old shard and a new one.
+func (c *ShardedCache[K, V]) Validate() error {
+	set := c.set.Load()
+	if set.old != nil {
+		if err := set.old.validate(); err != nil {
+			return fmt.Errorf("old %w", err)
+		}
+		for i, old := range set.old.shards {
+			old.mu.Lock()
+			err := validateMoved(old, set)
+			old.mu.Unlock()
+
+			if err != nil {
+				return fmt.Errorf("old shard %d: %w", i, err)
+			}
+		}
+	}
+	return set.validate()
+}
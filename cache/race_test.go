@@ -2,7 +2,9 @@ package cache
 
 import (
 	"fmt"
+	"slices"
 	"sync"
+	"sync/atomic"
 	"testing"
 )
 
@@ -36,6 +38,10 @@ func TestConcurrentAccess(t *testing.T) {
 
 	wg.Wait()
 
+	if err := cache.Validate(); err != nil {
+		t.Errorf("Cache invariants broken after concurrent use: %v", err)
+	}
+
 	// Verify we have sensible the statistics
 	stats := cache.GetStatistics()
 	expectedOps := numWorkers * opsPerWorker
@@ -94,6 +100,10 @@ func TestConcurrentRWMutexCache(t *testing.T) {
 
 	wg.Wait()
 
+	if err := cache.Validate(); err != nil {
+		t.Errorf("Cache invariants broken after concurrent use: %v", err)
+	}
+
 	// Verify we have sensible statistics
 	stats := cache.GetStatistics()
 	expectedOps := (numReaders + numWriters) * opsPerWorker
@@ -131,6 +141,10 @@ func TestConcurrentShardedCache(t *testing.T) {
 
 	wg.Wait()
 
+	if err := cache.Validate(); err != nil {
+		t.Errorf("Cache invariants broken after concurrent use: %v", err)
+	}
+
 	// Verify we have sensible statistics
 	stats := cache.GetStatistics()
 	expectedOps := numWorkers * opsPerWorker
@@ -139,3 +153,92 @@ func TestConcurrentShardedCache(t *testing.T) {
 			expectedOps, stats.Reads, stats.Writes)
 	}
 }
+
+// isolatedCache is what the value isolation tests need from an implementation
+type isolatedCache interface {
+	Put(key string, value []int) bool
+	Get(key string) (*[]int, bool)
+	GetValue(key string) ([]int, bool)
+	SetCloner(clone Cloner[[]int], mode CloneMode)
+}
+
+func isolatedCaches(t *testing.T) map[string]isolatedCache {
+	channel := NewChannelCache[string, []int](10)
+	t.Cleanup(func() { channel.Close() })
+
+	return map[string]isolatedCache{
+		"mutex":   NewCache[string, []int](10),
+		"rwmutex": NewRWMutexCache[string, []int](10),
+		"sharded": NewShardedCache[string, []int](10, 2),
+		"channel": channel,
+	}
+}
+
+// TestConcurrentValueIsolation has writers scribble over the values Get
+// returned while readers check the cached value never changes. Under -race,
+// any memory shared between a returned value and the entry is reported.
+func TestConcurrentValueIsolation(t *testing.T) {
+	for name, c := range isolatedCaches(t) {
+		t.Run(name, func(t *testing.T) {
+			c.SetCloner(slices.Clone[[]int], CloneAlways)
+			original := []int{1, 2, 3}
+			c.Put("key", original)
+			original[0] = -1 // Put cloned it, so the cache must not see this
+
+			var wg sync.WaitGroup
+			var corrupted atomic.Int32
+			for i := 0; i < 8; i++ {
+				wg.Add(1)
+				go func(workerID int) {
+					defer wg.Done()
+					for j := 0; j < 200; j++ {
+						if workerID%2 == 0 {
+							// Mutate through both the pointer and the copy
+							if v, found := c.Get("key"); found {
+								(*v)[0] = workerID
+								*v = nil
+							}
+							if v, found := c.GetValue("key"); found {
+								v[1] = workerID
+							}
+						} else if v, found := c.GetValue("key"); !found || !slices.Equal(v, []int{1, 2, 3}) {
+							corrupted.Add(1)
+						}
+					}
+				}(i)
+			}
+			wg.Wait()
+
+			if n := corrupted.Load(); n > 0 {
+				t.Errorf("Readers saw a changed or missing value %d times", n)
+			}
+		})
+	}
+}
+
+// TestGetReturnsCopy checks that even without a Cloner, changing the value Get
+// returned doesn't change the entry
+func TestGetReturnsCopy(t *testing.T) {
+	type point struct{ x, y int }
+
+	c := NewCache[string, point](10)
+	c.Put("p", point{1, 2})
+
+	var wg sync.WaitGroup
+	for i := 0; i < 8; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for j := 0; j < 100; j++ {
+				if v, found := c.Get("p"); found {
+					v.x = i // Would race with other readers if v pointed into the entry
+				}
+			}
+		}()
+	}
+	wg.Wait()
+
+	if v, _ := c.GetValue("p"); v != (point{1, 2}) {
+		t.Errorf("Expected the cached point to be unchanged, got %+v", v)
+	}
+}
@@ -0,0 +1,81 @@
+package cache
+
+import (
+	"bytes"
+	"encoding/binary"
+	"encoding/gob"
+	"errors"
+	"fmt"
+	"hash/crc32"
+	"io"
+)
+
+// Records are written to disk as a length-prefixed, CRC-checked gob payload:
+//
+//	[4 byte payload length][4 byte CRC32 of payload][payload]
+//
+// Keys and values are encoded with encoding/gob, so struct types must export
+// the fields that should survive the trip to disk.
+const recordHeaderSize = 8
+
+// maxRecordSize guards against allocating huge buffers for a corrupt length prefix
+const maxRecordSize = 1 << 30
+
+// errCorruptRecord is returned when a record fails its length or checksum check
+var errCorruptRecord = errors.New("cache: corrupt record")
+
+// diskRecord is the unit stored in segment and log files
+type diskRecord[K comparable, V any] struct {
+	Key     K
+	Value   V
+	Deleted bool // tombstone: the key was removed
+}
+
+// encodeRecord returns the framed, ready to write bytes of rec
+func encodeRecord[K comparable, V any](rec diskRecord[K, V]) ([]byte, error) {
+	var buf bytes.Buffer
+	buf.Write(make([]byte, recordHeaderSize)) // reserve room for the header
+
+	if err := gob.NewEncoder(&buf).Encode(rec); err != nil {
+		return nil, fmt.Errorf("cache: encoding record: %w", err)
+	}
+
+	framed := buf.Bytes()
+	payload := framed[recordHeaderSize:]
+	binary.LittleEndian.PutUint32(framed[0:4], uint32(len(payload)))
+	binary.LittleEndian.PutUint32(framed[4:8], crc32.ChecksumIEEE(payload))
+	return framed, nil
+}
+
+// readRecord reads one framed record from r and returns it along with the
+// number of bytes consumed. io.EOF is returned when r is exhausted exactly at a
+// record boundary; a partial or damaged record returns errCorruptRecord.
+func readRecord[K comparable, V any](r io.Reader) (diskRecord[K, V], int64, error) {
+	var rec diskRecord[K, V]
+
+	var header [recordHeaderSize]byte
+	if n, err := io.ReadFull(r, header[:]); err != nil {
+		if err == io.EOF {
+			return rec, 0, io.EOF
+		}
+		return rec, int64(n), errCorruptRecord
+	}
+
+	size := binary.LittleEndian.Uint32(header[0:4])
+	if size == 0 || size > maxRecordSize {
+		return rec, recordHeaderSize, errCorruptRecord
+	}
+
+	payload := make([]byte, size)
+	if n, err := io.ReadFull(r, payload); err != nil {
+		return rec, recordHeaderSize + int64(n), errCorruptRecord
+	}
+	if crc32.ChecksumIEEE(payload) != binary.LittleEndian.Uint32(header[4:8]) {
+		return rec, recordHeaderSize + int64(size), errCorruptRecord
+	}
+
+	if err := gob.NewDecoder(bytes.NewReader(payload)).Decode(&rec); err != nil {
+		return rec, recordHeaderSize + int64(size), errCorruptRecord
+	}
+	return rec, recordHeaderSize + int64(size), nil
+}
@@ -0,0 +1,321 @@
+package cache
+
+import (
+	"errors"
+	"fmt"
+	"runtime"
+)
+
+// reshardBatch is how many entries the mover moves out of an old shard per
+// lock hold, which bounds how long it blocks that shard's keys
+const reshardBatch = 64
+
+// ErrResharding is returned by Reshard while an earlier Reshard is still
+// moving entries
+var ErrResharding = errors.New("cache: a reshard is already in progress")
+
+// shardSet is the set of shards a ShardedCache routes keys to. While a
+// Reshard is moving entries, old is the set they're moving out of.
+type shardSet[K comparable, V any] struct {
+	shards []*Cache[K, V]
+	mask   int
+	old    *shardSet[K, V]
+}
+
+// newShardSet splits entryLimit over shardCount shards, rounded up to a
+// power of two
+func newShardSet[K comparable, V any](entryLimit int, shardCount int) *shardSet[K, V] {
+	// Make sure shardCount is a power of 2 for efficient modulo
+	if shardCount&(shardCount-1) != 0 {
+		// Find next power of 2
+		shardCount--
+		shardCount |= shardCount >> 1
+		shardCount |= shardCount >> 2
+		shardCount |= shardCount >> 4
+		shardCount |= shardCount >> 8
+		shardCount |= shardCount >> 16
+		shardCount++
+	}
+
+	entriesPerShard := entryLimit / shardCount
+	if entriesPerShard < 1 {
+		entriesPerShard = 1
+	}
+
+	set := &shardSet[K, V]{
+		shards: make([]*Cache[K, V], shardCount),
+		mask:   shardCount - 1,
+	}
+	for i := 0; i < shardCount; i++ {
+		set.shards[i] = NewCache[K, V](entriesPerShard)
+	}
+	return set
+}
+
+// shardFor returns the shard of the set that key hashes to
+func (s *shardSet[K, V]) shardFor(key K) *Cache[K, V] {
+	return s.shards[anyToHash(key)&s.mask]
+}
+
+// each calls fn for every shard of the old set, if any, and then for every
+// shard of the set. Entries only move from old shards to new ones, so a pass
+// in this order sees each of them at least once.
+func (s *shardSet[K, V]) each(fn func(shard *Cache[K, V])) {
+	if s.old != nil {
+		for _, shard := range s.old.shards {
+			fn(shard)
+		}
+	}
+	for _, shard := range s.shards {
+		fn(shard)
+	}
+}
+
+// lockShard acquires a shard's lock, recording any wait in its contention
+// statistics
+func lockShard[K comparable, V any](shard *Cache[K, V]) error {
+	shard.yield(yieldBeforeLock)
+	shard.contention.lock(&shard.mu)
+	return nil
+}
+
+// lockKey locks the shard serving key with lock and returns it. While a
+// Reshard is moving entries, it first locks the old shard key hashed to,
+// which it returns locked as well; with move set, key is moved from there
+// into the shard, so the caller finds it in one place. An operation that
+// picked a shard just before a Reshard retired it retries with the new set.
+func (c *ShardedCache[K, V]) lockKey(key K, move bool, lock func(shard *Cache[K, V]) error) (old, shard *Cache[K, V], err error) {
+	for {
+		set := c.set.Load()
+		old, shard = nil, set.shardFor(key)
+
+		if set.old != nil {
+			old = set.old.shardFor(key)
+			if err := lock(old); err != nil {
+				return nil, nil, err
+			}
+		}
+		if err := lock(shard); err != nil {
+			if old != nil {
+				old.mu.Unlock()
+			}
+			return nil, nil, err
+		}
+
+		if !shard.retired {
+			if old != nil && move {
+				moveLocked(old, shard, key, true)
+			}
+			return old, shard, nil
+		}
+		unlockKey(old, shard)
+	}
+}
+
+// unlockKey releases the locks taken by lockKey
+func unlockKey[K comparable, V any](old, shard *Cache[K, V]) {
+	shard.mu.Unlock()
+	if old != nil {
+		old.mu.Unlock()
+	}
+}
+
+// moveLocked moves key, if it's still in the old shard, to the back of its
+// new shard, behind every entry used since the reshard began. If the new
+// shard is full, makeRoom evicts its least recently used entry, for a key
+// that's about to be used; otherwise the moved entry is the one evicted.
+// Tags move with the entry. The caller must hold both locks.
+func moveLocked[K comparable, V any](old, shard *Cache[K, V], key K, makeRoom bool) {
+	e, exists := old.items[key]
+	if !exists {
+		return
+	}
+
+	var tags []string
+	if old.tags != nil {
+		tags = old.tags.tags[key]
+	}
+	old.removeLocked(e)
+
+	if len(shard.items) >= shard.entryLimit {
+		if makeRoom {
+			shard.evictLocked()
+		} else {
+			if !e.readAfterWrite {
+				shard.stats.IncrementNeverRead()
+			}
+			shard.stats.IncrementEvictions()
+			if shard.onEvict != nil {
+				shard.onEvict(e.key, e.value)
+			}
+			return
+		}
+	}
+
+	shard.items[key] = e
+	shard.lruList.addToBack(e)
+	if len(tags) > 0 {
+		if shard.tags == nil {
+			shard.tags = newTagIndex[K]()
+		}
+		shard.tags.set(key, tags)
+	}
+	if shard.prefixes != nil {
+		shard.prefixes.insert(key)
+	}
+}
+
+// Reshard changes the number of shards to newCount, rounded up to a power of
+// two as in NewShardedCache, without stopping the cache. The new shards serve
+// every operation from the moment Reshard returns. Until its entries have
+// moved, an operation on a key also locks the key's old shard and moves the
+// key over first, so reads never miss an entry that's still on its way.
+//
+// A background goroutine moves the remaining entries, most recently used
+// first, taking a batch from each old shard in turn and holding an old
+// shard's lock for at most one batch. Moved entries line up behind the ones
+// used since the reshard began, so each new shard keeps roughly the LRU order
+// the old shards had. The returned channel is closed once every entry has
+// moved. Statistics carry on across the reshard.
+//
+// Reshard returns ErrResharding if an earlier Reshard is still moving
+// entries.
+func (c *ShardedCache[K, V]) Reshard(newCount int) (<-chan struct{}, error) {
+	set, done, err := c.switchShards(newCount)
+	if err == nil && set != nil {
+		go c.migrate(set, done)
+	}
+	return done, err
+}
+
+// switchShards routes every key to a new set of newCount shards and retires
+// the current ones, leaving their entries for migrate to move. It returns a
+// nil set and a closed channel if the shard count doesn't change.
+func (c *ShardedCache[K, V]) switchShards(newCount int) (*shardSet[K, V], chan struct{}, error) {
+	c.reshardMu.Lock()
+	defer c.reshardMu.Unlock()
+
+	if c.resharding {
+		return nil, nil, ErrResharding
+	}
+
+	done := make(chan struct{})
+	current := c.set.Load()
+	set := newShardSet[K, V](c.entryLimit, newCount)
+	if len(set.shards) == len(current.shards) {
+		close(done)
+		return nil, done, nil
+	}
+
+	// The new shards aren't shared yet, so they're configured without locks
+	for _, shard := range set.shards {
+		shard.onEvict = c.onEvict
+		shard.cloner = c.cloner
+		shard.sched = c.sched
+	}
+	set.old = current
+
+	// Holding every old lock across the switch means no operation is halfway
+	// through an old shard. Those waiting for one find it retired and retry.
+	for _, shard := range current.shards {
+		shard.mu.Lock()
+	}
+	c.set.Store(set)
+	for _, shard := range current.shards {
+		shard.retired = true
+		shard.mu.Unlock()
+	}
+
+	c.resharding = true
+	return set, done, nil
+}
+
+// migrate moves every entry out of the old shards of set, then drops them,
+// folding their statistics into the cache's
+func (c *ShardedCache[K, V]) migrate(set *shardSet[K, V], done chan struct{}) {
+	pending := append([]*Cache[K, V](nil), set.old.shards...)
+	for len(pending) > 0 {
+		remaining := pending[:0]
+		for _, old := range pending {
+			if moveBatch(set, old) {
+				remaining = append(remaining, old)
+			}
+		}
+		pending = remaining
+		runtime.Gosched()
+	}
+
+	c.reshardMu.Lock()
+	retired := *c.stats.Load()
+	for _, old := range set.old.shards {
+		s := old.GetStatistics()
+		retired.Reads += s.Reads
+		retired.Writes += s.Writes
+		retired.Hits += s.Hits
+		retired.Misses += s.Misses
+		retired.Evictions += s.Evictions
+		retired.Deletes += s.Deletes
+		retired.Invalidations += s.Invalidations
+		retired.NeverReadCount += s.NeverReadCount
+	}
+	c.stats.Store(&retired)
+	c.set.Store(&shardSet[K, V]{shards: set.shards, mask: set.mask})
+	c.resharding = false
+	c.reshardMu.Unlock()
+
+	close(done)
+}
+
+// moveBatch moves up to reshardBatch entries from the front of an old shard
+// to the new shards of set, and returns whether any are left
+func moveBatch[K comparable, V any](set *shardSet[K, V], old *Cache[K, V]) bool {
+	old.mu.Lock()
+	defer old.mu.Unlock()
+
+	for n := 0; n < reshardBatch && old.lruList.head != nil; n++ {
+		key := old.lruList.head.key
+		shard := set.shardFor(key)
+		shard.mu.Lock()
+		moveLocked(old, shard, key, false)
+		shard.mu.Unlock()
+	}
+	return old.lruList.head != nil
+}
+
+// validate checks every shard of the set, and that its keys hash to it
+func (s *shardSet[K, V]) validate() error {
+	for i, shard := range s.shards {
+		shard.mu.Lock()
+		err := shard.validateLocked()
+		if err == nil {
+			for key := range shard.items {
+				if s.shardFor(key) != shard {
+					err = fmt.Errorf("%w: key %v is in the wrong shard", errInvalid, key)
+					break
+				}
+			}
+		}
+		shard.mu.Unlock()
+
+		if err != nil {
+			return fmt.Errorf("shard %d: %w", i, err)
+		}
+	}
+	return nil
+}
+
+// validateMoved checks that no key of an old shard has a copy in the new
+// shards of set; the caller must hold old.mu
+func validateMoved[K comparable, V any](old *Cache[K, V], set *shardSet[K, V]) error {
+	for key := range old.items {
+		shard := set.shardFor(key)
+		shard.mu.Lock()
+		_, moved := shard.items[key]
+		shard.mu.Unlock()
+
+		if moved {
+			return fmt.Errorf("%w: key %v is in an old shard and a new one", errInvalid, key)
+		}
+	}
+	return nil
+}
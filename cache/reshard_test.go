@@ -0,0 +1,189 @@
+package cache
+
+import (
+	"context"
+	"math/rand/v2"
+	"sync"
+	"testing"
+)
+
+func TestReshardKeepsEntries(t *testing.T) {
+	for _, count := range []int{32, 2} {
+		c := NewShardedCache[int, int](1000, 8)
+		for key := 0; key < 200; key++ {
+			c.Put(key, key)
+		}
+
+		done, err := c.Reshard(count)
+		if err != nil {
+			t.Fatal(err)
+		}
+		<-done
+
+		if n := len(c.set.Load().shards); n != count {
+			t.Errorf("Expected %d shards, got %d", count, n)
+		}
+		for key := 0; key < 200; key++ {
+			if value, found := c.GetValue(key); !found || value != key {
+				t.Errorf("Expected key %d to survive resharding to %d shards", key, count)
+			}
+		}
+		if err := c.Validate(); err != nil {
+			t.Error(err)
+		}
+	}
+}
+
+func TestReshardDuringMigration(t *testing.T) {
+	c := NewShardedCache[int, int](1000, 4)
+	for key := 0; key < 100; key++ {
+		c.PutWithTags(key, key, "all")
+	}
+
+	// Switch to the new shards but move nothing in the background, so every
+	// key is still in an old shard
+	set, done, err := c.switchShards(16)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := c.Reshard(2); err != ErrResharding {
+		t.Errorf("Expected a second Reshard to fail with ErrResharding, got %v", err)
+	}
+
+	if value, found := c.GetValue(1); !found || value != 1 {
+		t.Error("Expected a Get to find a key that hasn't moved yet")
+	}
+	if _, stale := set.old.shardFor(1).items[1]; stale || set.shardFor(1).Len() != 1 {
+		t.Error("Expected the Get to move its key to the new shard")
+	}
+	if !c.Delete(2) || c.Delete(2) {
+		t.Error("Expected the first Delete of a key that hasn't moved to succeed and the second to fail")
+	}
+	c.Put(3, 30)
+	if value, _, _ := c.GetCtx(context.Background(), 3); *value != 30 {
+		t.Errorf("Expected the new value of key 3, got %d", *value)
+	}
+	if err := c.Validate(); err != nil {
+		t.Error(err)
+	}
+
+	c.migrate(set, done)
+	<-done
+
+	if c.set.Load().old != nil {
+		t.Error("Expected the old shards to be dropped once every entry moved")
+	}
+	if removed := c.InvalidateTag("all"); removed != 99 {
+		t.Errorf("Expected tags to move with their entries and 99 to be invalidated, got %d", removed)
+	}
+
+	stats := c.GetStatistics()
+	if stats.Writes != 101 || stats.Reads != 2 || stats.Hits != 2 || stats.Deletes != 1 || stats.Invalidations != 99 {
+		t.Errorf("Expected statistics to carry on across the reshard, got %+v", stats)
+	}
+}
+
+func TestReshardKeepsLRUOrder(t *testing.T) {
+	c := NewShardedCache[int, int](8, 1)
+	for key := 0; key < 8; key++ {
+		c.Put(key, key)
+	}
+	for key := 0; key < 4; key++ {
+		c.Get(key)
+	}
+	recency := []int{3, 2, 1, 0, 7, 6, 5, 4}
+
+	done, err := c.Reshard(2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	<-done
+
+	// Each new shard holds 4 entries: the most recently used of the keys it
+	// gets, in the order they were used before
+	set := c.set.Load()
+	evicted := 0
+	for i, shard := range set.shards {
+		var want []int
+		for _, key := range recency {
+			if set.shardFor(key) == shard {
+				want = append(want, key)
+			}
+		}
+		if len(want) > 4 {
+			evicted += len(want) - 4
+			want = want[:4]
+		}
+
+		var got []int
+		for e := shard.lruList.head; e != nil; e = e.next {
+			got = append(got, e.key)
+		}
+		if len(got) != len(want) {
+			t.Errorf("shard %d: expected keys %v, got %v", i, want, got)
+			continue
+		}
+		for j := range want {
+			if got[j] != want[j] {
+				t.Errorf("shard %d: expected keys %v, got %v", i, want, got)
+				break
+			}
+		}
+	}
+
+	if stats := c.GetStatistics(); stats.Evictions != int64(evicted) {
+		t.Errorf("Expected %d evictions of entries the new shards had no room for, got %d", evicted, stats.Evictions)
+	}
+}
+
+func TestReshardConcurrent(t *testing.T) {
+	c := NewShardedCache[int, int](500, 4)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	var wg sync.WaitGroup
+	for i := 0; i < 8; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			rng := rand.New(rand.NewPCG(uint64(i), 0))
+			for ctx.Err() == nil {
+				key := rng.IntN(1000)
+				switch rng.IntN(6) {
+				case 0:
+					c.Put(key, key)
+				case 1:
+					c.Delete(key)
+				case 2:
+					c.InvalidateTag("odd")
+				case 3:
+					c.PutWithTags(key, key, "odd")
+				default:
+					if value, found := c.GetValue(key); found && value != key {
+						t.Errorf("Expected key %d to hold %d, got %d", key, key, value)
+					}
+				}
+			}
+		}()
+	}
+
+	for _, count := range []int{16, 2, 8, 1, 4} {
+		done, err := c.Reshard(count)
+		if err != nil {
+			t.Fatal(err)
+		}
+		<-done
+		if err := c.Validate(); err != nil {
+			t.Error(err)
+		}
+	}
+	cancel()
+	wg.Wait()
+
+	if err := c.Validate(); err != nil {
+		t.Error(err)
+	}
+	stats := c.GetStatistics()
+	if stats.Reads != stats.Hits+stats.Misses {
+		t.Errorf("Expected every read to be a hit or a miss, got %+v", stats)
+	}
+}
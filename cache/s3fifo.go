@@ -0,0 +1,317 @@
+package cache
+
+import (
+	"fmt"
+	"sync"
+)
+
+const (
+	// s3fifoSmallRatio is the share of the entry limit the small queue holds
+	s3fifoSmallRatio = 0.1
+
+	// s3fifoMaxFreq caps the read count of an entry
+	s3fifoMaxFreq = 3
+)
+
+// S3FIFOCache implements a thread safe cache with the S3-FIFO eviction
+// policy. New entries go into a small FIFO holding a tenth of the cache; an
+// entry that reaches its tail without being read is evicted and its key
+// remembered in a ghost queue, while one that was read moves into the main
+// FIFO. The main FIFO reinserts entries that were read since it last looked
+// at them and evicts the rest. A key written again while remembered goes
+// straight into the main FIFO. As with SIEVECache, reads only count up the
+// entry's frequency atomically, so Get needs just a read lock.
+type S3FIFOCache[K comparable, V any] struct {
+	mu         sync.RWMutex
+	entryLimit int
+	smallLimit int
+	items      map[K]*fifoEntry[K, V]
+	small      fifoList[K, V]
+	main       fifoList[K, V]
+	ghosts     *ghostQueue[K] // keys recently evicted from small
+	stats      *Statistics
+	cloner     cloning[V] // deep copies values on Put and Get, set by SetCloner
+}
+
+// NewS3FIFOCache creates an S3-FIFO cache with the given entry limit. The
+// ghost queue remembers as many keys as the main FIFO holds entries.
+func NewS3FIFOCache[K comparable, V any](entryLimit int) *S3FIFOCache[K, V] {
+	smallLimit := max(1, int(float64(entryLimit)*s3fifoSmallRatio))
+	return &S3FIFOCache[K, V]{
+		entryLimit: entryLimit,
+		smallLimit: smallLimit,
+		items:      make(map[K]*fifoEntry[K, V]),
+		ghosts:     newGhostQueue[K](max(1, entryLimit-smallLimit)),
+		stats:      newStatistics(),
+	}
+}
+
+// SetCloner makes the cache deep copy values with clone when mode says so
+func (c *S3FIFOCache[K, V]) SetCloner(clone Cloner[V], mode CloneMode) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.cloner = cloning[V]{clone: clone, mode: mode}
+}
+
+// Put adds the value to the cache, and returns a boolean to indicate whether
+// a value already existed in the cache for that key
+func (c *S3FIFOCache[K, V]) Put(key K, value V) bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if debugValidate {
+		defer c.debugCheckLocked()
+	}
+
+	c.stats.IncrementWrites()
+	value = c.cloner.onPut(value)
+
+	if e, exists := c.items[key]; exists {
+		e.value = value
+		e.readAfterWrite.Store(false)
+		return true
+	}
+
+	if len(c.items) >= c.entryLimit && len(c.items) > 0 {
+		c.evictLocked()
+	}
+
+	e := &fifoEntry[K, V]{key: key, value: value}
+	c.items[key] = e
+	if c.ghosts.remove(key) {
+		e.segment = segmentS3Main
+		c.main.addToFront(e)
+	} else {
+		e.segment = segmentS3Small
+		c.small.addToFront(e)
+	}
+	return false
+}
+
+// evictLocked evicts one entry, from the small FIFO while it's over its share
+// or the main FIFO is empty, and from the main FIFO otherwise. Entries that
+// were read are moved on rather than evicted, so it may take a few steps.
+func (c *S3FIFOCache[K, V]) evictLocked() {
+	for {
+		if c.small.len >= c.smallLimit || c.main.len == 0 {
+			if c.evictSmallLocked() {
+				return
+			}
+		} else {
+			c.evictMainLocked()
+			return
+		}
+	}
+}
+
+// evictSmallLocked looks at the tail of the small FIFO. An entry that was
+// read moves to the main FIFO, and evictSmallLocked returns false; otherwise
+// the entry is evicted and its key remembered.
+func (c *S3FIFOCache[K, V]) evictSmallLocked() bool {
+	e := c.small.tail
+	c.small.remove(e)
+
+	if e.freq.Load() > 0 {
+		e.freq.Store(0)
+		e.segment = segmentS3Main
+		c.main.addToFront(e)
+		return false
+	}
+
+	c.ghosts.add(e.key)
+	c.dropLocked(e)
+	return true
+}
+
+// evictMainLocked reinserts entries from the tail of the main FIFO, counting
+// down their frequency, until it finds one that wasn't read and evicts it
+func (c *S3FIFOCache[K, V]) evictMainLocked() {
+	for {
+		e := c.main.tail
+		c.main.remove(e)
+
+		if f := e.freq.Load(); f > 0 {
+			e.freq.Store(f - 1)
+			c.main.addToFront(e)
+			continue
+		}
+
+		c.dropLocked(e)
+		return
+	}
+}
+
+// dropLocked removes an evicted entry from the items map and counts it
+func (c *S3FIFOCache[K, V]) dropLocked(e *fifoEntry[K, V]) {
+	delete(c.items, e.key)
+	if !e.readAfterWrite.Load() {
+		c.stats.IncrementNeverRead()
+	}
+	c.stats.IncrementEvictions()
+}
+
+// Get returns a copy of the value associated with key
+func (c *S3FIFOCache[K, V]) Get(key K) (*V, bool) {
+	value, found := c.GetValue(key)
+	if !found {
+		return nil, false
+	}
+	return &value, true
+}
+
+// GetValue is Get returning the copy of the value itself
+func (c *S3FIFOCache[K, V]) GetValue(key K) (V, bool) {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	c.stats.IncrementReads()
+
+	e, exists := c.items[key]
+	if !exists {
+		c.stats.IncrementMisses()
+		var zero V
+		return zero, false
+	}
+
+	e.touch(s3fifoMaxFreq)
+	c.stats.IncrementHits()
+	return c.cloner.onGet(e.value), true
+}
+
+// Delete removes the key from the cache, and returns a boolean to indicate
+// whether a value existed for that key. The key is forgotten from the ghost
+// queue too.
+func (c *S3FIFOCache[K, V]) Delete(key K) bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if debugValidate {
+		defer c.debugCheckLocked()
+	}
+
+	c.ghosts.remove(key)
+
+	e, exists := c.items[key]
+	if !exists {
+		return false
+	}
+
+	if e.segment == segmentS3Main {
+		c.main.remove(e)
+	} else {
+		c.small.remove(e)
+	}
+	delete(c.items, key)
+	c.stats.IncrementDeletes()
+
+	return true
+}
+
+// GetStatistics returns consistent statistics about the cache
+func (c *S3FIFOCache[K, V]) GetStatistics() Statistics {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	stats := *c.stats
+	fifoStatistics(c.items, &stats)
+	return stats
+}
+
+// ghostQueue remembers the most recent keys added to it, up to a limit,
+// without their values. Keys sit in a ring in the order they were added; the
+// map holds the position of each remembered key, so a removed key's slot is
+// simply skipped when the ring wraps around to it.
+type ghostQueue[K comparable] struct {
+	ring  []K
+	next  uint64 // total keys added; the next goes in ring[next%len(ring)]
+	index map[K]uint64
+}
+
+func newGhostQueue[K comparable](limit int) *ghostQueue[K] {
+	return &ghostQueue[K]{
+		ring:  make([]K, limit),
+		index: make(map[K]uint64, limit),
+	}
+}
+
+// add remembers key, forgetting the oldest key if the queue is full
+func (g *ghostQueue[K]) add(key K) {
+	slot := g.next % uint64(len(g.ring))
+	if g.next >= uint64(len(g.ring)) {
+		old := g.ring[slot]
+		if pos, ok := g.index[old]; ok && pos == g.next-uint64(len(g.ring)) {
+			delete(g.index, old)
+		}
+	}
+
+	g.ring[slot] = key
+	g.index[key] = g.next
+	g.next++
+}
+
+// remove forgets key, and returns whether it was remembered
+func (g *ghostQueue[K]) remove(key K) bool {
+	if _, ok := g.index[key]; !ok {
+		return false
+	}
+	delete(g.index, key)
+	return true
+}
+
+// Validate checks the internal invariants of the cache: every item is on the
+// queue its segment says, remembered keys aren't cached, and no limit is
+// exceeded
+func (c *S3FIFOCache[K, V]) Validate() error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	return c.validateLocked()
+}
+
+func (c *S3FIFOCache[K, V]) validateLocked() error {
+	if len(c.items) > c.entryLimit {
+		return fmt.Errorf("%w: %d entries exceed the limit of %d", errInvalid, len(c.items), c.entryLimit)
+	}
+	if n := c.small.len + c.main.len; n != len(c.items) {
+		return fmt.Errorf("%w: %d items but the queues count %d entries", errInvalid, len(c.items), n)
+	}
+	if err := c.small.validateSegment(c.items, segmentS3Small, s3fifoMaxFreq); err != nil {
+		return err
+	}
+	if err := c.main.validateSegment(c.items, segmentS3Main, s3fifoMaxFreq); err != nil {
+		return err
+	}
+	if err := c.ghosts.validate(); err != nil {
+		return err
+	}
+	for key := range c.ghosts.index {
+		if _, cached := c.items[key]; cached {
+			return fmt.Errorf("%w: key %v is both cached and remembered", errInvalid, key)
+		}
+	}
+	return nil
+}
+
+// debugCheckLocked panics if the cache is invalid; the caller must hold c.mu
+func (c *S3FIFOCache[K, V]) debugCheckLocked() {
+	if err := c.validateLocked(); err != nil {
+		panic(err)
+	}
+}
+
+// validate checks that every remembered key is in the ring at the position
+// the index has for it, among the last keys added
+func (g *ghostQueue[K]) validate() error {
+	size := uint64(len(g.ring))
+	if len(g.index) > len(g.ring) {
+		return fmt.Errorf("%w: %d remembered keys exceed the limit of %d", errInvalid, len(g.index), size)
+	}
+	for key, pos := range g.index {
+		if pos >= g.next || g.next-pos > size {
+			return fmt.Errorf("%w: remembered key %v has position %d outside the last %d of %d", errInvalid, key, pos, size, g.next)
+		}
+		if g.ring[pos%size] != key {
+			return fmt.Errorf("%w: remembered key %v is not in its ring slot", errInvalid, key)
+		}
+	}
+	return nil
+}
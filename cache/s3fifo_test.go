@@ -0,0 +1,114 @@
+package cache
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestS3FIFOCacheQueues(t *testing.T) {
+	// The small queue holds 1 entry and the ghost queue 9 keys
+	c := NewS3FIFOCache[int, int](10)
+
+	for i := 0; i < 10; i++ {
+		c.Put(i, i)
+	}
+	c.Get(0)
+
+	// 0 was read while in the small queue, so it moves to main; 1 wasn't and
+	// is evicted, to be remembered
+	c.Put(10, 10)
+	if e := c.items[0]; e == nil || e.segment != segmentS3Main {
+		t.Fatal("Key 0 should have moved to the main queue")
+	}
+	if _, found := c.Get(1); found {
+		t.Fatal("Key 1 should have been evicted")
+	}
+	if _, remembered := c.ghosts.index[1]; !remembered {
+		t.Fatal("Key 1 should be remembered in the ghost queue")
+	}
+
+	// Writing 1 again brings it straight into main
+	if c.Put(1, 100) {
+		t.Error("A remembered key has no value, so Put should report it as new")
+	}
+	if e := c.items[1]; e == nil || e.segment != segmentS3Main {
+		t.Error("Key 1 should have come back into the main queue")
+	}
+	if err := c.Validate(); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestS3FIFOCacheScanResistance(t *testing.T) {
+	c := NewS3FIFOCache[int, int](10)
+
+	// Make 0 to 4 popular, then scan a hundred keys seen once
+	for i := 0; i < 5; i++ {
+		c.Put(i, i)
+		c.Get(i)
+	}
+	for i := 100; i < 200; i++ {
+		c.Put(i, i)
+	}
+
+	for i := 0; i < 5; i++ {
+		if _, found := c.GetValue(i); !found {
+			t.Errorf("Popular key %d should have survived the scan", i)
+		}
+	}
+	if err := c.Validate(); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestGhostQueue(t *testing.T) {
+	g := newGhostQueue[int](3)
+	for i := 0; i < 5; i++ {
+		g.add(i)
+	}
+
+	// Only the last 3 keys are remembered
+	for i := 0; i < 5; i++ {
+		if _, remembered := g.index[i]; remembered != (i >= 2) {
+			t.Errorf("Key %d remembered is %v", i, remembered)
+		}
+	}
+
+	// A removed key's slot is skipped, and doesn't forget the key added after
+	if !g.remove(3) || g.remove(3) {
+		t.Error("Expected the first remove of 3 to succeed and the second to fail")
+	}
+	g.add(5)
+	g.add(3)
+	if len(g.index) != 3 {
+		t.Errorf("Expected 3 remembered keys, got %v", g.index)
+	}
+	if err := g.validate(); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestS3FIFOCacheValidate(t *testing.T) {
+	corruptions := map[string]func(c *S3FIFOCache[int, int]){
+		"wrong segment": func(c *S3FIFOCache[int, int]) {
+			c.items[19].segment = segmentS3Main
+		},
+		"cached ghost": func(c *S3FIFOCache[int, int]) {
+			c.ghosts.add(19)
+		},
+		"frequency overflow": func(c *S3FIFOCache[int, int]) {
+			c.items[19].freq.Store(s3fifoMaxFreq + 1)
+		},
+	}
+
+	for name, corrupt := range corruptions {
+		c := NewS3FIFOCache[int, int](10)
+		for i := 0; i < 20; i++ {
+			c.Put(i, i)
+		}
+		corrupt(c)
+		if err := c.Validate(); !errors.Is(err, errInvalid) {
+			t.Errorf("%s: expected an invariant violation, got %v", name, err)
+		}
+	}
+}
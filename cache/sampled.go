@@ -0,0 +1,310 @@
+package cache
+
+import (
+	"fmt"
+	"math/rand/v2"
+	"sort"
+	"sync"
+)
+
+const (
+	// defaultSamples is how many keys SampledCache looks at per eviction,
+	// as Redis does by default
+	defaultSamples = 5
+
+	// evictionPoolSize is how many of the idlest sampled keys are kept
+	// between evictions
+	evictionPoolSize = 16
+)
+
+// sampledEntry is an entry of a SampledCache. It records when it was last
+// used on the cache's access clock instead of linking into a list.
+type sampledEntry[K comparable, V any] struct {
+	key            K
+	value          V
+	lastAccess     uint32 // access clock at the last Get or Put
+	accessCount    int32
+	readAfterWrite bool
+}
+
+// poolCandidate is a sampled key waiting in the eviction pool
+type poolCandidate[K comparable] struct {
+	key        K
+	lastAccess uint32 // when it was sampled, to notice it was used since
+}
+
+// SampledCache implements a thread safe approximate LRU cache in the style of
+// Redis. Instead of keeping entries in recency order, every entry stamps a
+// 32-bit access clock when it's used, and an eviction samples a few random
+// entries and evicts the one idle the longest. The idlest candidates of past
+// samples wait in an eviction pool, so each eviction picks from more than
+// the keys it sampled itself.
+//
+// That costs 4 bytes per entry rather than two list pointers, Get never
+// relinks anything, and the entries live in one slice that has no pointers
+// for the garbage collector with pointer-free keys and values. The price is
+// that the entry evicted is only probably among the least recently used.
+// The clock wraps after 2^32 accesses, making entries idle for longer than
+// that look recently used.
+type SampledCache[K comparable, V any] struct {
+	mu         sync.Mutex
+	entryLimit int
+	samples    int
+	index      map[K]int32 // slot of every key in entries
+	entries    []sampledEntry[K, V]
+	clock      uint32             // ticks on every access
+	pool       []poolCandidate[K] // idlest candidates, least idle first
+	rng        *rand.Rand         // picks the sampled entries
+	stats      *Statistics
+	cloner     cloning[V] // deep copies values on Put and Get, set by SetCloner
+}
+
+// NewSampledCache creates an approximate LRU cache with the given entry
+// limit that samples the given number of keys per eviction; zero or less
+// selects 5. More samples evict closer to exact LRU and take longer.
+func NewSampledCache[K comparable, V any](entryLimit, samples int) *SampledCache[K, V] {
+	if samples <= 0 {
+		samples = defaultSamples
+	}
+	return &SampledCache[K, V]{
+		entryLimit: entryLimit,
+		samples:    samples,
+		index:      make(map[K]int32),
+		pool:       make([]poolCandidate[K], 0, evictionPoolSize),
+		rng:        rand.New(rand.NewPCG(rand.Uint64(), rand.Uint64())),
+		stats:      newStatistics(),
+	}
+}
+
+// SetCloner makes the cache deep copy values with clone when mode says so
+func (c *SampledCache[K, V]) SetCloner(clone Cloner[V], mode CloneMode) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.cloner = cloning[V]{clone: clone, mode: mode}
+}
+
+// tickLocked advances the access clock and returns the new time
+func (c *SampledCache[K, V]) tickLocked() uint32 {
+	c.clock++
+	return c.clock
+}
+
+// Put adds the value to the cache, and returns a boolean to indicate whether
+// a value already existed in the cache for that key
+func (c *SampledCache[K, V]) Put(key K, value V) bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if debugValidate {
+		defer c.debugCheckLocked()
+	}
+
+	c.stats.IncrementWrites()
+	value = c.cloner.onPut(value)
+
+	if i, exists := c.index[key]; exists {
+		e := &c.entries[i]
+		e.value = value
+		e.readAfterWrite = false
+		e.lastAccess = c.tickLocked()
+		return true
+	}
+
+	if len(c.entries) >= c.entryLimit && len(c.entries) > 0 {
+		c.evictLocked()
+	}
+
+	c.index[key] = int32(len(c.entries))
+	c.entries = append(c.entries, sampledEntry[K, V]{key: key, value: value, lastAccess: c.tickLocked()})
+	return false
+}
+
+// evictLocked samples entries into the eviction pool and evicts the idlest
+// candidate that's still cached and unused since it was sampled
+func (c *SampledCache[K, V]) evictLocked() {
+	for {
+		c.populatePoolLocked()
+
+		for len(c.pool) > 0 {
+			best := c.pool[len(c.pool)-1]
+			c.pool = c.pool[:len(c.pool)-1]
+
+			i, exists := c.index[best.key]
+			if !exists || c.entries[i].lastAccess != best.lastAccess {
+				continue
+			}
+
+			if !c.entries[i].readAfterWrite {
+				c.stats.IncrementNeverRead()
+			}
+			c.removeLocked(i)
+			c.stats.IncrementEvictions()
+			return
+		}
+	}
+}
+
+// populatePoolLocked samples entries at random and adds each to the pool if
+// there's room or it has been idle longer than the least idle candidate
+func (c *SampledCache[K, V]) populatePoolLocked() {
+	idle := func(lastAccess uint32) uint32 {
+		return c.clock - lastAccess
+	}
+
+	for n := 0; n < c.samples; n++ {
+		e := &c.entries[c.rng.IntN(len(c.entries))]
+		if c.pooledLocked(e.key) {
+			continue
+		}
+		if len(c.pool) == evictionPoolSize {
+			if idle(e.lastAccess) <= idle(c.pool[0].lastAccess) {
+				continue
+			}
+			c.pool = append(c.pool[:0], c.pool[1:]...)
+		}
+
+		at := sort.Search(len(c.pool), func(j int) bool {
+			return idle(c.pool[j].lastAccess) > idle(e.lastAccess)
+		})
+		c.pool = append(c.pool, poolCandidate[K]{})
+		copy(c.pool[at+1:], c.pool[at:])
+		c.pool[at] = poolCandidate[K]{key: e.key, lastAccess: e.lastAccess}
+	}
+}
+
+// pooledLocked reports whether key is already a candidate
+func (c *SampledCache[K, V]) pooledLocked(key K) bool {
+	for _, candidate := range c.pool {
+		if candidate.key == key {
+			return true
+		}
+	}
+	return false
+}
+
+// removeLocked removes the entry in slot i by moving the last entry into it
+func (c *SampledCache[K, V]) removeLocked(i int32) {
+	last := int32(len(c.entries) - 1)
+	delete(c.index, c.entries[i].key)
+	if i != last {
+		c.entries[i] = c.entries[last]
+		c.index[c.entries[i].key] = i
+	}
+
+	// Drop the key and value so the spare capacity keeps nothing alive
+	c.entries[last] = sampledEntry[K, V]{}
+	c.entries = c.entries[:last]
+}
+
+// Get returns a copy of the value associated with key
+func (c *SampledCache[K, V]) Get(key K) (*V, bool) {
+	value, found := c.GetValue(key)
+	if !found {
+		return nil, false
+	}
+	return &value, true
+}
+
+// GetValue is Get returning the copy of the value itself
+func (c *SampledCache[K, V]) GetValue(key K) (V, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.stats.IncrementReads()
+
+	i, exists := c.index[key]
+	if !exists {
+		c.stats.IncrementMisses()
+		var zero V
+		return zero, false
+	}
+
+	e := &c.entries[i]
+	e.accessCount++
+	e.readAfterWrite = true
+	e.lastAccess = c.tickLocked()
+	c.stats.IncrementHits()
+
+	return c.cloner.onGet(e.value), true
+}
+
+// Delete removes the key from the cache, and returns a boolean to indicate
+// whether a value existed for that key
+func (c *SampledCache[K, V]) Delete(key K) bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if debugValidate {
+		defer c.debugCheckLocked()
+	}
+
+	i, exists := c.index[key]
+	if !exists {
+		return false
+	}
+
+	c.removeLocked(i)
+	c.stats.IncrementDeletes()
+
+	return true
+}
+
+// GetStatistics returns consistent statistics about the cache
+func (c *SampledCache[K, V]) GetStatistics() Statistics {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	stats := *c.stats
+	if len(c.entries) > 0 {
+		totalAccesses := 0
+		for i := range c.entries {
+			totalAccesses += int(c.entries[i].accessCount)
+			if !c.entries[i].readAfterWrite {
+				stats.CurrentNeverRead++
+			}
+		}
+		stats.AverageAccessCount = float64(totalAccesses) / float64(len(c.entries))
+	}
+	return stats
+}
+
+// Validate checks the internal invariants of the cache: the index and the
+// entries hold the same keys, the entry limit is respected, and the eviction
+// pool is ordered by idle time
+func (c *SampledCache[K, V]) Validate() error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	return c.validateLocked()
+}
+
+func (c *SampledCache[K, V]) validateLocked() error {
+	if len(c.entries) > c.entryLimit {
+		return fmt.Errorf("%w: %d entries exceed the limit of %d", errInvalid, len(c.entries), c.entryLimit)
+	}
+	if len(c.index) != len(c.entries) {
+		return fmt.Errorf("%w: %d indexed keys but %d entries", errInvalid, len(c.index), len(c.entries))
+	}
+	for i := range c.entries {
+		key := c.entries[i].key
+		if j, exists := c.index[key]; !exists || int(j) != i {
+			return fmt.Errorf("%w: entry %v in slot %d is not the one indexed under its key", errInvalid, key, i)
+		}
+	}
+
+	if len(c.pool) > evictionPoolSize {
+		return fmt.Errorf("%w: %d eviction candidates exceed the pool size of %d", errInvalid, len(c.pool), evictionPoolSize)
+	}
+	for j := 1; j < len(c.pool); j++ {
+		if c.clock-c.pool[j-1].lastAccess > c.clock-c.pool[j].lastAccess {
+			return fmt.Errorf("%w: eviction pool is out of order at %v", errInvalid, c.pool[j].key)
+		}
+	}
+	return nil
+}
+
+// debugCheckLocked panics if the cache is invalid; the caller must hold c.mu
+func (c *SampledCache[K, V]) debugCheckLocked() {
+	if err := c.validateLocked(); err != nil {
+		panic(err)
+	}
+}
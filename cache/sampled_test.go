@@ -0,0 +1,134 @@
+package cache
+
+import (
+	"errors"
+	"math/rand/v2"
+	"sync"
+	"testing"
+)
+
+func TestSampledCacheOperations(t *testing.T) {
+	// With far more samples than entries, every eviction sees every entry
+	// and picks the exact least recently used one
+	c := NewSampledCache[string, int](3, 100)
+
+	if c.Put("one", 1) || !c.Put("one", 100) {
+		t.Error("Put should report the key as new once, then as existing")
+	}
+	c.Put("two", 2)
+	c.Put("three", 3)
+	c.Get("one")
+	c.Put("four", 4) // Should evict "two"
+
+	if _, found := c.Get("two"); found {
+		t.Error("Key 'two' should have been evicted")
+	}
+	for _, key := range []string{"one", "three", "four"} {
+		if _, found := c.GetValue(key); !found {
+			t.Errorf("Key %q should still be cached", key)
+		}
+	}
+
+	if !c.Delete("three") || c.Delete("three") {
+		t.Error("Expected the first Delete of 'three' to succeed and the second to fail")
+	}
+	stats := c.GetStatistics()
+	if stats.Evictions != 1 || stats.NeverReadCount != 1 || stats.Deletes != 1 {
+		t.Errorf("Expected 1 eviction of a never read entry and 1 delete, got %+v", stats)
+	}
+	if err := c.Validate(); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestSampledCacheSkipsStaleCandidates(t *testing.T) {
+	c := NewSampledCache[int, int](4, 100)
+	for i := 0; i < 4; i++ {
+		c.Put(i, i)
+	}
+	c.Put(4, 4) // Evicts 0 and leaves 1, 2 and 3 in the pool
+
+	// 1 is the idlest candidate, but it's used after being sampled, and 2 is
+	// deleted; the pool must notice both
+	c.Get(1)
+	c.Delete(2)
+	c.Put(5, 5)
+	c.Put(6, 6)
+
+	for key, want := range map[int]bool{1: true, 2: false, 3: false, 4: true, 5: true, 6: true} {
+		if _, found := c.GetValue(key); found != want {
+			t.Errorf("Key %d cached is %v, expected %v", key, found, want)
+		}
+	}
+	if err := c.Validate(); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestSampledCacheValidate(t *testing.T) {
+	corruptions := map[string]func(c *SampledCache[int, int]){
+		"unindexed entry": func(c *SampledCache[int, int]) {
+			delete(c.index, c.entries[0].key)
+		},
+		"wrong slot": func(c *SampledCache[int, int]) {
+			c.index[c.entries[0].key] = 1
+		},
+		"unordered pool": func(c *SampledCache[int, int]) {
+			c.pool = []poolCandidate[int]{{key: 1, lastAccess: 1}, {key: 2, lastAccess: 2}}
+		},
+	}
+
+	for name, corrupt := range corruptions {
+		c := NewSampledCache[int, int](4, 0)
+		for i := 0; i < 4; i++ {
+			c.Put(i, i)
+		}
+		corrupt(c)
+		if err := c.Validate(); !errors.Is(err, errInvalid) {
+			t.Errorf("%s: expected an invariant violation, got %v", name, err)
+		}
+	}
+}
+
+func TestSampledCacheApproximatesLRU(t *testing.T) {
+	keys := scanTrace(100_000, 1000, 3)
+	lru := hitRatio(NewCache[int, int](200), keys)
+
+	// More samples get closer to LRU
+	for _, samples := range []int{5, 10} {
+		ratio := hitRatio(NewSampledCache[int, int](200, samples), keys)
+		t.Logf("%2d samples: hit ratio %.3f, LRU %.3f", samples, ratio, lru)
+		if ratio < lru*0.9 || ratio > lru*1.1 {
+			t.Errorf("Expected %d samples to stay within 10%% of LRU's hit ratio of %.3f, got %.3f", samples, lru, ratio)
+		}
+	}
+}
+
+func TestSampledCacheConcurrent(t *testing.T) {
+	c := NewSampledCache[int, int](50, 0)
+
+	var wg sync.WaitGroup
+	for i := 0; i < 8; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			rng := rand.New(rand.NewPCG(uint64(i), 0))
+			for j := 0; j < 2000; j++ {
+				key := rng.IntN(150)
+				switch rng.IntN(4) {
+				case 0:
+					c.Put(key, j)
+				case 1:
+					c.Delete(key)
+				default:
+					c.Get(key)
+				}
+			}
+		}()
+	}
+	wg.Wait()
+
+	if err := c.Validate(); err != nil {
+		t.Error(err)
+	}
+}
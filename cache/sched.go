@@ -0,0 +1,62 @@
+package cache
+
+// yieldPoint identifies a place in the cache code where a test scheduler may
+// switch to another goroutine
+type yieldPoint uint8
+
+const (
+	yieldBeforeLock   yieldPoint = iota // an operation is about to take the cache lock
+	yieldBetweenLocks                   // RWMutexCache.Get dropped the read lock and is about to take the write lock
+)
+
+func (p yieldPoint) String() string {
+	switch p {
+	case yieldBeforeLock:
+		return "before-lock"
+	case yieldBetweenLocks:
+		return "between-locks"
+	default:
+		return "unknown"
+	}
+}
+
+// scheduler is the hook a deterministic test harness installs to control how
+// goroutines interleave. Yield is only ever called while no cache lock is
+// held, so a scheduler may park the caller and run another goroutine without
+// risking a deadlock.
+type scheduler interface {
+	Yield(point yieldPoint)
+}
+
+// setScheduler installs a scheduler hook; nil removes it. It must be called
+// before the cache is shared between goroutines.
+func (c *Cache[K, V]) setScheduler(s scheduler) {
+	c.sched = s
+}
+
+func (c *Cache[K, V]) yield(point yieldPoint) {
+	if c.sched != nil {
+		c.sched.Yield(point)
+	}
+}
+
+// setScheduler installs a scheduler hook; nil removes it. It must be called
+// before the cache is shared between goroutines.
+func (c *RWMutexCache[K, V]) setScheduler(s scheduler) {
+	c.sched = s
+}
+
+func (c *RWMutexCache[K, V]) yield(point yieldPoint) {
+	if c.sched != nil {
+		c.sched.Yield(point)
+	}
+}
+
+// setScheduler installs a scheduler hook on every shard
+func (c *ShardedCache[K, V]) setScheduler(s scheduler) {
+	c.reshardMu.Lock()
+	defer c.reshardMu.Unlock()
+
+	c.sched = s
+	c.set.Load().each(func(shard *Cache[K, V]) { shard.setScheduler(s) })
+}
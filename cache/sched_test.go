@@ -0,0 +1,350 @@
+package cache
+
+import (
+	"flag"
+	"fmt"
+	"math/rand/v2"
+	"slices"
+	"strings"
+	"testing"
+)
+
+// schedSeed replays a single random schedule, for example
+//
+//	go test -run TestScheduleLinearizableRWMutexCache -sched.seed=17 ./cache
+var schedSeed = flag.Int64("sched.seed", -1, "replay only the deterministic schedule with this seed")
+
+// chooser picks which of n runnable goroutines runs next
+type chooser interface {
+	choose(n int) int
+}
+
+// randomChooser picks uniformly with a seeded generator
+type randomChooser struct {
+	rng *rand.Rand
+}
+
+func (r randomChooser) choose(n int) int {
+	return r.rng.IntN(n)
+}
+
+// dfsChooser enumerates schedules depth first: it replays a prefix of
+// choices, then always picks the first runnable goroutine, and remembers how
+// many alternatives each choice had so next can move to the following
+// schedule
+type dfsChooser struct {
+	prefix  []int
+	choices []int
+	widths  []int
+}
+
+func (d *dfsChooser) choose(n int) int {
+	c := 0
+	if i := len(d.choices); i < len(d.prefix) {
+		c = d.prefix[i]
+	}
+	d.choices = append(d.choices, c)
+	d.widths = append(d.widths, n)
+	return c
+}
+
+// next returns the prefix of the next unexplored schedule, or false once
+// every schedule has been run
+func (d *dfsChooser) next() ([]int, bool) {
+	for i := len(d.choices) - 1; i >= 0; i-- {
+		if d.choices[i]+1 < d.widths[i] {
+			return append(slices.Clone(d.choices[:i]), d.choices[i]+1), true
+		}
+	}
+	return nil, false
+}
+
+// schedThread is a goroutine under the control of a detScheduler
+type schedThread struct {
+	id       int
+	wake     chan struct{}
+	done     bool
+	panicked any
+}
+
+// detScheduler runs goroutines one at a time, switching only at yield points.
+// Since yield points are outside the cache locks, the goroutine that runs
+// never blocks on one that is parked, and the interleaving is decided
+// entirely by the chooser.
+type detScheduler struct {
+	chooser chooser
+	current *schedThread
+	back    chan struct{} // the running goroutine yielded or returned
+	running bool          // false before and after run, when yields pass straight through
+	trace   []string
+}
+
+// Yield parks the running goroutine and hands control back to the scheduler.
+// Outside run, such as in a scenario's check, it returns immediately.
+func (s *detScheduler) Yield(point yieldPoint) {
+	if !s.running {
+		return
+	}
+	t := s.current
+	s.trace = append(s.trace, fmt.Sprintf("t%d:%v", t.id, point))
+	s.back <- struct{}{}
+	<-t.wake
+}
+
+// run executes fns to completion under the scheduler
+func (s *detScheduler) run(fns []func()) error {
+	s.back = make(chan struct{})
+	s.running = true
+	defer func() { s.running = false }()
+	threads := make([]*schedThread, len(fns))
+	for i, fn := range fns {
+		t := &schedThread{id: i, wake: make(chan struct{})}
+		threads[i] = t
+		go func() {
+			defer func() {
+				t.panicked = recover()
+				t.done = true
+				s.back <- struct{}{}
+			}()
+			<-t.wake
+			fn()
+		}()
+	}
+
+	for {
+		var runnable []*schedThread
+		for _, t := range threads {
+			if !t.done {
+				runnable = append(runnable, t)
+			}
+		}
+		if len(runnable) == 0 {
+			break
+		}
+
+		s.current = runnable[s.chooser.choose(len(runnable))]
+		s.current.wake <- struct{}{}
+		<-s.back
+	}
+
+	for _, t := range threads {
+		if t.panicked != nil {
+			return fmt.Errorf("goroutine t%d panicked: %v", t.id, t.panicked)
+		}
+	}
+	return nil
+}
+
+// scenario builds a fresh system for one schedule. It installs s as the
+// scheduler of the caches it creates and returns the goroutines to run and a
+// check of the outcome.
+type scenario func(s scheduler) (threads []func(), check func() error)
+
+// runSchedule runs a scenario once and returns the interleaving it took
+func runSchedule(sc scenario, c chooser) ([]string, error) {
+	s := &detScheduler{chooser: c}
+	threads, check := sc(s)
+	if err := s.run(threads); err != nil {
+		return s.trace, err
+	}
+	return s.trace, check()
+}
+
+// exploreRandom runs seeds random schedules and returns the first failing one
+func exploreRandom(sc scenario, seeds int64) (int64, []string, error) {
+	for seed := int64(0); seed < seeds; seed++ {
+		trace, err := runSchedule(sc, randomChooser{rand.New(rand.NewPCG(uint64(seed), 0))})
+		if err != nil {
+			return seed, trace, err
+		}
+	}
+	return -1, nil, nil
+}
+
+// exploreSystematic runs every schedule, up to limit of them, and returns the
+// number run and the first failure
+func exploreSystematic(sc scenario, limit int) (int, []string, error) {
+	var prefix []int
+	for runs := 1; ; runs++ {
+		d := &dfsChooser{prefix: prefix}
+		if trace, err := runSchedule(sc, d); err != nil {
+			return runs, trace, err
+		}
+
+		next, more := d.next()
+		if !more || runs == limit {
+			return runs, nil, nil
+		}
+		prefix = next
+	}
+}
+
+// checkSchedules explores a scenario systematically and with random seeds,
+// reporting a failing seed so it can be replayed with -sched.seed
+func checkSchedules(t *testing.T, sc scenario) {
+	t.Helper()
+
+	if *schedSeed >= 0 {
+		trace, err := runSchedule(sc, randomChooser{rand.New(rand.NewPCG(uint64(*schedSeed), 0))})
+		if err != nil {
+			t.Fatalf("Seed %d: %v\nschedule: %s", *schedSeed, err, strings.Join(trace, " "))
+		}
+		return
+	}
+
+	if runs, trace, err := exploreSystematic(sc, 2000); err != nil {
+		t.Fatalf("Systematic schedule %d: %v\nschedule: %s", runs, err, strings.Join(trace, " "))
+	}
+	if seed, trace, err := exploreRandom(sc, 300); err != nil {
+		t.Fatalf("Seed %d: %v\nschedule: %s\nreplay with: go test -run '%s' -sched.seed=%d ./cache",
+			seed, err, strings.Join(trace, " "), t.Name(), seed)
+	}
+}
+
+// scheduledCache is a cache the scheduler can drive
+type scheduledCache interface {
+	linearizabilityCache
+	setScheduler(s scheduler)
+	GetStatistics() Statistics
+	Validate() error
+}
+
+// linearizableScenario runs a few fixed operations per goroutine and checks
+// the history against the LRU model
+func linearizableScenario(newCache func() scheduledCache, capacity int) scenario {
+	return func(s scheduler) ([]func(), func() error) {
+		c := newCache()
+		c.Put(1, 100)
+		c.Put(2, 200)
+		c.setScheduler(s)
+
+		h := &historyRecorder{}
+		put := func(client, key, value int) {
+			h.record(operation{client: client, kind: histPut, key: key, value: value},
+				func(op *operation) { op.found = c.Put(op.key, op.value) })
+		}
+		get := func(client, key int) {
+			h.record(operation{client: client, kind: histGet, key: key}, func(op *operation) {
+				if v, found := c.Get(op.key); found {
+					op.found, op.got = true, *v
+				}
+			})
+		}
+		del := func(client, key int) {
+			h.record(operation{client: client, kind: histDelete, key: key},
+				func(op *operation) { op.found = c.Delete(op.key) })
+		}
+
+		threads := []func(){
+			func() { get(0, 1); put(0, 3, 300) },
+			func() { del(1, 1); get(1, 2) },
+			func() { put(2, 1, 101); get(2, 1) },
+		}
+		check := func() error {
+			if err := c.Validate(); err != nil {
+				return err
+			}
+			stats := c.GetStatistics()
+			if stats.Hits+stats.Misses != stats.Reads {
+				return fmt.Errorf("%d hits and %d misses for %d reads", stats.Hits, stats.Misses, stats.Reads)
+			}
+			// The setup is sequential and linearizes first
+			ops := append([]operation{
+				{kind: histPut, key: 1, value: 100, call: -4, ret: -3},
+				{kind: histPut, key: 2, value: 200, call: -2, ret: -1},
+			}, h.ops...)
+			if !checkLinearizable(ops, capacity) {
+				return fmt.Errorf("history is not linearizable:\n%s", formatHistory(ops))
+			}
+			return nil
+		}
+		return threads, check
+	}
+}
+
+func TestScheduleLinearizableCache(t *testing.T) {
+	checkSchedules(t, linearizableScenario(func() scheduledCache { return NewCache[int, int](2) }, 2))
+}
+
+func TestScheduleLinearizableRWMutexCache(t *testing.T) {
+	checkSchedules(t, linearizableScenario(func() scheduledCache { return NewRWMutexCache[int, int](2) }, 2))
+}
+
+// lostUpdateScenario increments a counter with a Get followed by a Put, a
+// check-then-act race the cache locks can't prevent
+func lostUpdateScenario(s scheduler) ([]func(), func() error) {
+	c := NewCache[string, int](10)
+	c.Put("counter", 0)
+	c.setScheduler(s)
+
+	increment := func() {
+		v, _ := c.Get("counter")
+		c.Put("counter", *v+1)
+	}
+	check := func() error {
+		if v, _ := c.Get("counter"); *v != 2 {
+			return fmt.Errorf("expected counter 2, got %d", *v)
+		}
+		return nil
+	}
+	return []func(){increment, increment}, check
+}
+
+func TestScheduleFindsLostUpdate(t *testing.T) {
+	runs, trace, err := exploreSystematic(lostUpdateScenario, 100)
+	if err == nil {
+		t.Fatalf("Expected systematic exploration to find the lost update, ran %d schedules", runs)
+	}
+	t.Logf("Found after %d schedules: %v\nschedule: %s", runs, err, strings.Join(trace, " "))
+
+	seed, trace, err := exploreRandom(lostUpdateScenario, 100)
+	if err == nil {
+		t.Fatal("Expected a random schedule to find the lost update")
+	}
+
+	// The failing seed replays the same interleaving and the same failure
+	for i := 0; i < 5; i++ {
+		replayed, replayErr := runSchedule(lostUpdateScenario, randomChooser{rand.New(rand.NewPCG(uint64(seed), 0))})
+		if replayErr == nil || replayErr.Error() != err.Error() || !slices.Equal(replayed, trace) {
+			t.Fatalf("Seed %d didn't replay: got %v with %v, expected %v with %v", seed, replayErr, replayed, err, trace)
+		}
+	}
+}
+
+func TestScheduleReachesRWMutexRecheck(t *testing.T) {
+	// A Get that hits under the read lock and loses the entry to a Delete
+	// before it takes the write lock must report a miss
+	var missed, hit bool
+	sc := func(s scheduler) ([]func(), func() error) {
+		c := NewRWMutexCache[int, int](2)
+		c.Put(1, 100)
+		c.setScheduler(s)
+
+		var found bool
+		threads := []func(){
+			func() { _, found = c.Get(1) },
+			func() { c.Delete(1) },
+		}
+		check := func() error {
+			stats := c.GetStatistics()
+			if found {
+				hit = true
+			} else if stats.Misses == 1 && stats.Reads == 1 {
+				missed = true
+			}
+			if stats.Hits+stats.Misses != stats.Reads {
+				return fmt.Errorf("%d hits and %d misses for %d reads", stats.Hits, stats.Misses, stats.Reads)
+			}
+			return nil
+		}
+		return threads, check
+	}
+
+	runs, trace, err := exploreSystematic(sc, 0)
+	if err != nil {
+		t.Fatalf("Schedule %d: %v\nschedule: %s", runs, err, strings.Join(trace, " "))
+	}
+	if !missed || !hit {
+		t.Errorf("Expected the %d schedules to cover both a hit and a miss, got hit=%v missed=%v", runs, hit, missed)
+	}
+}
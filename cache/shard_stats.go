@@ -0,0 +1,202 @@
+package cache
+
+import (
+	"context"
+	"log"
+	"slices"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// lockContention counts how often and for how long callers waited for a
+// lock
+type lockContention struct {
+	waits atomic.Int64 // acquisitions that found the lock held
+	nanos atomic.Int64 // total time spent waiting
+}
+
+// lock acquires mu. As in lockCtx, the uncontended case costs a single
+// TryLock; only a contended lock reads the clock to time the wait.
+func (lc *lockContention) lock(mu *sync.Mutex) {
+	if mu.TryLock() {
+		return
+	}
+
+	start := time.Now()
+	mu.Lock()
+	lc.waits.Add(1)
+	lc.nanos.Add(int64(time.Since(start)))
+}
+
+// lockCtx is lock that gives up with ctx.Err() if ctx is done first. A wait
+// that gives up still counts, since the caller did wait for the lock.
+func (lc *lockContention) lockCtx(ctx context.Context, mu *sync.Mutex) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	if mu.TryLock() {
+		return nil
+	}
+
+	start := time.Now()
+	err := lockCtx(ctx, mu)
+	lc.waits.Add(1)
+	lc.nanos.Add(int64(time.Since(start)))
+	return err
+}
+
+// ops returns the reads, writes and deletes the cache has served, without
+// taking its lock
+func (c *Cache[K, V]) ops() int64 {
+	return atomic.LoadInt64(&c.stats.Reads) + atomic.LoadInt64(&c.stats.Writes) + atomic.LoadInt64(&c.stats.Deletes)
+}
+
+// ShardStatistics describes the traffic and lock contention of one shard of
+// a ShardedCache
+type ShardStatistics struct {
+	Statistics                 // the shard's own counters
+	Shard        int           // index of the shard
+	Size         int           // entries cached in the shard
+	Ops          int64         // reads, writes and deletes
+	LockWaits    int64         // operations that found the shard's lock held
+	LockWaitTime time.Duration // total time operations waited for the lock
+}
+
+// ShardStatistics returns the statistics of every shard, in shard order.
+// Each shard is consistent on its own, but shards are read one after the
+// other. During a Reshard, these are the new shards.
+func (c *ShardedCache[K, V]) ShardStatistics() []ShardStatistics {
+	shards := c.set.Load().shards
+	stats := make([]ShardStatistics, len(shards))
+	for i, shard := range shards {
+		s := shard.GetStatistics()
+		stats[i] = ShardStatistics{
+			Statistics:   s,
+			Shard:        i,
+			Size:         shard.Len(),
+			Ops:          s.Reads + s.Writes + s.Deletes,
+			LockWaits:    shard.contention.waits.Load(),
+			LockWaitTime: time.Duration(shard.contention.nanos.Load()),
+		}
+	}
+	return stats
+}
+
+// LoadImbalance returns the operations of the busiest shard divided by the
+// mean per shard: 1 when traffic is spread evenly, up to the shard count
+// when a single shard gets all of it, and 0 before any traffic
+func (c *ShardedCache[K, V]) LoadImbalance() float64 {
+	shards := c.set.Load().shards
+	ops := make([]int64, len(shards))
+	for i, shard := range shards {
+		ops[i] = shard.ops()
+	}
+	return loadImbalance(ops)
+}
+
+// loadImbalance returns max/mean of the per-shard operation counts
+func loadImbalance(ops []int64) float64 {
+	var total, busiest int64
+	for _, n := range ops {
+		total += n
+		busiest = max(busiest, n)
+	}
+	if total == 0 {
+		return 0
+	}
+	return float64(busiest) * float64(len(ops)) / float64(total)
+}
+
+// HotShard reports a shard that served more than its share of the
+// operations during one interval of WatchHotShards
+type HotShard struct {
+	Shard     int     // index of the shard
+	Ops       int64   // operations the shard served in the interval
+	Share     float64 // fraction of all operations in the interval
+	Imbalance float64 // LoadImbalance over the interval
+}
+
+// WatchHotShards starts a goroutine that compares the traffic of the shards
+// every interval until ctx is done, and calls fn for each shard that served
+// more than threshold of all operations in that interval. With a nil fn, hot
+// shards are logged with the log package. Intervals with fewer operations
+// than there are shards are too quiet to judge and are skipped.
+//
+// The watcher reads the shards' counters without locking them, so it adds
+// nothing to the cost of an operation.
+func (c *ShardedCache[K, V]) WatchHotShards(ctx context.Context, interval time.Duration, threshold float64, fn func(HotShard)) {
+	if fn == nil {
+		fn = func(h HotShard) {
+			log.Printf("cache: shard %d served %.0f%% of operations (%d, imbalance %.2f)",
+				h.Shard, h.Share*100, h.Ops, h.Imbalance)
+		}
+	}
+
+	w := newHotShardWatcher(c, threshold, fn)
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-ticker.C:
+				w.check()
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+}
+
+// hotShardWatcher compares shard traffic since its previous check
+type hotShardWatcher[K comparable, V any] struct {
+	cache     *ShardedCache[K, V]
+	threshold float64
+	fn        func(HotShard)
+	shards    []*Cache[K, V] // shards at the previous check
+	last      []int64        // operations of each shard at the previous check
+}
+
+func newHotShardWatcher[K comparable, V any](c *ShardedCache[K, V], threshold float64, fn func(HotShard)) *hotShardWatcher[K, V] {
+	w := &hotShardWatcher[K, V]{cache: c, threshold: threshold, fn: fn}
+	w.reset(c.set.Load().shards)
+	return w
+}
+
+// reset makes shards the baseline for the next check
+func (w *hotShardWatcher[K, V]) reset(shards []*Cache[K, V]) {
+	w.shards = shards
+	w.last = make([]int64, len(shards))
+	for i, shard := range shards {
+		w.last[i] = shard.ops()
+	}
+}
+
+// check reports the shards that were hot since the previous check. After a
+// Reshard, the first check only records the new shards.
+func (w *hotShardWatcher[K, V]) check() {
+	if shards := w.cache.set.Load().shards; !slices.Equal(shards, w.shards) {
+		w.reset(shards)
+		return
+	}
+
+	delta := make([]int64, len(w.last))
+	var total int64
+	for i, shard := range w.shards {
+		ops := shard.ops()
+		delta[i] = ops - w.last[i]
+		w.last[i] = ops
+		total += delta[i]
+	}
+	if total < int64(len(delta)) {
+		return
+	}
+
+	imbalance := loadImbalance(delta)
+	for i, ops := range delta {
+		if share := float64(ops) / float64(total); share > w.threshold {
+			w.fn(HotShard{Shard: i, Ops: ops, Share: share, Imbalance: imbalance})
+		}
+	}
+}
@@ -0,0 +1,190 @@
+package cache
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+)
+
+// keysOnShard returns n int keys that all map to the given shard
+func keysOnShard(c *ShardedCache[int, int], shard, n int) []int {
+	var keys []int
+	for key := 0; len(keys) < n; key++ {
+		if c.getShard(key) == c.set.Load().shards[shard] {
+			keys = append(keys, key)
+		}
+	}
+	return keys
+}
+
+func TestShardStatistics(t *testing.T) {
+	c := NewShardedCache[int, int](80, 8)
+	for _, key := range keysOnShard(c, 3, 5) {
+		c.Put(key, key)
+		c.Get(key)
+	}
+	c.Get(-1)
+
+	stats := c.ShardStatistics()
+	if len(stats) != 8 {
+		t.Fatalf("Expected 8 shards, got %d", len(stats))
+	}
+	if s := stats[3]; s.Shard != 3 || s.Size != 5 || s.Ops != 10 || s.Hits != 5 {
+		t.Errorf("Expected shard 3 to hold 5 entries after 10 operations with 5 hits, got %+v", s)
+	}
+
+	total := 0
+	for _, s := range stats {
+		total += s.Size
+	}
+	if total != 5 {
+		t.Errorf("Expected the shard sizes to add up to 5, got %d", total)
+	}
+}
+
+func TestShardLockWaits(t *testing.T) {
+	c := NewShardedCache[int, int](80, 8)
+	key := keysOnShard(c, 0, 1)[0]
+
+	// Hold the shard's lock while a Put waits for it
+	shard := c.set.Load().shards[0]
+	shard.mu.Lock()
+	done := make(chan struct{})
+	go func() {
+		c.Put(key, 1)
+		close(done)
+	}()
+	time.Sleep(20 * time.Millisecond)
+	shard.mu.Unlock()
+	<-done
+
+	stats := c.ShardStatistics()[0]
+	if stats.LockWaits != 1 || stats.LockWaitTime < 10*time.Millisecond {
+		t.Errorf("Expected one wait of about 20ms, got %d waits for %v", stats.LockWaits, stats.LockWaitTime)
+	}
+	if other := c.ShardStatistics()[1]; other.LockWaits != 0 {
+		t.Errorf("Expected no waits on an idle shard, got %d", other.LockWaits)
+	}
+}
+
+func TestLoadImbalance(t *testing.T) {
+	tests := []struct {
+		ops  []int64
+		want float64
+	}{
+		{[]int64{0, 0, 0, 0}, 0},
+		{[]int64{5, 5, 5, 5}, 1},
+		{[]int64{20, 0, 0, 0}, 4},
+		{[]int64{10, 5, 5, 0}, 2},
+	}
+	for _, tt := range tests {
+		if got := loadImbalance(tt.ops); got != tt.want {
+			t.Errorf("loadImbalance(%v) = %v, want %v", tt.ops, got, tt.want)
+		}
+	}
+
+	c := NewShardedCache[int, int](80, 8)
+	for _, key := range keysOnShard(c, 5, 10) {
+		c.Put(key, key)
+	}
+	if got := c.LoadImbalance(); got != 8 {
+		t.Errorf("Expected all traffic on one of 8 shards to give 8, got %v", got)
+	}
+}
+
+func TestHotShardWatcher(t *testing.T) {
+	c := NewShardedCache[int, int](800, 8)
+	var hot []HotShard
+	w := newHotShardWatcher(c, 0.5, func(h HotShard) { hot = append(hot, h) })
+
+	// Even traffic over many keys isn't hot
+	for key := 0; key < 800; key++ {
+		c.Put(key, key)
+	}
+	w.check()
+	if len(hot) != 0 {
+		t.Fatalf("Expected no hot shards for spread out keys, got %+v", hot)
+	}
+
+	// A few keys on one shard take most of the next interval
+	hotKeys := keysOnShard(c, 6, 3)
+	for i := 0; i < 300; i++ {
+		c.Get(hotKeys[i%len(hotKeys)])
+	}
+	for key := 0; key < 100; key++ {
+		c.Get(key)
+	}
+	w.check()
+	if len(hot) != 1 || hot[0].Shard != 6 || hot[0].Share < 0.75 || hot[0].Imbalance < 6 {
+		t.Errorf("Expected shard 6 reported with over 75%% of the traffic, got %+v", hot)
+	}
+
+	// Too quiet an interval is skipped
+	hot = nil
+	c.Get(hotKeys[0])
+	w.check()
+	if len(hot) != 0 {
+		t.Errorf("Expected a single operation not to be judged, got %+v", hot)
+	}
+}
+
+func TestWatchHotShards(t *testing.T) {
+	c := NewShardedCache[int, int](80, 8)
+	key := keysOnShard(c, 2, 1)[0]
+
+	var mu sync.Mutex
+	var hot []HotShard
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	c.WatchHotShards(ctx, 5*time.Millisecond, 0.9, func(h HotShard) {
+		mu.Lock()
+		defer mu.Unlock()
+		hot = append(hot, h)
+	})
+
+	deadline := time.Now().Add(5 * time.Second)
+	for time.Now().Before(deadline) {
+		for i := 0; i < 100; i++ {
+			c.Get(key)
+		}
+		time.Sleep(time.Millisecond)
+
+		mu.Lock()
+		n := len(hot)
+		mu.Unlock()
+		if n > 0 {
+			break
+		}
+	}
+
+	mu.Lock()
+	defer mu.Unlock()
+	if len(hot) == 0 || hot[0].Shard != 2 {
+		t.Errorf("Expected shard 2 to be reported hot, got %+v", hot)
+	}
+}
+
+func TestShardLockWaitsWithContext(t *testing.T) {
+	c := NewShardedCache[int, int](80, 8)
+	key := keysOnShard(c, 0, 1)[0]
+
+	// Context-aware calls wait on the same shard lock and must be counted too
+	shard := c.set.Load().shards[0]
+	shard.mu.Lock()
+	done := make(chan error)
+	go func() {
+		_, err := c.PutCtx(context.Background(), key, 1)
+		done <- err
+	}()
+	time.Sleep(20 * time.Millisecond)
+	shard.mu.Unlock()
+	if err := <-done; err != nil {
+		t.Fatalf("Expected PutCtx to succeed, got %v", err)
+	}
+
+	stats := c.ShardStatistics()[0]
+	if stats.LockWaits != 1 || stats.LockWaitTime < 10*time.Millisecond {
+		t.Errorf("Expected one wait of about 20ms, got %d waits for %v", stats.LockWaits, stats.LockWaitTime)
+	}
+}
@@ -0,0 +1,300 @@
+package cache
+
+import (
+	"fmt"
+	"sync"
+	"sync/atomic"
+)
+
+// fifoEntry is an entry of the FIFO based caches. Reads don't move it, they
+// only update the atomic fields, so Get needs no more than a read lock.
+type fifoEntry[K comparable, V any] struct {
+	key            K
+	value          V
+	freq           atomic.Uint32 // reads since eviction last looked at the entry, capped
+	accessCount    atomic.Int64  // number of times the entry has been accessed
+	readAfterWrite atomic.Bool   // true if read after the last write
+	segment        segment       // queue holding the entry, in caches with more than one
+	prev, next     *fifoEntry[K, V]
+}
+
+// touch records a read, counting freq up to limit. An entry at the limit is
+// only loaded, not written, so hot keys stay cheap to read.
+func (e *fifoEntry[K, V]) touch(limit uint32) {
+	for {
+		f := e.freq.Load()
+		if f >= limit || e.freq.CompareAndSwap(f, f+1) {
+			break
+		}
+	}
+	if !e.readAfterWrite.Load() {
+		e.readAfterWrite.Store(true)
+	}
+	e.accessCount.Add(1)
+}
+
+// fifoList is an intrusive doubly linked list of fifoEntries, newest at the
+// head. Entries only move when they're inserted, promoted or removed.
+type fifoList[K comparable, V any] struct {
+	head *fifoEntry[K, V]
+	tail *fifoEntry[K, V]
+	len  int
+}
+
+// addToFront adds an entry to the front of the list
+func (l *fifoList[K, V]) addToFront(e *fifoEntry[K, V]) {
+	l.len++
+	e.prev, e.next = nil, l.head
+	if l.head != nil {
+		l.head.prev = e
+	} else {
+		l.tail = e
+	}
+	l.head = e
+}
+
+// remove unlinks an entry from the list
+func (l *fifoList[K, V]) remove(e *fifoEntry[K, V]) {
+	if e.prev != nil {
+		e.prev.next = e.next
+	} else {
+		l.head = e.next
+	}
+	if e.next != nil {
+		e.next.prev = e.prev
+	} else {
+		l.tail = e.prev
+	}
+	e.prev, e.next = nil, nil
+	l.len--
+}
+
+// fifoStatistics fills in the on-demand fields of stats from the cached
+// entries
+func fifoStatistics[K comparable, V any](items map[K]*fifoEntry[K, V], stats *Statistics) {
+	if len(items) == 0 {
+		return
+	}
+
+	var totalAccesses int64
+	for _, e := range items {
+		totalAccesses += e.accessCount.Load()
+		if !e.readAfterWrite.Load() {
+			stats.CurrentNeverRead++
+		}
+	}
+	stats.AverageAccessCount = float64(totalAccesses) / float64(len(items))
+}
+
+// SIEVECache implements a thread safe cache with the SIEVE eviction policy.
+// Entries sit in one FIFO in insertion order, and a read only sets the
+// entry's visited bit, so Get shares a read lock with other readers instead
+// of taking the exclusive lock RWMutexCache needs to reorder its list. To
+// evict, a hand sweeps from the oldest entry towards the newest, clearing
+// visited bits, and evicts the first entry it finds unvisited. The hand
+// stays where it stopped, so survivors aren't moved either.
+type SIEVECache[K comparable, V any] struct {
+	mu         sync.RWMutex
+	entryLimit int
+	items      map[K]*fifoEntry[K, V]
+	list       fifoList[K, V]
+	hand       *fifoEntry[K, V] // next entry to examine, nil to start from the tail
+	stats      *Statistics
+	cloner     cloning[V] // deep copies values on Put and Get, set by SetCloner
+}
+
+// NewSIEVECache creates a SIEVE cache with the given entry limit
+func NewSIEVECache[K comparable, V any](entryLimit int) *SIEVECache[K, V] {
+	return &SIEVECache[K, V]{
+		entryLimit: entryLimit,
+		items:      make(map[K]*fifoEntry[K, V]),
+		stats:      newStatistics(),
+	}
+}
+
+// SetCloner makes the cache deep copy values with clone when mode says so
+func (c *SIEVECache[K, V]) SetCloner(clone Cloner[V], mode CloneMode) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.cloner = cloning[V]{clone: clone, mode: mode}
+}
+
+// Put adds the value to the cache, and returns a boolean to indicate whether
+// a value already existed in the cache for that key. An updated entry keeps
+// its place and visited bit.
+func (c *SIEVECache[K, V]) Put(key K, value V) bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if debugValidate {
+		defer c.debugCheckLocked()
+	}
+
+	c.stats.IncrementWrites()
+	value = c.cloner.onPut(value)
+
+	if e, exists := c.items[key]; exists {
+		e.value = value
+		e.readAfterWrite.Store(false)
+		return true
+	}
+
+	if len(c.items) >= c.entryLimit && len(c.items) > 0 {
+		c.evictLocked()
+	}
+
+	e := &fifoEntry[K, V]{key: key, value: value}
+	c.items[key] = e
+	c.list.addToFront(e)
+	return false
+}
+
+// evictLocked moves the hand to the first unvisited entry and evicts it
+func (c *SIEVECache[K, V]) evictLocked() {
+	e := c.hand
+	if e == nil {
+		e = c.list.tail
+	}
+	for e.freq.Load() > 0 {
+		e.freq.Store(0)
+		if e = e.prev; e == nil {
+			e = c.list.tail
+		}
+	}
+
+	c.hand = e.prev
+	c.list.remove(e)
+	delete(c.items, e.key)
+
+	if !e.readAfterWrite.Load() {
+		c.stats.IncrementNeverRead()
+	}
+	c.stats.IncrementEvictions()
+}
+
+// Get returns a copy of the value associated with key
+func (c *SIEVECache[K, V]) Get(key K) (*V, bool) {
+	value, found := c.GetValue(key)
+	if !found {
+		return nil, false
+	}
+	return &value, true
+}
+
+// GetValue is Get returning the copy of the value itself
+func (c *SIEVECache[K, V]) GetValue(key K) (V, bool) {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	c.stats.IncrementReads()
+
+	e, exists := c.items[key]
+	if !exists {
+		c.stats.IncrementMisses()
+		var zero V
+		return zero, false
+	}
+
+	e.touch(1)
+	c.stats.IncrementHits()
+	return c.cloner.onGet(e.value), true
+}
+
+// Delete removes the key from the cache, and returns a boolean to indicate
+// whether a value existed for that key
+func (c *SIEVECache[K, V]) Delete(key K) bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if debugValidate {
+		defer c.debugCheckLocked()
+	}
+
+	e, exists := c.items[key]
+	if !exists {
+		return false
+	}
+
+	if c.hand == e {
+		c.hand = e.prev
+	}
+	c.list.remove(e)
+	delete(c.items, key)
+	c.stats.IncrementDeletes()
+
+	return true
+}
+
+// GetStatistics returns consistent statistics about the cache
+func (c *SIEVECache[K, V]) GetStatistics() Statistics {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	stats := *c.stats
+	fifoStatistics(c.items, &stats)
+	return stats
+}
+
+// Validate checks the internal invariants of the cache: the items map and the
+// FIFO hold the same entries, the hand is one of them, and the entry limit is
+// respected
+func (c *SIEVECache[K, V]) Validate() error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	return c.validateLocked()
+}
+
+func (c *SIEVECache[K, V]) validateLocked() error {
+	if len(c.items) > c.entryLimit {
+		return fmt.Errorf("%w: %d entries exceed the limit of %d", errInvalid, len(c.items), c.entryLimit)
+	}
+	if len(c.items) != c.list.len {
+		return fmt.Errorf("%w: %d items but the list counts %d entries", errInvalid, len(c.items), c.list.len)
+	}
+	if c.hand != nil && c.items[c.hand.key] != c.hand {
+		return fmt.Errorf("%w: hand points at %v, which isn't cached", errInvalid, c.hand.key)
+	}
+	return c.list.validateSegment(c.items, segmentLRU, 1)
+}
+
+// debugCheckLocked panics if the cache is invalid; the caller must hold c.mu
+func (c *SIEVECache[K, V]) debugCheckLocked() {
+	if err := c.validateLocked(); err != nil {
+		panic(err)
+	}
+}
+
+// validateSegment walks a FIFO from head to tail like the entryList version,
+// also checking that no read count exceeds maxFreq
+func (l *fifoList[K, V]) validateSegment(items map[K]*fifoEntry[K, V], segment segment, maxFreq uint32) error {
+	if (l.head == nil) != (l.tail == nil) {
+		return fmt.Errorf("%w: only one of head and tail is set", errInvalid)
+	}
+
+	count := 0
+	var prev *fifoEntry[K, V]
+	for e := l.head; e != nil; prev, e = e, e.next {
+		if count++; count > len(items) {
+			return fmt.Errorf("%w: list has a cycle or more entries than the map (%d)", errInvalid, len(items))
+		}
+		if e.prev != prev {
+			return fmt.Errorf("%w: entry %v has a bad previous link", errInvalid, e.key)
+		}
+		if items[e.key] != e {
+			return fmt.Errorf("%w: entry %v is not the one mapped to its key", errInvalid, e.key)
+		}
+		if e.segment != segment {
+			return fmt.Errorf("%w: entry %v is marked as on segment %d, not %d", errInvalid, e.key, e.segment, segment)
+		}
+		if f := e.freq.Load(); f > maxFreq {
+			return fmt.Errorf("%w: entry %v has frequency %d above %d", errInvalid, e.key, f, maxFreq)
+		}
+	}
+	if prev != l.tail {
+		return fmt.Errorf("%w: walk from head doesn't end at tail", errInvalid)
+	}
+	if count != l.len {
+		return fmt.Errorf("%w: list links %d entries but counts %d", errInvalid, count, l.len)
+	}
+	return nil
+}
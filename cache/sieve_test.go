@@ -0,0 +1,123 @@
+package cache
+
+import (
+	"errors"
+	"math/rand/v2"
+	"sync"
+	"testing"
+)
+
+func TestSIEVECacheEviction(t *testing.T) {
+	c := NewSIEVECache[string, int](3)
+
+	c.Put("a", 1)
+	c.Put("b", 2)
+	c.Put("c", 3)
+	c.Get("a")
+
+	// The hand starts at a, clears its visited bit and evicts b
+	c.Put("d", 4)
+	if _, found := c.Get("b"); found {
+		t.Error("Key 'b' should have been evicted")
+	}
+
+	// The hand waits at c, which is unvisited, while a no longer is
+	c.Put("e", 5)
+	if _, found := c.Get("c"); found {
+		t.Error("Key 'c' should have been evicted next")
+	}
+	for _, key := range []string{"a", "d", "e"} {
+		if _, found := c.GetValue(key); !found {
+			t.Errorf("Key %q should still be cached", key)
+		}
+	}
+
+	if c.Put("a", 100) != true {
+		t.Error("Key 'a' should exist on second Put")
+	}
+	stats := c.GetStatistics()
+	if stats.Evictions != 2 || stats.NeverReadCount != 2 {
+		t.Errorf("Expected 2 evictions of never read entries, got %+v", stats)
+	}
+	if err := c.Validate(); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestSIEVECacheDeleteAtHand(t *testing.T) {
+	c := NewSIEVECache[int, int](3)
+	for i := 0; i < 3; i++ {
+		c.Put(i, i)
+		c.Get(i)
+	}
+
+	// Every entry is visited, so the hand goes all the way round to evict 0
+	// and stops at 1
+	c.Put(3, 3)
+	if c.hand == nil || c.hand.key != 1 {
+		t.Fatalf("Expected the hand to stop at 1, got %v", c.hand)
+	}
+
+	c.Delete(1)
+	if err := c.Validate(); err != nil {
+		t.Fatal(err)
+	}
+	c.Put(4, 4)
+	c.Put(5, 5)
+	if err := c.Validate(); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestSIEVECacheValidate(t *testing.T) {
+	c := NewSIEVECache[int, int](4)
+	for i := 0; i < 5; i++ {
+		c.Put(i, i)
+	}
+
+	c.hand = &fifoEntry[int, int]{key: 100}
+	if err := c.Validate(); !errors.Is(err, errInvalid) {
+		t.Errorf("Expected a hand outside the cache to be reported, got %v", err)
+	}
+}
+
+// TestFIFOCachesConcurrent mixes readers holding the read lock with writers
+// evicting under the write lock, for the race detector to check
+func TestFIFOCachesConcurrent(t *testing.T) {
+	caches := map[string]interface {
+		Get(key int) (*int, bool)
+		Put(key int, value int) bool
+		Delete(key int) bool
+		Validate() error
+	}{
+		"sieve":  NewSIEVECache[int, int](50),
+		"s3fifo": NewS3FIFOCache[int, int](50),
+	}
+
+	for name, c := range caches {
+		var wg sync.WaitGroup
+		for i := 0; i < 8; i++ {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				rng := rand.New(rand.NewPCG(uint64(i), 0))
+				for j := 0; j < 2000; j++ {
+					key := rng.IntN(150)
+					switch rng.IntN(8) {
+					case 0, 1:
+						c.Put(key, j)
+					case 2:
+						c.Delete(key)
+					default:
+						c.Get(key)
+					}
+				}
+			}()
+		}
+		wg.Wait()
+
+		if err := c.Validate(); err != nil {
+			t.Errorf("%s: %v", name, err)
+		}
+	}
+}
@@ -0,0 +1,227 @@
+package cache
+
+import (
+	"fmt"
+	"sync"
+)
+
+// SLRUCache implements a thread safe segmented LRU cache. New entries start
+// on a probationary segment and move to a protected segment when they're read
+// again, so keys seen only once are evicted from probation without
+// displacing the ones that proved popular. When the protected segment is full,
+// its least recently used entry drops back to the front of probation.
+type SLRUCache[K comparable, V any] struct {
+	mu             sync.Mutex
+	entryLimit     int
+	protectedLimit int
+	items          map[K]*entry[K, V]
+	probation      entryList[K, V]
+	protected      entryList[K, V]
+	stats          *Statistics
+	cloner         cloning[V] // deep copies values on Put and Get, set by SetCloner
+}
+
+// NewSLRUCache creates a segmented LRU cache with the given entry limit, of
+// which protectedRatio may be held by the protected segment. The ratio must
+// be at least 0 and below 1; 0.8 is a common choice.
+func NewSLRUCache[K comparable, V any](entryLimit int, protectedRatio float64) *SLRUCache[K, V] {
+	if !(protectedRatio >= 0 && protectedRatio < 1) {
+		panic(fmt.Sprintf("cache: SLRU protected ratio %v is outside [0, 1)", protectedRatio))
+	}
+	return &SLRUCache[K, V]{
+		entryLimit:     entryLimit,
+		protectedLimit: int(float64(entryLimit) * protectedRatio),
+		items:          make(map[K]*entry[K, V]),
+		stats:          newStatistics(),
+	}
+}
+
+// SetCloner makes the cache deep copy values with clone when mode says so
+func (c *SLRUCache[K, V]) SetCloner(clone Cloner[V], mode CloneMode) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.cloner = cloning[V]{clone: clone, mode: mode}
+}
+
+// segmentLocked returns the list of an entry's segment
+func (c *SLRUCache[K, V]) segmentLocked(e *entry[K, V]) *entryList[K, V] {
+	if e.segment == segmentProtected {
+		return &c.protected
+	}
+	return &c.probation
+}
+
+// Put adds the value to the cache, and returns a boolean to indicate whether
+// a value already existed in the cache for that key. Writes don't promote an
+// entry; only reads do.
+func (c *SLRUCache[K, V]) Put(key K, value V) bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if debugValidate {
+		defer c.debugCheckLocked()
+	}
+
+	c.stats.IncrementWrites()
+	value = c.cloner.onPut(value)
+
+	if e, exists := c.items[key]; exists {
+		e.value = value
+		e.readAfterWrite = false
+		c.segmentLocked(e).moveToFront(e)
+		return true
+	}
+
+	if len(c.items) >= c.entryLimit && len(c.items) > 0 {
+		// Probation is only empty when everything cached was read twice
+		victim := c.probation.removeLast()
+		if victim == nil {
+			victim = c.protected.removeLast()
+		}
+		if !victim.readAfterWrite {
+			c.stats.IncrementNeverRead()
+		}
+		delete(c.items, victim.key)
+		c.stats.IncrementEvictions()
+	}
+
+	e := &entry[K, V]{key: key, value: value, segment: segmentProbation}
+	c.items[key] = e
+	c.probation.addToFront(e)
+	return false
+}
+
+// Get returns a copy of the value associated with key
+func (c *SLRUCache[K, V]) Get(key K) (*V, bool) {
+	value, found := c.GetValue(key)
+	if !found {
+		return nil, false
+	}
+	return &value, true
+}
+
+// GetValue is Get returning the copy of the value itself. A hit on probation
+// promotes the entry to the protected segment.
+func (c *SLRUCache[K, V]) GetValue(key K) (V, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if debugValidate {
+		defer c.debugCheckLocked()
+	}
+
+	c.stats.IncrementReads()
+
+	e, exists := c.items[key]
+	if !exists {
+		c.stats.IncrementMisses()
+		var zero V
+		return zero, false
+	}
+
+	e.accessCount++
+	e.readAfterWrite = true
+	if e.segment == segmentProtected {
+		c.protected.moveToFront(e)
+	} else {
+		c.promoteLocked(e)
+	}
+
+	c.stats.IncrementHits()
+	return c.cloner.onGet(e.value), true
+}
+
+// promoteLocked moves an entry from probation to the front of the protected
+// segment, demoting the least recently used protected entry if that
+// overflows it
+func (c *SLRUCache[K, V]) promoteLocked(e *entry[K, V]) {
+	c.probation.remove(e)
+	e.segment = segmentProtected
+	c.protected.addToFront(e)
+
+	if c.protected.len > c.protectedLimit {
+		demoted := c.protected.removeLast()
+		demoted.segment = segmentProbation
+		c.probation.addToFront(demoted)
+	}
+}
+
+// Delete removes the key from the cache, and returns a boolean to indicate
+// whether a value existed for that key
+func (c *SLRUCache[K, V]) Delete(key K) bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	e, exists := c.items[key]
+	if !exists {
+		return false
+	}
+
+	c.segmentLocked(e).remove(e)
+	delete(c.items, key)
+	c.stats.IncrementDeletes()
+	if debugValidate {
+		c.debugCheckLocked()
+	}
+
+	return true
+}
+
+// GetStatistics returns consistent statistics about the cache
+func (c *SLRUCache[K, V]) GetStatistics() Statistics {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	stats := *c.stats
+	entryStatistics(c.items, &stats)
+	return stats
+}
+
+// entryStatistics fills in the on-demand fields of stats from the cached
+// entries
+func entryStatistics[K comparable, V any](items map[K]*entry[K, V], stats *Statistics) {
+	if len(items) == 0 {
+		return
+	}
+
+	totalAccesses := 0
+	for _, e := range items {
+		totalAccesses += e.accessCount
+		if !e.readAfterWrite {
+			stats.CurrentNeverRead++
+		}
+	}
+	stats.AverageAccessCount = float64(totalAccesses) / float64(len(items))
+}
+
+// Validate checks the internal invariants of the cache: every item is on
+// exactly the list of its segment, and neither the entry limit nor the
+// protected limit is exceeded
+func (c *SLRUCache[K, V]) Validate() error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	return c.validateLocked()
+}
+
+func (c *SLRUCache[K, V]) validateLocked() error {
+	if len(c.items) > c.entryLimit {
+		return fmt.Errorf("%w: %d entries exceed the limit of %d", errInvalid, len(c.items), c.entryLimit)
+	}
+	if c.protected.len > c.protectedLimit {
+		return fmt.Errorf("%w: %d protected entries exceed the limit of %d", errInvalid, c.protected.len, c.protectedLimit)
+	}
+	if n := c.probation.len + c.protected.len; n != len(c.items) {
+		return fmt.Errorf("%w: %d items but the segments count %d entries", errInvalid, len(c.items), n)
+	}
+	if err := c.probation.validateSegment(c.items, segmentProbation); err != nil {
+		return err
+	}
+	return c.protected.validateSegment(c.items, segmentProtected)
+}
+
+// debugCheckLocked panics if the cache is invalid; the caller must hold c.mu
+func (c *SLRUCache[K, V]) debugCheckLocked() {
+	if err := c.validateLocked(); err != nil {
+		panic(err)
+	}
+}
@@ -0,0 +1,132 @@
+package cache
+
+import (
+	"errors"
+	"math/rand/v2"
+	"sync"
+	"testing"
+)
+
+func TestSLRUCachePromotion(t *testing.T) {
+	// Four entries, two of them protected
+	c := NewSLRUCache[string, int](4, 0.5)
+
+	c.Put("a", 1)
+	c.Put("b", 2)
+	c.Get("a") // a is protected
+	c.Put("c", 3)
+	c.Put("d", 4)
+	c.Put("e", 5) // Evicts b, the oldest on probation, not a
+
+	if _, found := c.Get("b"); found {
+		t.Error("Key 'b' should have been evicted from probation")
+	}
+	if v, found := c.GetValue("a"); !found || v != 1 {
+		t.Errorf("Expected protected key 'a' to survive with 1, got %v, %v", v, found)
+	}
+
+	// Promoting two more overflows protection and demotes a
+	c.Get("c")
+	c.Get("d")
+	if c.items["a"].segment != segmentProbation {
+		t.Error("Expected 'a' to be demoted to probation")
+	}
+	if c.protected.len != 2 || c.probation.len != 2 {
+		t.Errorf("Expected 2 protected and 2 probationary entries, got %d and %d", c.protected.len, c.probation.len)
+	}
+
+	stats := c.GetStatistics()
+	if stats.Evictions != 1 || stats.NeverReadCount != 1 {
+		t.Errorf("Expected 1 eviction of a never read entry, got %+v", stats)
+	}
+	if err := c.Validate(); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestSLRUCacheAllProtected(t *testing.T) {
+	c := NewSLRUCache[int, int](3, 0.9)
+	for i := 0; i < 3; i++ {
+		c.Put(i, i)
+	}
+	c.Get(0)
+	c.Get(1)
+
+	// Only 2 may be protected, so 2 itself stays on probation and goes first
+	c.Get(2)
+	c.Put(3, 3)
+	if _, found := c.Get(0); found {
+		t.Error("Expected key 0, demoted when 2 was promoted, to be evicted")
+	}
+	if err := c.Validate(); err != nil {
+		t.Error(err)
+	}
+
+	// With no protected segment at all SLRU is plain LRU
+	lru := NewSLRUCache[int, int](2, 0)
+	lru.Put(1, 1)
+	lru.Put(2, 2)
+	lru.Get(1)
+	lru.Put(3, 3)
+	if _, found := lru.Get(2); found {
+		t.Error("Expected key 2 to be evicted as the least recently used")
+	}
+	if err := lru.Validate(); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestSLRUCacheRejectsBadRatio(t *testing.T) {
+	for _, ratio := range []float64{-0.1, 1, 2} {
+		func() {
+			defer func() {
+				if recover() == nil {
+					t.Errorf("Expected a panic for protected ratio %v", ratio)
+				}
+			}()
+			NewSLRUCache[int, int](10, ratio)
+		}()
+	}
+}
+
+func TestSLRUCacheValidate(t *testing.T) {
+	c := NewSLRUCache[int, int](4, 0.5)
+	for i := 0; i < 4; i++ {
+		c.Put(i, i)
+	}
+	c.Get(0)
+
+	c.items[1].segment = segmentProtected
+	if err := c.Validate(); !errors.Is(err, errInvalid) {
+		t.Errorf("Expected an entry on the wrong segment to be reported, got %v", err)
+	}
+}
+
+func TestSLRUCacheConcurrent(t *testing.T) {
+	c := NewSLRUCache[int, int](50, 0.8)
+
+	var wg sync.WaitGroup
+	for i := 0; i < 8; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			rng := rand.New(rand.NewPCG(uint64(i), 0))
+			for j := 0; j < 2000; j++ {
+				key := rng.IntN(100)
+				switch rng.IntN(4) {
+				case 0:
+					c.Put(key, j)
+				case 1:
+					c.Delete(key)
+				default:
+					c.Get(key)
+				}
+			}
+		}()
+	}
+	wg.Wait()
+
+	if err := c.Validate(); err != nil {
+		t.Error(err)
+	}
+}
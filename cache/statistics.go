@@ -11,6 +11,8 @@ type Statistics struct {
 	Hits               int64   // Number of cache hits
 	Misses             int64   // Number of cache misses
 	Evictions          int64   // Number of entries evicted
+	Deletes            int64   // Number of entries removed by Delete
+	Invalidations      int64   // Number of entries removed by tag or prefix invalidation
 	NeverReadCount     int64   //Total evicted items that were never read
 	CurrentNeverRead   int     //Current items never read (calculated on demand)
 	AverageAccessCount float64 // Average access  count (calculated on demand)
@@ -47,6 +49,16 @@ func (s *Statistics) IncrementEvictions() {
 	atomic.AddInt64(&s.Evictions, 1)
 }
 
+// IncrementDeletes increments the deletes counter
+func (s *Statistics) IncrementDeletes() {
+	atomic.AddInt64(&s.Deletes, 1)
+}
+
+// AddInvalidations adds n to the invalidations counter
+func (s *Statistics) AddInvalidations(n int64) {
+	atomic.AddInt64(&s.Invalidations, n)
+}
+
 // IncrementNeverRead increments the counter for evicted items that were never read
 func (s *Statistics) IncrementNeverRead() {
 	atomic.AddInt64(&s.NeverReadCount, 1)
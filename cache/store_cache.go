@@ -0,0 +1,554 @@
+package cache
+
+import (
+	"errors"
+	"fmt"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// Store is a backing store, such as a database, that a StoreCache keeps
+// consistent with the cache
+type Store[K comparable, V any] interface {
+	// Load returns the stored value for key and whether one exists
+	Load(key K) (V, bool, error)
+	// Store saves the value for key
+	Store(key K, value V) error
+	// Delete removes key from the store; deleting a missing key is not an error
+	Delete(key K) error
+}
+
+// BatchStore is implemented by stores that can save many values in one call.
+// Write-behind flushes use it instead of calling Store once per entry.
+type BatchStore[K comparable, V any] interface {
+	Store[K, V]
+	StoreBatch(values map[K]V) error
+}
+
+// WriteMode selects when a StoreCache writes to its store
+type WriteMode int
+
+const (
+	// WriteThrough writes to the store before updating the cache; Put only
+	// succeeds once the store has accepted the value
+	WriteThrough WriteMode = iota
+
+	// WriteBehind updates the cache straight away and queues the entry as
+	// dirty; a pool of workers flushes dirty entries to the store in batches
+	WriteBehind
+)
+
+// StoreOptions configures a StoreCache. Zero values select the defaults.
+type StoreOptions struct {
+	Mode          WriteMode
+	Workers       int             // write-behind flush workers (default 4)
+	BatchSize     int             // most dirty entries written per batch (default 64)
+	FlushInterval time.Duration   // how often dirty entries are flushed (default 100ms)
+	MaxRetries    int             // retries of a failed batch before it is dropped (default 3)
+	RetryBackoff  time.Duration   // wait before the first retry, doubled every retry (default 10ms)
+	OnError       func(err error) // called when a write-behind batch is dropped
+}
+
+func (o *StoreOptions) setDefaults() {
+	if o.Workers <= 0 {
+		o.Workers = 4
+	}
+	if o.BatchSize <= 0 {
+		o.BatchSize = 64
+	}
+	if o.FlushInterval <= 0 {
+		o.FlushInterval = 100 * time.Millisecond
+	}
+	if o.MaxRetries < 0 {
+		o.MaxRetries = 0
+	} else if o.MaxRetries == 0 {
+		o.MaxRetries = 3
+	}
+	if o.RetryBackoff <= 0 {
+		o.RetryBackoff = 10 * time.Millisecond
+	}
+}
+
+// StoreStatistics tracks the cache and its traffic to the backing store
+type StoreStatistics struct {
+	Cache   Statistics // statistics of the in-memory cache
+	Loads   int64      // values loaded from the store on a cache miss
+	Stores  int64      // values written to the store
+	Deletes int64      // deletes sent to the store
+	Batches int64      // write-behind batches flushed
+	Retries int64      // retried write-behind batches
+	Dropped int64      // dirty entries given up on after the last retry
+	Dirty   int        // entries waiting to be flushed
+}
+
+// dirtyEntry is the latest write to a key that has not reached the store yet
+type dirtyEntry[V any] struct {
+	value    V
+	deleted  bool
+	version  uint64 // bumped on every write, to detect writes during a flush
+	queued   bool   // waiting in the flush queue
+	inFlight bool   // being written by a worker
+}
+
+// errStoreCacheClosed is returned by writes to a closed StoreCache
+var errStoreCacheClosed = errors.New("cache: store cache is closed")
+
+// storeStripes is the number of per-key locks ordering cache and store updates
+const storeStripes = 64
+
+// StoreCache is a Cache kept consistent with a backing Store. Misses are read
+// through from the store and writes reach it either synchronously
+// (WriteThrough) or asynchronously (WriteBehind).
+type StoreCache[K comparable, V any] struct {
+	cache *Cache[K, V]
+	store Store[K, V]
+	opts  StoreOptions
+
+	// stripes serialize operations on the same key so the cache and the store
+	// see writes in the same order. Close takes all of them to wait out writes
+	// that have already checked closed.
+	stripes [storeStripes]sync.Mutex
+	cloner  cloning[V] // clones on Put and on loads, set by SetCloner under every stripe
+
+	mu      sync.Mutex // guards dirty, queue and closed
+	dirty   map[K]*dirtyEntry[V]
+	queue   []K        // dirty keys in the order they should be flushed
+	idle    *sync.Cond // signalled whenever a batch finishes
+	closed  bool
+	version uint64
+
+	// evicted collects dirty keys evicted from the cache. It has its own lock
+	// because the eviction callback runs with the cache lock held.
+	evictMu sync.Mutex
+	evicted []K
+
+	wake chan struct{} // nudges a worker to flush now
+	stop chan struct{} // closed by Close
+	wg   sync.WaitGroup
+
+	loads, stores, deletes, batches, retries, dropped atomic.Int64
+}
+
+// NewStoreCache creates a cache with the given entry limit in front of store
+func NewStoreCache[K comparable, V any](entryLimit int, store Store[K, V], opts StoreOptions) *StoreCache[K, V] {
+	opts.setDefaults()
+
+	c := &StoreCache[K, V]{
+		cache: NewCache[K, V](entryLimit),
+		store: store,
+		opts:  opts,
+		dirty: make(map[K]*dirtyEntry[V]),
+		wake:  make(chan struct{}, 1),
+		stop:  make(chan struct{}),
+	}
+	c.idle = sync.NewCond(&c.mu)
+
+	if opts.Mode == WriteBehind {
+		c.cache.OnEvict(c.onEvict)
+		for i := 0; i < opts.Workers; i++ {
+			c.wg.Add(1)
+			go c.worker()
+		}
+	}
+
+	return c
+}
+
+// stripe returns the lock that orders operations on key
+func (c *StoreCache[K, V]) stripe(key K) *sync.Mutex {
+	return &c.stripes[anyToHash(key)&(storeStripes-1)]
+}
+
+// SetCloner makes the cache deep copy values with clone when mode says so.
+// A value passed to Put is cloned once, for both the cache and the store.
+func (c *StoreCache[K, V]) SetCloner(clone Cloner[V], mode CloneMode) {
+	for i := range c.stripes {
+		c.stripes[i].Lock()
+	}
+	defer func() {
+		for i := range c.stripes {
+			c.stripes[i].Unlock()
+		}
+	}()
+
+	c.cloner = cloning[V]{clone: clone, mode: mode}
+	// Hits are served by the inner cache alone; Put already cloned
+	c.cache.SetCloner(clone, mode&^CloneOnPut)
+}
+
+// Put stores the value and returns a boolean to indicate whether a value
+// already existed in the cache for that key. In WriteThrough mode the cache is
+// only updated once the store has accepted the value.
+func (c *StoreCache[K, V]) Put(key K, value V) (bool, error) {
+	mu := c.stripe(key)
+	mu.Lock()
+	defer mu.Unlock()
+
+	if err := c.checkOpen(); err != nil {
+		return false, err
+	}
+	value = c.cloner.onPut(value)
+
+	if c.opts.Mode == WriteThrough {
+		if err := c.store.Store(key, value); err != nil {
+			return false, err
+		}
+		c.stores.Add(1)
+		return c.cache.Put(key, value), nil
+	}
+
+	existed := c.cache.Put(key, value)
+	c.markDirty(key, value, false)
+	return existed, nil
+}
+
+// Delete removes the key from the cache and the store
+func (c *StoreCache[K, V]) Delete(key K) (bool, error) {
+	mu := c.stripe(key)
+	mu.Lock()
+	defer mu.Unlock()
+
+	if err := c.checkOpen(); err != nil {
+		return false, err
+	}
+
+	if c.opts.Mode == WriteThrough {
+		if err := c.store.Delete(key); err != nil {
+			return false, err
+		}
+		c.deletes.Add(1)
+		return c.cache.Delete(key), nil
+	}
+
+	existed := c.cache.Delete(key)
+	var zero V
+	c.markDirty(key, zero, true)
+	return existed, nil
+}
+
+// checkOpen returns an error once Close has been called. The caller must hold
+// the key's stripe, so Close can't start until the write is done.
+func (c *StoreCache[K, V]) checkOpen() error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.closed {
+		return errStoreCacheClosed
+	}
+	return nil
+}
+
+// Get returns the value associated with the passed key, loading it from the
+// store on a cache miss
+func (c *StoreCache[K, V]) Get(key K) (*V, bool, error) {
+	if value, found := c.cache.Get(key); found {
+		return value, true, nil
+	}
+
+	mu := c.stripe(key)
+	mu.Lock()
+	defer mu.Unlock()
+
+	// An evicted entry that hasn't been flushed yet is newer than the store
+	c.mu.Lock()
+	d, isDirty := c.dirty[key]
+	var value V
+	var deleted bool
+	if isDirty {
+		value, deleted = d.value, d.deleted
+	}
+	c.mu.Unlock()
+
+	if isDirty {
+		if deleted {
+			return nil, false, nil
+		}
+		c.cache.Put(key, value)
+		value = c.cloner.onGet(value)
+		return &value, true, nil
+	}
+
+	value, found, err := c.store.Load(key)
+	if err != nil {
+		return nil, false, err
+	}
+	if !found {
+		return nil, false, nil
+	}
+	c.loads.Add(1)
+
+	c.cache.Put(key, value)
+	value = c.cloner.onGet(value)
+	return &value, true, nil
+}
+
+// markDirty records a write-behind write and queues it for flushing
+func (c *StoreCache[K, V]) markDirty(key K, value V, deleted bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.version++
+	d, exists := c.dirty[key]
+	if !exists {
+		d = &dirtyEntry[V]{}
+		c.dirty[key] = d
+	}
+	d.value = value
+	d.deleted = deleted
+	d.version = c.version
+
+	// An in-flight entry is requeued by its worker once the flush completes
+	if !d.queued && !d.inFlight {
+		d.queued = true
+		c.queue = append(c.queue, key)
+	}
+
+	if len(c.queue) >= c.opts.BatchSize {
+		c.nudge()
+	}
+}
+
+// onEvict flushes dirty entries as soon as they leave the cache. It runs with
+// the cache lock held, so it only records the key and wakes a worker.
+func (c *StoreCache[K, V]) onEvict(key K, value V) {
+	c.evictMu.Lock()
+	c.evicted = append(c.evicted, key)
+	c.evictMu.Unlock()
+
+	c.nudge()
+}
+
+// nudge wakes a worker without blocking
+func (c *StoreCache[K, V]) nudge() {
+	select {
+	case c.wake <- struct{}{}:
+	default:
+	}
+}
+
+// worker flushes batches of dirty entries until the cache is closed
+func (c *StoreCache[K, V]) worker() {
+	defer c.wg.Done()
+
+	ticker := time.NewTicker(c.opts.FlushInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-c.wake:
+		case <-ticker.C:
+		case <-c.stop:
+			c.drain()
+			return
+		}
+		c.drain()
+	}
+}
+
+// drain flushes batches until nothing is left to take
+func (c *StoreCache[K, V]) drain() {
+	for {
+		batch := c.takeBatch()
+		if len(batch) == 0 {
+			return
+		}
+		c.flushBatch(batch)
+	}
+}
+
+// batchEntry is a dirty entry copied out for flushing
+type batchEntry[K comparable, V any] struct {
+	key     K
+	value   V
+	deleted bool
+	version uint64
+}
+
+// takeBatch removes up to BatchSize keys from the queue, evicted keys first,
+// and marks them in flight
+func (c *StoreCache[K, V]) takeBatch() []batchEntry[K, V] {
+	c.evictMu.Lock()
+	evicted := c.evicted
+	c.evicted = nil
+	c.evictMu.Unlock()
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	// Move evicted keys to the front of the queue
+	if len(evicted) > 0 {
+		front := make(map[K]bool, len(evicted))
+		reordered := make([]K, 0, len(c.queue))
+		for _, key := range evicted {
+			if d, exists := c.dirty[key]; exists && d.queued && !front[key] {
+				front[key] = true
+				reordered = append(reordered, key)
+			}
+		}
+		for _, key := range c.queue {
+			if !front[key] {
+				reordered = append(reordered, key)
+			}
+		}
+		c.queue = reordered
+	}
+
+	n := min(len(c.queue), c.opts.BatchSize)
+	batch := make([]batchEntry[K, V], 0, n)
+	for _, key := range c.queue[:n] {
+		d := c.dirty[key]
+		d.queued = false
+		d.inFlight = true
+		batch = append(batch, batchEntry[K, V]{key: key, value: d.value, deleted: d.deleted, version: d.version})
+	}
+	c.queue = c.queue[n:]
+
+	return batch
+}
+
+// flushBatch writes a batch to the store, retrying with exponential backoff.
+// Once the cache is closing, the remaining retries don't wait.
+func (c *StoreCache[K, V]) flushBatch(batch []batchEntry[K, V]) {
+	err := c.writeBatch(batch)
+	backoff := c.opts.RetryBackoff
+	for retry := 0; err != nil && retry < c.opts.MaxRetries; retry++ {
+		c.retries.Add(1)
+		timer := time.NewTimer(backoff)
+		select {
+		case <-timer.C:
+		case <-c.stop:
+			timer.Stop()
+		}
+		backoff *= 2
+		err = c.writeBatch(batch)
+	}
+
+	c.batches.Add(1)
+	if err != nil {
+		c.dropped.Add(int64(len(batch)))
+		if c.opts.OnError != nil {
+			c.opts.OnError(fmt.Errorf("cache: dropping %d dirty entries: %w", len(batch), err))
+		}
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	for _, e := range batch {
+		d := c.dirty[e.key]
+		d.inFlight = false
+		if d.version == e.version {
+			// Nothing was written while the batch was in flight
+			delete(c.dirty, e.key)
+		} else if !d.queued {
+			d.queued = true
+			c.queue = append(c.queue, e.key)
+		}
+	}
+	c.idle.Broadcast()
+}
+
+// writeBatch sends one batch to the store
+func (c *StoreCache[K, V]) writeBatch(batch []batchEntry[K, V]) error {
+	values := make(map[K]V, len(batch))
+	var errs []error
+	for _, e := range batch {
+		if e.deleted {
+			if err := c.store.Delete(e.key); err != nil {
+				errs = append(errs, err)
+				continue
+			}
+			c.deletes.Add(1)
+		} else {
+			values[e.key] = e.value
+		}
+	}
+	if len(values) == 0 {
+		return errors.Join(errs...)
+	}
+
+	if bs, ok := c.store.(BatchStore[K, V]); ok {
+		if err := bs.StoreBatch(values); err != nil {
+			return errors.Join(append(errs, err)...)
+		}
+		c.stores.Add(int64(len(values)))
+		return errors.Join(errs...)
+	}
+
+	for key, value := range values {
+		if err := c.store.Store(key, value); err != nil {
+			errs = append(errs, err)
+			continue
+		}
+		c.stores.Add(1)
+	}
+	return errors.Join(errs...)
+}
+
+// Flush writes every dirty entry to the store and waits until they are all
+// written or dropped
+func (c *StoreCache[K, V]) Flush() {
+	c.drain()
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	for len(c.dirty) > 0 {
+		if len(c.queue) > 0 {
+			// Rewritten while in flight and requeued; take it ourselves
+			c.mu.Unlock()
+			c.drain()
+			c.mu.Lock()
+			continue
+		}
+		c.idle.Wait()
+	}
+}
+
+// GetStatistics returns statistics about the cache and its store traffic
+func (c *StoreCache[K, V]) GetStatistics() StoreStatistics {
+	c.mu.Lock()
+	dirty := len(c.dirty)
+	c.mu.Unlock()
+
+	return StoreStatistics{
+		Cache:   c.cache.GetStatistics(),
+		Loads:   c.loads.Load(),
+		Stores:  c.stores.Load(),
+		Deletes: c.deletes.Load(),
+		Batches: c.batches.Load(),
+		Retries: c.retries.Load(),
+		Dropped: c.dropped.Load(),
+		Dirty:   dirty,
+	}
+}
+
+// Close flushes all dirty entries and stops the workers. Writes after Close
+// return an error. The error reports only entries dropped while closing;
+// batches dropped earlier already went to OnError.
+func (c *StoreCache[K, V]) Close() error {
+	for i := range c.stripes {
+		c.stripes[i].Lock()
+	}
+	c.mu.Lock()
+	closed := c.closed
+	c.closed = true
+	c.mu.Unlock()
+	for i := range c.stripes {
+		c.stripes[i].Unlock()
+	}
+	if closed {
+		return nil
+	}
+
+	before := c.dropped.Load()
+	close(c.stop)
+	c.wg.Wait()
+	c.Flush()
+
+	if dropped := c.dropped.Load() - before; dropped > 0 {
+		return fmt.Errorf("cache: %d dirty entries could not be written", dropped)
+	}
+	return nil
+}
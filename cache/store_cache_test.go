@@ -0,0 +1,344 @@
+package cache
+
+import (
+	"errors"
+	"fmt"
+	"sync"
+	"testing"
+	"time"
+)
+
+// memStore is an in-memory fake Store that can be told to fail
+type memStore struct {
+	mu       sync.Mutex
+	values   map[string]int
+	failures int // number of upcoming writes that should fail
+	writes   int
+	batches  int
+}
+
+func newMemStore() *memStore {
+	return &memStore{values: make(map[string]int)}
+}
+
+var errStoreDown = errors.New("store is down")
+
+func (s *memStore) Load(key string) (int, bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	value, found := s.values[key]
+	return value, found, nil
+}
+
+func (s *memStore) Store(key string, value int) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.failures > 0 {
+		s.failures--
+		return errStoreDown
+	}
+	s.values[key] = value
+	s.writes++
+	return nil
+}
+
+func (s *memStore) Delete(key string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	delete(s.values, key)
+	return nil
+}
+
+func (s *memStore) get(key string) (int, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	value, found := s.values[key]
+	return value, found
+}
+
+// batchMemStore adds StoreBatch to memStore
+type batchMemStore struct {
+	*memStore
+}
+
+func (s batchMemStore) StoreBatch(values map[string]int) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.failures > 0 {
+		s.failures--
+		return errStoreDown
+	}
+	for key, value := range values {
+		s.values[key] = value
+	}
+	s.writes += len(values)
+	s.batches++
+	return nil
+}
+
+func TestWriteThrough(t *testing.T) {
+	store := newMemStore()
+	cache := NewStoreCache[string, int](2, store, StoreOptions{Mode: WriteThrough})
+	defer cache.Close()
+
+	if _, err := cache.Put("one", 1); err != nil {
+		t.Fatal(err)
+	}
+	if value, found := store.get("one"); !found || value != 1 {
+		t.Errorf("Write-through Put should reach the store, got %d %v", value, found)
+	}
+
+	// A failed store write leaves the cache untouched
+	store.failures = 1
+	if _, err := cache.Put("one", 100); !errors.Is(err, errStoreDown) {
+		t.Errorf("Expected the store error, got %v", err)
+	}
+	val, _, _ := cache.Get("one")
+	if *val != 1 {
+		t.Errorf("Expected cached value 1 after failed Put, got %d", *val)
+	}
+
+	cache.Delete("one")
+	if _, found := store.get("one"); found {
+		t.Error("Write-through Delete should reach the store")
+	}
+}
+
+func TestReadThrough(t *testing.T) {
+	store := newMemStore()
+	store.values["one"] = 1
+	cache := NewStoreCache[string, int](2, store, StoreOptions{Mode: WriteThrough})
+	defer cache.Close()
+
+	val, found, err := cache.Get("one")
+	if err != nil || !found || *val != 1 {
+		t.Fatalf("Expected 'one' to be loaded from the store, got %v %v %v", val, found, err)
+	}
+	cache.Get("one") // now a cache hit
+
+	stats := cache.GetStatistics()
+	if stats.Loads != 1 || stats.Cache.Hits != 1 {
+		t.Errorf("Expected 1 load and 1 cache hit, got %d and %d", stats.Loads, stats.Cache.Hits)
+	}
+
+	if _, found, _ := cache.Get("missing"); found {
+		t.Error("Key 'missing' is in neither the cache nor the store")
+	}
+}
+
+func TestWriteBehindBatching(t *testing.T) {
+	store := batchMemStore{newMemStore()}
+	cache := NewStoreCache[string, int](100, store, StoreOptions{
+		Mode:          WriteBehind,
+		BatchSize:     10,
+		FlushInterval: time.Hour, // only flush when a batch fills up or on demand
+	})
+	defer cache.Close()
+
+	for i := 0; i < 25; i++ {
+		cache.Put(fmt.Sprintf("key%d", i), i)
+	}
+	cache.Delete("key0")
+	cache.Flush()
+
+	if _, found := store.get("key0"); found {
+		t.Error("Deleted key 'key0' should not be in the store")
+	}
+	for i := 1; i < 25; i++ {
+		if value, found := store.get(fmt.Sprintf("key%d", i)); !found || value != i {
+			t.Errorf("Expected key%d=%d in the store, got %d %v", i, i, value, found)
+		}
+	}
+
+	stats := cache.GetStatistics()
+	if stats.Dirty != 0 {
+		t.Errorf("Expected no dirty entries after Flush, got %d", stats.Dirty)
+	}
+	if store.batches == 0 || store.batches > 4 {
+		t.Errorf("Expected the writes to be batched, got %d batches", store.batches)
+	}
+}
+
+func TestWriteBehindRetry(t *testing.T) {
+	store := newMemStore()
+	store.failures = 2
+	cache := NewStoreCache[string, int](10, store, StoreOptions{
+		Mode:          WriteBehind,
+		FlushInterval: time.Hour,
+		RetryBackoff:  time.Millisecond,
+	})
+	defer cache.Close()
+
+	cache.Put("one", 1)
+	cache.Flush()
+
+	if value, found := store.get("one"); !found || value != 1 {
+		t.Errorf("Expected 'one' to be written after retrying, got %d %v", value, found)
+	}
+	if stats := cache.GetStatistics(); stats.Retries != 2 || stats.Dropped != 0 {
+		t.Errorf("Expected 2 retries and nothing dropped, got %d and %d", stats.Retries, stats.Dropped)
+	}
+
+	// Give up once the retries run out
+	reported := make(chan error, 1)
+	store.failures = 10
+	giveUp := NewStoreCache[string, int](10, store, StoreOptions{
+		Mode:          WriteBehind,
+		FlushInterval: time.Hour,
+		MaxRetries:    1,
+		RetryBackoff:  time.Millisecond,
+		OnError:       func(err error) { reported <- err },
+	})
+	defer giveUp.Close()
+
+	giveUp.Put("two", 2)
+	giveUp.Flush()
+
+	if err := <-reported; !errors.Is(err, errStoreDown) {
+		t.Errorf("Expected the dropped batch to be reported, got %v", err)
+	}
+	if stats := giveUp.GetStatistics(); stats.Dropped != 1 {
+		t.Errorf("Expected 1 dropped entry, got %d", stats.Dropped)
+	}
+
+	// Once the store is back, the earlier drop doesn't fail a clean Close
+	store.mu.Lock()
+	store.failures = 0
+	store.mu.Unlock()
+	giveUp.Put("three", 3)
+	if err := giveUp.Close(); err != nil {
+		t.Errorf("Expected Close to flush cleanly, got %v", err)
+	}
+}
+
+func TestWriteBehindFlushOnEviction(t *testing.T) {
+	store := newMemStore()
+	cache := NewStoreCache[string, int](1, store, StoreOptions{
+		Mode:          WriteBehind,
+		FlushInterval: time.Hour,
+	})
+	defer cache.Close()
+
+	cache.Put("one", 1)
+	cache.Put("two", 2) // evicts dirty "one"
+
+	// An evicted entry is still readable before it is flushed
+	val, found, _ := cache.Get("one")
+	if !found || *val != 1 {
+		t.Errorf("Expected evicted dirty 'one'=1, got %v %v", val, found)
+	}
+
+	deadline := time.Now().Add(time.Second)
+	for {
+		if value, found := store.get("one"); found && value == 1 {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatal("Evicted entry 'one' was not flushed")
+		}
+		time.Sleep(time.Millisecond)
+	}
+}
+
+func TestWriteBehindClose(t *testing.T) {
+	store := newMemStore()
+	cache := NewStoreCache[string, int](50, store, StoreOptions{Mode: WriteBehind})
+
+	var wg sync.WaitGroup
+	numWorkers := 8
+	opsPerWorker := 200
+
+	for i := 0; i < numWorkers; i++ {
+		wg.Add(1)
+		go func(workerID int) {
+			defer wg.Done()
+
+			for j := 0; j < opsPerWorker; j++ {
+				key := fmt.Sprintf("worker%d-key%d", workerID, j%10)
+				cache.Put(key, j)
+				cache.Get(key)
+			}
+		}(i)
+	}
+	wg.Wait()
+
+	if err := cache.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	// Every key holds the last value its worker wrote
+	for i := 0; i < numWorkers; i++ {
+		for k := 0; k < 10; k++ {
+			want := opsPerWorker - 10 + k
+			if value, found := store.get(fmt.Sprintf("worker%d-key%d", i, k)); !found || value != want {
+				t.Errorf("Expected worker%d-key%d=%d in the store, got %d %v", i, k, want, value, found)
+			}
+		}
+	}
+
+	if _, err := cache.Put("late", 1); err == nil {
+		t.Error("Put after Close should fail")
+	}
+}
+
+func TestStoreCacheWritesAfterClose(t *testing.T) {
+	for _, mode := range []WriteMode{WriteThrough, WriteBehind} {
+		store := newMemStore()
+		cache := NewStoreCache[string, int](10, store, StoreOptions{Mode: mode})
+		cache.Put("one", 1)
+		if err := cache.Close(); err != nil {
+			t.Fatal(err)
+		}
+
+		if _, err := cache.Put("one", 100); err != errStoreCacheClosed {
+			t.Errorf("mode %d: expected Put after Close to fail, got %v", mode, err)
+		}
+		if _, err := cache.Delete("one"); err != errStoreCacheClosed {
+			t.Errorf("mode %d: expected Delete after Close to fail, got %v", mode, err)
+		}
+
+		// Neither the cache nor the store sees the rejected writes
+		if value, found, _ := cache.Get("one"); !found || *value != 1 {
+			t.Errorf("mode %d: expected the cache to still hold one=1, got %v %v", mode, value, found)
+		}
+		if value, found := store.get("one"); !found || value != 1 {
+			t.Errorf("mode %d: expected the store to still hold one=1, got %d %v", mode, value, found)
+		}
+	}
+}
+
+func TestWriteBehindCloseInterruptsBackoff(t *testing.T) {
+	store := newMemStore()
+	store.failures = 10
+	cache := NewStoreCache[string, int](10, store, StoreOptions{
+		Mode:          WriteBehind,
+		FlushInterval: time.Millisecond,
+		RetryBackoff:  time.Hour,
+	})
+	cache.Put("one", 1)
+
+	// Wait for a worker to start backing off
+	deadline := time.Now().Add(5 * time.Second)
+	for cache.GetStatistics().Retries == 0 {
+		if time.Now().After(deadline) {
+			t.Fatal("The failed batch was never retried")
+		}
+		time.Sleep(time.Millisecond)
+	}
+
+	closed := make(chan error, 1)
+	go func() { closed <- cache.Close() }()
+	select {
+	case err := <-closed:
+		if err == nil {
+			t.Error("Expected Close to report the dropped entry")
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("Close waited out the retry backoff")
+	}
+}
@@ -0,0 +1,254 @@
+package cache
+
+import (
+	"sync"
+	"sync/atomic"
+)
+
+// TierCache is the behaviour TieredCache needs from its in-memory L1 tier.
+// Cache and ShardedCache both satisfy it.
+type TierCache[K comparable, V any] interface {
+	Put(key K, value V) bool
+	Get(key K) (*V, bool)
+	GetStatistics() Statistics
+	OnEvict(fn func(key K, value V))
+}
+
+// TieredCache combines an in-memory L1 cache with a file-backed L2 store for
+// working sets that don't fit in RAM. Entries evicted from L1 are demoted to
+// L2 and an L2 hit promotes the entry back into L1, so every key lives in
+// exactly one tier at a time.
+type TieredCache[K comparable, V any] struct {
+	// mu is held shared by Get and exclusively by Put, so a Put can never
+	// interleave with a promotion of the same key
+	mu     sync.RWMutex
+	l1     TierCache[K, V]
+	l2     *diskStore[K, V]
+	cloner cloning[V] // clones on Put and on promotion, set by SetCloner
+
+	// Entries evicted from L1 are queued under demoteMu, which the eviction
+	// callback can take with the L1 lock held, and written to L2 afterwards
+	// under flushMu, so L1 never waits for the disk
+	demoteMu sync.Mutex
+	demoting []tieredEntry[K, V]
+	flushMu  sync.Mutex
+
+	// promoting single-flights L2 lookups, since the first Get to take an
+	// entry from L2 removes it for the others
+	promoteMu sync.Mutex
+	promoting map[K]*promotion[V]
+
+	promotions atomic.Int64
+	demotions  atomic.Int64
+	errors     atomic.Int64
+}
+
+// tieredEntry is an entry evicted from L1 that hasn't been written to L2 yet
+type tieredEntry[K comparable, V any] struct {
+	key   K
+	value V
+}
+
+// promotion is an L2 lookup in progress, which other Gets of the key wait for
+type promotion[V any] struct {
+	done  chan struct{} // closed once value and found are set
+	value V
+	found bool
+}
+
+// TieredStatistics breaks cache statistics down by tier
+type TieredStatistics struct {
+	L1               Statistics // statistics of the in-memory tier
+	L2               Statistics // statistics of the on-disk tier, which only sees L1 misses
+	Promotions       int64      // entries moved from L2 back to L1 on a hit
+	Demotions        int64      // entries moved from L1 to L2 on eviction
+	Errors           int64      // failed disk reads or writes; the entry is dropped
+	L2Entries        int        // entries currently stored on disk
+	L2Bytes          int64      // bytes used by the segment files, including garbage
+	CompactionErrors int64      // automatic compactions that failed; L2 keeps its old segments
+}
+
+// NewTieredCache creates a tiered cache using l1 as the in-memory tier and the
+// directory dir for the on-disk tier. The tiered cache takes over l1's eviction
+// callback, so l1 should not be used directly afterwards.
+func NewTieredCache[K comparable, V any](l1 TierCache[K, V], dir string) (*TieredCache[K, V], error) {
+	l2, err := openDiskStore[K, V](dir)
+	if err != nil {
+		return nil, err
+	}
+
+	c := &TieredCache[K, V]{
+		l1:        l1,
+		l2:        l2,
+		promoting: make(map[K]*promotion[V]),
+	}
+	l1.OnEvict(c.demote)
+
+	return c, nil
+}
+
+// demote queues an entry evicted from L1 for flushDemotions. It runs with the
+// L1 lock held, so it must not touch the disk.
+func (c *TieredCache[K, V]) demote(key K, value V) {
+	c.demoteMu.Lock()
+	defer c.demoteMu.Unlock()
+
+	c.demoting = append(c.demoting, tieredEntry[K, V]{key: key, value: value})
+}
+
+// flushDemotions writes the queued demotions to L2. Only one caller writes at
+// a time, and the others wait for it, so once this returns every entry evicted
+// before the call is on disk. The caller must hold c.mu in either mode.
+func (c *TieredCache[K, V]) flushDemotions() {
+	c.flushMu.Lock()
+	defer c.flushMu.Unlock()
+
+	c.demoteMu.Lock()
+	queued := c.demoting
+	c.demoting = nil
+	c.demoteMu.Unlock()
+
+	for _, e := range queued {
+		if err := c.l2.put(e.key, e.value); err != nil {
+			c.errors.Add(1)
+			continue
+		}
+		c.demotions.Add(1)
+	}
+}
+
+// SetCloner makes the cache deep copy values with clone when mode says so.
+// L1 clones its own hits if it has a SetCloner, as every cache in this
+// package does.
+func (c *TieredCache[K, V]) SetCloner(clone Cloner[V], mode CloneMode) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.cloner = cloning[V]{clone: clone, mode: mode}
+	if l1, ok := c.l1.(interface{ SetCloner(Cloner[V], CloneMode) }); ok {
+		// Put already cloned
+		l1.SetCloner(clone, mode&^CloneOnPut)
+	}
+}
+
+// Put adds the value to L1 and returns a boolean to indicate whether a value
+// already existed for that key in either tier
+func (c *TieredCache[K, V]) Put(key K, value V) bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	existed := c.l1.Put(key, c.cloner.onPut(value))
+	c.flushDemotions()
+
+	// Drop any stale copy from disk, including one just flushed
+	onDisk, err := c.l2.delete(key)
+	if err != nil {
+		c.errors.Add(1)
+	}
+
+	return existed || onDisk
+}
+
+// Get returns the value associated with the passed key, looking in L1 first
+// and promoting the entry out of L2 on a disk hit
+func (c *TieredCache[K, V]) Get(key K) (*V, bool) {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	promoted := c.promotions.Load()
+	if value, found := c.l1.Get(key); found {
+		return value, true
+	}
+	return c.promote(key, promoted)
+}
+
+// promote takes key from L2 after an L1 miss and moves it back to L1. Only one
+// Get of a key looks in L2 at a time; the others wait for its result.
+// promoted is the promotion count read before the L1 miss.
+func (c *TieredCache[K, V]) promote(key K, promoted int64) (*V, bool) {
+	c.promoteMu.Lock()
+	if p, busy := c.promoting[key]; busy {
+		c.promoteMu.Unlock()
+		<-p.done
+		if !p.found {
+			return nil, false
+		}
+		value := c.cloner.onGet(p.value)
+		return &value, true
+	}
+	p := &promotion[V]{done: make(chan struct{})}
+	c.promoting[key] = p
+	c.promoteMu.Unlock()
+
+	defer func() {
+		c.promoteMu.Lock()
+		delete(c.promoting, key)
+		c.promoteMu.Unlock()
+		close(p.done)
+	}()
+
+	// The key may have been evicted but not written out yet
+	c.flushDemotions()
+
+	value, found, err := c.l2.take(key)
+	if err != nil {
+		c.errors.Add(1)
+		return nil, false
+	}
+	if !found {
+		// A promotion that finished after the L1 miss may have moved it back
+		if c.promotions.Load() != promoted {
+			if v, found := c.l1.Get(key); found {
+				p.value, p.found = *v, true
+				return v, true
+			}
+		}
+		return nil, false
+	}
+
+	// Promote back to L1; this may demote another entry in turn
+	c.l1.Put(key, value)
+	c.promotions.Add(1)
+	c.flushDemotions()
+
+	p.value, p.found = value, true
+	value = c.cloner.onGet(value)
+	return &value, true
+}
+
+// GetStatistics returns statistics about both tiers
+func (c *TieredCache[K, V]) GetStatistics() TieredStatistics {
+	return TieredStatistics{
+		L1:               c.l1.GetStatistics(),
+		L2:               c.l2.statistics(),
+		Promotions:       c.promotions.Load(),
+		Demotions:        c.demotions.Load(),
+		Errors:           c.errors.Load(),
+		L2Entries:        c.l2.len(),
+		L2Bytes:          c.l2.size(),
+		CompactionErrors: c.l2.compactionErrors(),
+	}
+}
+
+// GetHitRate calculates the hit rate across both tiers
+func (s *TieredStatistics) GetHitRate() float64 {
+	if s.L1.Reads == 0 {
+		return 0
+	}
+	return float64(s.L1.Hits+s.L2.Hits) / float64(s.L1.Reads)
+}
+
+// Compact reclaims the disk space used by overwritten and promoted entries.
+// Compaction also runs automatically once half the bytes on disk are garbage.
+func (c *TieredCache[K, V]) Compact() error {
+	return c.l2.compact()
+}
+
+// Close closes the segment files. Entries still in L1 are not written to disk.
+func (c *TieredCache[K, V]) Close() error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.flushDemotions()
+	return c.l2.close()
+}
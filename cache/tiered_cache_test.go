@@ -0,0 +1,258 @@
+package cache
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestTieredCacheDemotionAndPromotion(t *testing.T) {
+	cache, err := NewTieredCache[string, int](NewCache[string, int](2), t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer cache.Close()
+
+	cache.Put("one", 1)
+	cache.Put("two", 2)
+	cache.Put("three", 3) // Should demote "one" to disk
+
+	stats := cache.GetStatistics()
+	if stats.Demotions != 1 || stats.L2Entries != 1 {
+		t.Fatalf("Expected 1 demotion and 1 entry on disk, got %d and %d", stats.Demotions, stats.L2Entries)
+	}
+
+	// An L2 hit promotes "one" and demotes "two"
+	val, found := cache.Get("one")
+	if !found || *val != 1 {
+		t.Fatalf("Expected to find 'one' on disk, got %v %v", val, found)
+	}
+
+	stats = cache.GetStatistics()
+	if stats.Promotions != 1 || stats.Demotions != 2 {
+		t.Errorf("Expected 1 promotion and 2 demotions, got %d and %d", stats.Promotions, stats.Demotions)
+	}
+	if stats.L1.Misses != 1 || stats.L2.Hits != 1 {
+		t.Errorf("Expected 1 L1 miss and 1 L2 hit, got %d and %d", stats.L1.Misses, stats.L2.Hits)
+	}
+
+	// Every key is still reachable from one of the tiers
+	for key, want := range map[string]int{"one": 1, "two": 2, "three": 3} {
+		val, found := cache.Get(key)
+		if !found || *val != want {
+			t.Errorf("Expected %s=%d, got %v %v", key, want, val, found)
+		}
+	}
+
+	_, found = cache.Get("missing")
+	if found {
+		t.Error("Key 'missing' should not be found")
+	}
+	stats = cache.GetStatistics()
+	if stats.L2.Misses != 1 {
+		t.Errorf("Expected 1 L2 miss, got %d", stats.L2.Misses)
+	}
+}
+
+func TestTieredCachePutReplacesDiskCopy(t *testing.T) {
+	cache, err := NewTieredCache[string, int](NewCache[string, int](1), t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer cache.Close()
+
+	cache.Put("one", 1)
+	cache.Put("two", 2) // "one" now lives on disk
+
+	if existed := cache.Put("one", 100); !existed {
+		t.Error("Put should report that 'one' existed on disk")
+	}
+
+	val, found := cache.Get("one")
+	if !found || *val != 100 {
+		t.Errorf("Expected updated value 100, got %v %v", val, found)
+	}
+}
+
+func TestTieredCacheReopen(t *testing.T) {
+	dir := t.TempDir()
+
+	cache, err := NewTieredCache[string, int](NewCache[string, int](1), dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cache.Put("one", 1)
+	cache.Put("two", 2)
+	cache.Put("three", 3)
+	cache.Get("one") // promote "one", so its disk record is deleted
+	if err := cache.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	// Simulate a crash half way through a write
+	segments, err := filepath.Glob(filepath.Join(dir, "*.seg"))
+	if err != nil || len(segments) == 0 {
+		t.Fatalf("Expected segment files, got %v %v", segments, err)
+	}
+	f, err := os.OpenFile(segments[len(segments)-1], os.O_APPEND|os.O_WRONLY, 0o644)
+	if err != nil {
+		t.Fatal(err)
+	}
+	f.Write([]byte{42, 0, 0, 0, 1, 2})
+	f.Close()
+
+	cache, err = NewTieredCache[string, int](NewCache[string, int](1), dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer cache.Close()
+
+	stats := cache.GetStatistics()
+	if stats.L2Entries != 2 {
+		t.Fatalf("Expected 2 entries to survive on disk, got %d", stats.L2Entries)
+	}
+	if _, found := cache.Get("two"); !found {
+		t.Error("Key 'two' should have survived on disk")
+	}
+	if _, found := cache.Get("one"); found {
+		t.Error("Key 'one' was promoted before closing and should not be resurrected")
+	}
+}
+
+func TestTieredCacheDemotesOutsideL1Lock(t *testing.T) {
+	cache, err := NewTieredCache[int, int](NewCache[int, int](1), t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer cache.Close()
+
+	// Hold the disk store's lock as a long compaction would; evicting from
+	// L1 must still not wait for it
+	cache.l2.mu.Lock()
+	evicted := make(chan struct{})
+	go func() {
+		cache.l1.Put(1, 1)
+		cache.l1.Put(2, 2) // evicts key 1
+		close(evicted)
+	}()
+	select {
+	case <-evicted:
+	case <-time.After(5 * time.Second):
+		t.Fatal("Eviction blocked on the disk store")
+	}
+	cache.l2.mu.Unlock()
+
+	// The queued demotion is written out before L2 is searched
+	if value, found := cache.Get(1); !found || *value != 1 {
+		t.Errorf("Expected the demoted key 1 to be found, got %v %v", value, found)
+	}
+	if stats := cache.GetStatistics(); stats.Demotions != 2 || stats.L2.Hits != 1 {
+		t.Errorf("Expected 2 demotions and 1 L2 hit, got %d and %d", stats.Demotions, stats.L2.Hits)
+	}
+}
+
+func TestTieredCacheConcurrentPromotion(t *testing.T) {
+	cache, err := NewTieredCache[int, int](NewCache[int, int](1), t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer cache.Close()
+
+	cache.Put(1, 1)
+	cache.Put(2, 2) // demotes key 1
+
+	// Hold the disk store so every Get misses L1 before any takes key 1
+	// from L2; the ones that don't take it must still find it
+	cache.l2.mu.Lock()
+	var wg sync.WaitGroup
+	for i := 0; i < 4; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if value, found := cache.Get(1); !found || *value != 1 {
+				t.Errorf("Expected key 1=1, got %v %v", value, found)
+			}
+		}()
+	}
+	time.Sleep(50 * time.Millisecond)
+	cache.l2.mu.Unlock()
+	wg.Wait()
+
+	if stats := cache.GetStatistics(); stats.Promotions != 1 || stats.L2.Reads != 1 {
+		t.Errorf("Expected one L2 lookup to promote key 1, got %d promotions and %d L2 reads", stats.Promotions, stats.L2.Reads)
+	}
+}
+
+func TestTieredCacheCompaction(t *testing.T) {
+	cache, err := NewTieredCache[int, int](NewCache[int, int](1), t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer cache.Close()
+	cache.l2.maxSegmentSize = 1024 // Force several segments
+
+	// Bounce the same few keys between the tiers to create garbage
+	for i := 0; i < 200; i++ {
+		cache.Put(i%4, i)
+	}
+
+	before := cache.GetStatistics()
+	if err := cache.Compact(); err != nil {
+		t.Fatal(err)
+	}
+	after := cache.GetStatistics()
+
+	if after.L2Bytes >= before.L2Bytes {
+		t.Errorf("Expected compaction to shrink the store, had %d bytes and now %d", before.L2Bytes, after.L2Bytes)
+	}
+	if after.L2Entries != before.L2Entries {
+		t.Errorf("Compaction should keep %d entries, got %d", before.L2Entries, after.L2Entries)
+	}
+	for key := 0; key < 4; key++ {
+		val, found := cache.Get(key)
+		if !found || *val != 196+key {
+			t.Errorf("Expected key %d=%d after compaction, got %v %v", key, 196+key, val, found)
+		}
+	}
+}
+
+func TestConcurrentTieredCache(t *testing.T) {
+	cache, err := NewTieredCache[string, int](NewShardedCache[string, int](16, 4), t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer cache.Close()
+
+	var wg sync.WaitGroup
+	numWorkers := 8
+	opsPerWorker := 200
+
+	for i := 0; i < numWorkers; i++ {
+		wg.Add(1)
+		go func(workerID int) {
+			defer wg.Done()
+
+			for j := 0; j < opsPerWorker; j++ {
+				key := fmt.Sprintf("worker%d-key%d", workerID, (j/2)%20)
+				if j%2 == 0 {
+					cache.Put(key, j)
+				} else if val, found := cache.Get(key); !found || *val != j-1 {
+					t.Errorf("Expected %s=%d, got %v %v", key, j-1, val, found)
+				}
+			}
+		}(i)
+	}
+
+	wg.Wait()
+
+	stats := cache.GetStatistics()
+	if stats.Errors != 0 {
+		t.Errorf("Expected no disk errors, got %d", stats.Errors)
+	}
+	if stats.L1.Reads != int64(numWorkers*opsPerWorker/2) {
+		t.Errorf("Expected %d reads, got %d", numWorkers*opsPerWorker/2, stats.L1.Reads)
+	}
+}
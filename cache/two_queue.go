@@ -0,0 +1,260 @@
+package cache
+
+import (
+	"fmt"
+	"sync"
+)
+
+// Default shares of the entry limit given to the 2Q queues, as suggested by
+// Johnson and Shasha
+const (
+	twoQueueInRatio    = 0.25 // resident entries A1in may hold
+	twoQueueGhostRatio = 0.5  // keys A1out remembers, on top of the entry limit
+)
+
+// TwoQueueCache implements a thread safe 2Q cache. New entries go into A1in,
+// a FIFO that a read doesn't reorder. Keys pushed out of A1in are remembered
+// without their values in A1out, and a key written again while it's there
+// was evidently reused, so it goes into Am, an LRU of entries seen twice.
+// One-hit wonders pass through A1in and never disturb Am.
+type TwoQueueCache[K comparable, V any] struct {
+	mu         sync.Mutex
+	entryLimit int
+	inLimit    int                // entries A1in keeps when Am wants room
+	ghostLimit int                // keys A1out remembers
+	items      map[K]*entry[K, V] // resident entries, on in or main
+	ghosts     map[K]*entry[K, V] // entries on ghost, with their values dropped
+	in         entryList[K, V]    // A1in
+	main       entryList[K, V]    // Am
+	ghost      entryList[K, V]    // A1out
+	stats      *Statistics
+	cloner     cloning[V] // deep copies values on Put and Get, set by SetCloner
+}
+
+// NewTwoQueueCache creates a 2Q cache with the given entry limit. A1in holds
+// a quarter of the entries once the cache is full, and A1out remembers as
+// many keys as half the entry limit.
+func NewTwoQueueCache[K comparable, V any](entryLimit int) *TwoQueueCache[K, V] {
+	return &TwoQueueCache[K, V]{
+		entryLimit: entryLimit,
+		inLimit:    max(1, int(float64(entryLimit)*twoQueueInRatio)),
+		ghostLimit: max(1, int(float64(entryLimit)*twoQueueGhostRatio)),
+		items:      make(map[K]*entry[K, V]),
+		ghosts:     make(map[K]*entry[K, V]),
+		stats:      newStatistics(),
+	}
+}
+
+// SetCloner makes the cache deep copy values with clone when mode says so
+func (c *TwoQueueCache[K, V]) SetCloner(clone Cloner[V], mode CloneMode) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.cloner = cloning[V]{clone: clone, mode: mode}
+}
+
+// segmentLocked returns the list of a resident entry's segment
+func (c *TwoQueueCache[K, V]) segmentLocked(e *entry[K, V]) *entryList[K, V] {
+	if e.segment == segmentMain {
+		return &c.main
+	}
+	return &c.in
+}
+
+// Put adds the value to the cache, and returns a boolean to indicate whether
+// a value already existed in the cache for that key
+func (c *TwoQueueCache[K, V]) Put(key K, value V) bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if debugValidate {
+		defer c.debugCheckLocked()
+	}
+
+	c.stats.IncrementWrites()
+	value = c.cloner.onPut(value)
+
+	if e, exists := c.items[key]; exists {
+		e.value = value
+		e.readAfterWrite = false
+		if e.segment == segmentMain {
+			c.main.moveToFront(e)
+		}
+		return true
+	}
+
+	// A key remembered in A1out has been seen before, so it goes to Am
+	e, remembered := c.ghosts[key]
+	if remembered {
+		c.ghost.remove(e)
+		delete(c.ghosts, key)
+	}
+
+	if len(c.items) >= c.entryLimit && len(c.items) > 0 {
+		c.reclaimLocked()
+	}
+
+	e = &entry[K, V]{key: key, value: value, segment: segmentIn}
+	c.items[key] = e
+	if remembered {
+		e.segment = segmentMain
+		c.main.addToFront(e)
+	} else {
+		c.in.addToFront(e)
+	}
+	return false
+}
+
+// reclaimLocked evicts one entry. A1in gives up its oldest entry, whose key
+// moves to A1out, while it's over its share or Am is empty; otherwise Am
+// evicts its least recently used entry, which is forgotten.
+func (c *TwoQueueCache[K, V]) reclaimLocked() {
+	var victim *entry[K, V]
+	if c.in.len > c.inLimit || c.main.len == 0 {
+		victim = c.in.removeLast()
+	} else {
+		victim = c.main.removeLast()
+	}
+
+	if !victim.readAfterWrite {
+		c.stats.IncrementNeverRead()
+	}
+	delete(c.items, victim.key)
+	c.stats.IncrementEvictions()
+
+	if victim.segment == segmentIn {
+		c.rememberLocked(victim)
+	}
+}
+
+// rememberLocked puts an entry evicted from A1in on A1out, dropping the
+// oldest remembered key if A1out is full
+func (c *TwoQueueCache[K, V]) rememberLocked(e *entry[K, V]) {
+	if c.ghost.len >= c.ghostLimit {
+		forgotten := c.ghost.removeLast()
+		delete(c.ghosts, forgotten.key)
+	}
+
+	var zero V
+	*e = entry[K, V]{key: e.key, value: zero, segment: segmentGhost}
+	c.ghosts[e.key] = e
+	c.ghost.addToFront(e)
+}
+
+// Get returns a copy of the value associated with key
+func (c *TwoQueueCache[K, V]) Get(key K) (*V, bool) {
+	value, found := c.GetValue(key)
+	if !found {
+		return nil, false
+	}
+	return &value, true
+}
+
+// GetValue is Get returning the copy of the value itself. A key that's only
+// remembered in A1out is a miss.
+func (c *TwoQueueCache[K, V]) GetValue(key K) (V, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if debugValidate {
+		defer c.debugCheckLocked()
+	}
+
+	c.stats.IncrementReads()
+
+	e, exists := c.items[key]
+	if !exists {
+		c.stats.IncrementMisses()
+		var zero V
+		return zero, false
+	}
+
+	// Reads leave A1in in insertion order
+	e.accessCount++
+	e.readAfterWrite = true
+	if e.segment == segmentMain {
+		c.main.moveToFront(e)
+	}
+
+	c.stats.IncrementHits()
+	return c.cloner.onGet(e.value), true
+}
+
+// Delete removes the key from the cache, and returns a boolean to indicate
+// whether a value existed for that key. The key is forgotten from A1out too.
+func (c *TwoQueueCache[K, V]) Delete(key K) bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if debugValidate {
+		defer c.debugCheckLocked()
+	}
+
+	if e, remembered := c.ghosts[key]; remembered {
+		c.ghost.remove(e)
+		delete(c.ghosts, key)
+	}
+
+	e, exists := c.items[key]
+	if !exists {
+		return false
+	}
+
+	c.segmentLocked(e).remove(e)
+	delete(c.items, key)
+	c.stats.IncrementDeletes()
+
+	return true
+}
+
+// GetStatistics returns consistent statistics about the cache
+func (c *TwoQueueCache[K, V]) GetStatistics() Statistics {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	stats := *c.stats
+	entryStatistics(c.items, &stats)
+	return stats
+}
+
+// Validate checks the internal invariants of the cache: every resident item
+// is on A1in or Am as its segment says, every remembered key is on A1out and
+// not resident, and no limit is exceeded
+func (c *TwoQueueCache[K, V]) Validate() error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	return c.validateLocked()
+}
+
+func (c *TwoQueueCache[K, V]) validateLocked() error {
+	if len(c.items) > c.entryLimit {
+		return fmt.Errorf("%w: %d entries exceed the limit of %d", errInvalid, len(c.items), c.entryLimit)
+	}
+	if c.ghost.len > c.ghostLimit {
+		return fmt.Errorf("%w: %d remembered keys exceed the limit of %d", errInvalid, c.ghost.len, c.ghostLimit)
+	}
+	if n := c.in.len + c.main.len; n != len(c.items) {
+		return fmt.Errorf("%w: %d items but the queues count %d entries", errInvalid, len(c.items), n)
+	}
+	if c.ghost.len != len(c.ghosts) {
+		return fmt.Errorf("%w: %d remembered keys but A1out counts %d", errInvalid, len(c.ghosts), c.ghost.len)
+	}
+	for key := range c.ghosts {
+		if _, resident := c.items[key]; resident {
+			return fmt.Errorf("%w: key %v is both cached and remembered", errInvalid, key)
+		}
+	}
+
+	if err := c.in.validateSegment(c.items, segmentIn); err != nil {
+		return err
+	}
+	if err := c.main.validateSegment(c.items, segmentMain); err != nil {
+		return err
+	}
+	return c.ghost.validateSegment(c.ghosts, segmentGhost)
+}
+
+// debugCheckLocked panics if the cache is invalid; the caller must hold c.mu
+func (c *TwoQueueCache[K, V]) debugCheckLocked() {
+	if err := c.validateLocked(); err != nil {
+		panic(err)
+	}
+}
@@ -0,0 +1,140 @@
+package cache
+
+import (
+	"errors"
+	"math/rand/v2"
+	"sync"
+	"testing"
+)
+
+func TestTwoQueueCacheGhostHit(t *testing.T) {
+	// A1in keeps 2 entries once Am needs room, and A1out remembers 4 keys
+	c := NewTwoQueueCache[int, int](8)
+
+	for i := 0; i < 8; i++ {
+		c.Put(i, i)
+	}
+	c.Put(8, 8) // Am is empty, so A1in gives up 0
+	if _, found := c.Get(0); found {
+		t.Fatal("Key 0 should have been evicted from A1in")
+	}
+	if _, remembered := c.ghosts[0]; !remembered {
+		t.Fatal("Key 0 should be remembered in A1out")
+	}
+
+	// Writing 0 again brings it back straight into Am
+	if c.Put(0, 100) {
+		t.Error("A remembered key has no value, so Put should report it as new")
+	}
+	if e := c.items[0]; e == nil || e.segment != segmentMain {
+		t.Fatal("Key 0 should be cached in Am")
+	}
+	if _, remembered := c.ghosts[0]; remembered {
+		t.Error("Key 0 should no longer be remembered once it's cached")
+	}
+
+	// A flood of new keys churns through A1in without touching Am
+	for i := 100; i < 200; i++ {
+		c.Put(i, i)
+	}
+	if v, found := c.GetValue(0); !found || v != 100 {
+		t.Errorf("Expected key 0 to survive the flood in Am with 100, got %v, %v", v, found)
+	}
+	if c.ghost.len != 4 {
+		t.Errorf("Expected A1out to be full with 4 keys, it has %d", c.ghost.len)
+	}
+	if err := c.Validate(); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestTwoQueueCacheReadsDontReorderA1in(t *testing.T) {
+	c := NewTwoQueueCache[int, int](4)
+	for i := 0; i < 4; i++ {
+		c.Put(i, i)
+	}
+
+	// In an LRU this read would save key 0; A1in is a FIFO
+	c.Get(0)
+	c.Put(4, 4)
+	if _, found := c.Get(0); found {
+		t.Error("Key 0 should have been evicted first from A1in despite the read")
+	}
+
+	stats := c.GetStatistics()
+	if stats.Evictions != 1 || stats.NeverReadCount != 0 {
+		t.Errorf("Expected 1 eviction of a read entry, got %+v", stats)
+	}
+}
+
+func TestTwoQueueCacheDeleteForgets(t *testing.T) {
+	c := NewTwoQueueCache[int, int](4)
+	for i := 0; i < 5; i++ {
+		c.Put(i, i)
+	}
+
+	if c.Delete(0) {
+		t.Error("Key 0 is only remembered, so Delete should report no value")
+	}
+	c.Put(0, 0)
+	if c.items[0].segment != segmentIn {
+		t.Error("A deleted key should start over in A1in")
+	}
+	if err := c.Validate(); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestTwoQueueCacheValidate(t *testing.T) {
+	corruptions := map[string]func(c *TwoQueueCache[int, int]){
+		"wrong segment": func(c *TwoQueueCache[int, int]) {
+			c.items[5].segment = segmentMain
+		},
+		"resident ghost": func(c *TwoQueueCache[int, int]) {
+			c.ghosts[5] = c.items[5]
+		},
+		"lost ghost": func(c *TwoQueueCache[int, int]) {
+			delete(c.ghosts, 0)
+		},
+	}
+
+	for name, corrupt := range corruptions {
+		c := NewTwoQueueCache[int, int](4)
+		for i := 0; i < 6; i++ {
+			c.Put(i, i)
+		}
+		corrupt(c)
+		if err := c.Validate(); !errors.Is(err, errInvalid) {
+			t.Errorf("%s: expected an invariant violation, got %v", name, err)
+		}
+	}
+}
+
+func TestTwoQueueCacheConcurrent(t *testing.T) {
+	c := NewTwoQueueCache[int, int](50)
+
+	var wg sync.WaitGroup
+	for i := 0; i < 8; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			rng := rand.New(rand.NewPCG(uint64(i), 0))
+			for j := 0; j < 2000; j++ {
+				key := rng.IntN(150)
+				switch rng.IntN(4) {
+				case 0:
+					c.Put(key, j)
+				case 1:
+					c.Delete(key)
+				default:
+					c.Get(key)
+				}
+			}
+		}()
+	}
+	wg.Wait()
+
+	if err := c.Validate(); err != nil {
+		t.Error(err)
+	}
+}
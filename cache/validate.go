@@ -0,0 +1,92 @@
+package cache
+
+import (
+	"errors"
+	"fmt"
+)
+
+// errInvalid is wrapped by every error returned from Validate
+var errInvalid = errors.New("cache: invariant violated")
+
+// Validate checks the internal invariants of the cache: the items map and the
+// LRU list hold the same keys, the list links are consistent in both
+// directions and free of cycles, and the entry limit is respected. It's meant
+// for tests and debugging; building with -tags cachedebug runs it after every
+// mutation and panics on the first violation.
+func (c *Cache[K, V]) Validate() error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	return c.validateLocked()
+}
+
+// validateLocked implements Validate; the caller must hold c.mu
+func (c *Cache[K, V]) validateLocked() error {
+	return validateLRU(c.items, &c.lruList, c.entryLimit)
+}
+
+// debugCheckLocked panics if the cache is invalid. It's only called when
+// built with the cachedebug tag.
+func (c *Cache[K, V]) debugCheckLocked() {
+	if err := c.validateLocked(); err != nil {
+		panic(err)
+	}
+}
+
+// validateLRU checks a map and LRU list pair: every entry in the map is linked
+// into the list exactly once under its own key, and nothing else is
+func validateLRU[K comparable, V any](items map[K]*entry[K, V], l *entryList[K, V], entryLimit int) error {
+	if len(items) > entryLimit {
+		return fmt.Errorf("%w: %d entries exceed the limit of %d", errInvalid, len(items), entryLimit)
+	}
+	if len(items) != l.len {
+		return fmt.Errorf("%w: %d items but the list counts %d entries", errInvalid, len(items), l.len)
+	}
+	return l.validate(items)
+}
+
+// validate walks the list from head to tail, checking the links against the
+// items map
+func (l *entryList[K, V]) validate(items map[K]*entry[K, V]) error {
+	return l.validateSegment(items, segmentLRU)
+}
+
+// validateSegment walks the list from head to tail, checking the links
+// against the items map and that every entry is marked as being on segment.
+// The map may hold entries of other lists too.
+func (l *entryList[K, V]) validateSegment(items map[K]*entry[K, V], segment segment) error {
+	if (l.head == nil) != (l.tail == nil) {
+		return fmt.Errorf("%w: only one of head and tail is set", errInvalid)
+	}
+	if l.head != nil && l.head.prev != nil {
+		return fmt.Errorf("%w: head has a previous entry", errInvalid)
+	}
+	if l.tail != nil && l.tail.next != nil {
+		return fmt.Errorf("%w: tail has a next entry", errInvalid)
+	}
+
+	count := 0
+	var prev *entry[K, V]
+	for e := l.head; e != nil; prev, e = e, e.next {
+		// Every entry is in the map, so a longer walk must have looped
+		if count++; count > len(items) {
+			return fmt.Errorf("%w: list has a cycle or more entries than the map (%d)", errInvalid, len(items))
+		}
+		if e.prev != prev {
+			return fmt.Errorf("%w: entry %v has a bad previous link", errInvalid, e.key)
+		}
+		if items[e.key] != e {
+			return fmt.Errorf("%w: entry %v is not the one mapped to its key", errInvalid, e.key)
+		}
+		if e.segment != segment {
+			return fmt.Errorf("%w: entry %v is marked as on segment %d, not %d", errInvalid, e.key, e.segment, segment)
+		}
+	}
+	if prev != l.tail {
+		return fmt.Errorf("%w: walk from head doesn't end at tail", errInvalid)
+	}
+	if count != l.len {
+		return fmt.Errorf("%w: list links %d entries but counts %d", errInvalid, count, l.len)
+	}
+	return nil
+}
@@ -0,0 +1,77 @@
+package cache
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestValidate(t *testing.T) {
+	c := NewCache[int, int](3)
+	for i := 0; i < 5; i++ {
+		c.Put(i, i)
+	}
+	c.Get(2)
+	c.Delete(3)
+	if err := c.Validate(); err != nil {
+		t.Fatalf("Expected a valid cache, got %v", err)
+	}
+
+	corruptions := map[string]func(c *Cache[int, int]){
+		"unlinked entry": func(c *Cache[int, int]) {
+			c.lruList.remove(c.items[4])
+		},
+		"extra item": func(c *Cache[int, int]) {
+			c.items[99] = &entry[int, int]{key: 99}
+		},
+		"wrong key": func(c *Cache[int, int]) {
+			c.items[2].key = 99
+		},
+		"over limit": func(c *Cache[int, int]) {
+			c.entryLimit = 1
+		},
+		"bad prev link": func(c *Cache[int, int]) {
+			c.lruList.tail.prev = c.lruList.tail
+		},
+		"cycle": func(c *Cache[int, int]) {
+			c.lruList.tail.next = c.lruList.head
+		},
+		"stale tail": func(c *Cache[int, int]) {
+			c.lruList.tail = c.lruList.head
+		},
+	}
+
+	for name, corrupt := range corruptions {
+		c := NewCache[int, int](3)
+		for i := 0; i < 3; i++ {
+			c.Put(i+2, i)
+		}
+		corrupt(c)
+		if err := c.Validate(); !errors.Is(err, errInvalid) {
+			t.Errorf("%s: expected an invariant violation, got %v", name, err)
+		}
+	}
+}
+
+func TestValidateShardedCache(t *testing.T) {
+	c := NewShardedCache[string, int](64, 4)
+	for i := 0; i < 100; i++ {
+		c.Put(string(rune('a'+i%26))+string(rune('a'+i/26)), i)
+	}
+	if err := c.Validate(); err != nil {
+		t.Fatalf("Expected a valid cache, got %v", err)
+	}
+
+	// Move a key into a shard it doesn't hash to
+	var misplaced *Cache[string, int]
+	for _, shard := range c.set.Load().shards {
+		if shard != c.getShard("zz") {
+			misplaced = shard
+			break
+		}
+	}
+	misplaced.Delete(misplaced.lruList.tail.key)
+	misplaced.Put("zz", 1)
+	if err := c.Validate(); !errors.Is(err, errInvalid) {
+		t.Errorf("Expected a misplaced key to be reported, got %v", err)
+	}
+}
@@ -0,0 +1,441 @@
+package cache
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+	"time"
+)
+
+// WALOptions configures the write-ahead log of a DurableCache
+type WALOptions struct {
+	// SyncInterval is how often log writes are fsynced to disk. Zero
+	// fsyncs every record before Put or Delete returns; a positive interval
+	// group-commits all records written during the interval with one fsync, so
+	// a power failure can lose up to one interval of acknowledged updates.
+	SyncInterval time.Duration
+}
+
+// DurableCache is a Cache whose updates survive a crash. Every Put and Delete
+// is appended to a write-ahead log before it is applied, and the log is
+// replayed on startup. Compact writes a snapshot of the cache and starts a new,
+// empty log.
+//
+// The directory holds at most one snapshot and the logs written since it:
+//
+//	snapshot-<gen>  entries in LRU order (oldest first), covering all logs before <gen>
+//	wal-<gen>.log   Put/Delete records written after that snapshot
+//
+// A crash in Compact can leave a log newer than the newest snapshot. It
+// continues the logs before it, which are kept until a snapshot covers them.
+type DurableCache[K comparable, V any] struct {
+	mu    sync.Mutex // keeps log order identical to the order updates are applied
+	cache *Cache[K, V]
+	dir   string
+	opts  WALOptions
+
+	log     *os.File
+	gen     int  // generation of the active log
+	base    int  // generation of the newest snapshot; older files are covered by it
+	pending bool // records written since the last fsync
+	closed  bool
+	err     error
+
+	stop chan struct{} // closed to stop the group commit goroutine
+	done chan struct{} // closed once the group commit goroutine has exited
+}
+
+// OpenDurableCache creates a cache with the given entry limit whose state is
+// logged to dir, restoring whatever a previous run left there
+func OpenDurableCache[K comparable, V any](entryLimit int, dir string, opts WALOptions) (*DurableCache[K, V], error) {
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return nil, fmt.Errorf("cache: creating log directory: %w", err)
+	}
+
+	c := &DurableCache[K, V]{
+		cache: NewCache[K, V](entryLimit),
+		dir:   dir,
+		opts:  opts,
+	}
+
+	if err := c.recover(); err != nil {
+		return nil, err
+	}
+
+	// Replaying counts as writes; start the statistics from scratch
+	c.cache.stats = newStatistics()
+
+	if opts.SyncInterval > 0 {
+		c.stop = make(chan struct{})
+		c.done = make(chan struct{})
+		go c.groupCommit()
+	}
+
+	return c, nil
+}
+
+func (c *DurableCache[K, V]) snapshotPath(gen int) string {
+	return filepath.Join(c.dir, fmt.Sprintf("snapshot-%06d", gen))
+}
+
+func (c *DurableCache[K, V]) logPath(gen int) string {
+	return filepath.Join(c.dir, fmt.Sprintf("wal-%06d.log", gen))
+}
+
+// generations returns the generations of the files matching pattern, in order
+func (c *DurableCache[K, V]) generations(pattern string) ([]int, error) {
+	names, err := filepath.Glob(filepath.Join(c.dir, "*"))
+	if err != nil {
+		return nil, err
+	}
+
+	var gens []int
+	for _, name := range names {
+		var gen int
+		if _, err := fmt.Sscanf(filepath.Base(name), pattern, &gen); err == nil &&
+			filepath.Base(name) == fmt.Sprintf(pattern, gen) {
+			gens = append(gens, gen)
+		}
+	}
+	sort.Ints(gens)
+	return gens, nil
+}
+
+// recover loads the newest snapshot, replays the logs written after it and
+// opens the newest log for appending
+func (c *DurableCache[K, V]) recover() error {
+	snapshots, err := c.generations("snapshot-%06d")
+	if err != nil {
+		return err
+	}
+	logs, err := c.generations("wal-%06d.log")
+	if err != nil {
+		return err
+	}
+
+	if len(snapshots) > 0 {
+		c.base = snapshots[len(snapshots)-1]
+		c.gen = c.base
+		if err := c.replay(c.snapshotPath(c.base), false); err != nil {
+			return err
+		}
+	}
+
+	for i, gen := range logs {
+		if gen < c.base {
+			continue // already covered by the snapshot
+		}
+		// Only the last log written to can have been cut short by a crash.
+		// Compact may have created an empty one after it.
+		torn, err := c.emptyAfter(logs[i+1:])
+		if err != nil {
+			return err
+		}
+		if err := c.replay(c.logPath(gen), torn); err != nil {
+			return err
+		}
+		c.gen = gen
+	}
+
+	c.log, err = os.OpenFile(c.logPath(c.gen), os.O_RDWR|os.O_CREATE|os.O_APPEND, 0o644)
+	if err != nil {
+		return fmt.Errorf("cache: opening log: %w", err)
+	}
+
+	c.removeStale()
+	return nil
+}
+
+// emptyAfter reports whether every log of gens is empty
+func (c *DurableCache[K, V]) emptyAfter(gens []int) (bool, error) {
+	for _, gen := range gens {
+		info, err := os.Stat(c.logPath(gen))
+		if err != nil {
+			return false, err
+		}
+		if info.Size() > 0 {
+			return false, nil
+		}
+	}
+	return true, nil
+}
+
+// replay applies the records in the named file to the cache. When tolerateTorn
+// is set a damaged final record is treated as a write interrupted by a crash
+// and truncated; damage anywhere else is reported as corruption.
+func (c *DurableCache[K, V]) replay(name string, tolerateTorn bool) error {
+	f, err := os.OpenFile(name, os.O_RDWR, 0o644)
+	if err != nil {
+		return fmt.Errorf("cache: opening %s: %w", filepath.Base(name), err)
+	}
+	defer f.Close()
+
+	info, err := f.Stat()
+	if err != nil {
+		return err
+	}
+
+	r := bufio.NewReader(f)
+	var offset int64
+	for {
+		rec, n, err := readRecord[K, V](r)
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			if !tolerateTorn || offset+n < info.Size() {
+				return fmt.Errorf("cache: %s at offset %d: %w", filepath.Base(name), offset, err)
+			}
+			return f.Truncate(offset)
+		}
+
+		if rec.Deleted {
+			c.cache.Delete(rec.Key)
+		} else {
+			c.cache.Put(rec.Key, rec.Value)
+		}
+		offset += n
+	}
+}
+
+// removeStale deletes the snapshots and logs covered by the newest snapshot.
+// Logs after it are needed to replay, however many there are.
+func (c *DurableCache[K, V]) removeStale() {
+	for _, pattern := range []string{"snapshot-%06d", "wal-%06d.log"} {
+		gens, _ := c.generations(pattern)
+		for _, gen := range gens {
+			if gen < c.base {
+				os.Remove(filepath.Join(c.dir, fmt.Sprintf(pattern, gen)))
+			}
+		}
+	}
+}
+
+// appendLocked writes a record to the log, syncing it straight away when group
+// commit is disabled
+func (c *DurableCache[K, V]) appendLocked(rec diskRecord[K, V]) error {
+	if c.err != nil {
+		return c.err
+	}
+
+	data, err := encodeRecord(rec)
+	if err != nil {
+		return err
+	}
+
+	// A single write per record means a process crash never loses an
+	// acknowledged update; only fsync is deferred to the group commit
+	if _, err := c.log.Write(data); err != nil {
+		c.err = fmt.Errorf("cache: writing log: %w", err)
+		return c.err
+	}
+
+	if c.opts.SyncInterval > 0 {
+		c.pending = true
+		return nil
+	}
+	return c.syncLocked()
+}
+
+// syncLocked fsyncs the active log
+func (c *DurableCache[K, V]) syncLocked() error {
+	if err := c.log.Sync(); err != nil {
+		c.err = fmt.Errorf("cache: syncing log: %w", err)
+		return c.err
+	}
+	c.pending = false
+	return nil
+}
+
+// groupCommit fsyncs the log once per interval if anything was written
+func (c *DurableCache[K, V]) groupCommit() {
+	defer close(c.done)
+
+	ticker := time.NewTicker(c.opts.SyncInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+			c.mu.Lock()
+			if c.pending && c.err == nil {
+				c.syncLocked()
+			}
+			c.mu.Unlock()
+		case <-c.stop:
+			return
+		}
+	}
+}
+
+// Put logs the value and then adds it to the cache, and returns a boolean to
+// indicate whether a value already existed in the cache for that key
+func (c *DurableCache[K, V]) Put(key K, value V) (bool, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if err := c.appendLocked(diskRecord[K, V]{Key: key, Value: value}); err != nil {
+		return false, err
+	}
+	return c.cache.Put(key, value), nil
+}
+
+// Delete logs the removal and then removes the key from the cache
+func (c *DurableCache[K, V]) Delete(key K) (bool, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if err := c.appendLocked(diskRecord[K, V]{Key: key, Deleted: true}); err != nil {
+		return false, err
+	}
+	return c.cache.Delete(key), nil
+}
+
+// SetCloner makes the cache deep copy values with clone when mode says so.
+// Logged values are encoded copies already.
+func (c *DurableCache[K, V]) SetCloner(clone Cloner[V], mode CloneMode) {
+	c.cache.SetCloner(clone, mode)
+}
+
+// Get returns the value associated with the passed key. Reads are not logged,
+// so a replayed cache orders entries by write recency only.
+func (c *DurableCache[K, V]) Get(key K) (*V, bool) {
+	return c.cache.Get(key)
+}
+
+// GetStatistics returns statistics about the cache since it was opened
+func (c *DurableCache[K, V]) GetStatistics() Statistics {
+	return c.cache.GetStatistics()
+}
+
+// Sync fsyncs any records the group commit has not synced yet
+func (c *DurableCache[K, V]) Sync() error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.err != nil || !c.pending {
+		return c.err
+	}
+	return c.syncLocked()
+}
+
+// Compact writes a snapshot of the cache and truncates the log by switching to
+// a new, empty one. Updates are blocked while the snapshot is written.
+func (c *DurableCache[K, V]) Compact() error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.err != nil {
+		return c.err
+	}
+
+	// Create the new log before the snapshot: once snapshot-<next> exists,
+	// recovery skips the current log, so it must never receive another record
+	next := c.gen + 1
+	log, err := os.OpenFile(c.logPath(next), os.O_RDWR|os.O_CREATE|os.O_TRUNC|os.O_APPEND, 0o644)
+	if err != nil {
+		return fmt.Errorf("cache: opening log: %w", err)
+	}
+	if err := c.writeSnapshot(next); err != nil {
+		// The snapshot may have been renamed into place before the failure
+		os.Remove(c.snapshotPath(next))
+		log.Close()
+		os.Remove(c.logPath(next))
+		return err
+	}
+
+	// The snapshot now covers every record in the current log
+	c.log.Close()
+	c.log = log
+	c.gen = next
+	c.base = next
+	c.pending = false
+
+	c.removeStale()
+	return nil
+}
+
+// writeSnapshot writes the cache contents, least recently used first, so that
+// replaying the snapshot restores the LRU order
+func (c *DurableCache[K, V]) writeSnapshot(gen int) error {
+	tmp := c.snapshotPath(gen) + ".tmp"
+	f, err := os.Create(tmp)
+	if err != nil {
+		return fmt.Errorf("cache: creating snapshot: %w", err)
+	}
+
+	err = c.writeEntries(f)
+	if err == nil {
+		err = f.Sync()
+	}
+	if closeErr := f.Close(); err == nil {
+		err = closeErr
+	}
+	if err == nil {
+		err = os.Rename(tmp, c.snapshotPath(gen))
+	}
+	if err != nil {
+		os.Remove(tmp)
+		return fmt.Errorf("cache: writing snapshot: %w", err)
+	}
+
+	return syncDir(c.dir)
+}
+
+func (c *DurableCache[K, V]) writeEntries(w io.Writer) error {
+	c.cache.mu.Lock()
+	defer c.cache.mu.Unlock()
+
+	for e := c.cache.lruList.tail; e != nil; e = e.prev {
+		data, err := encodeRecord(diskRecord[K, V]{Key: e.key, Value: e.value})
+		if err != nil {
+			return err
+		}
+		if _, err := w.Write(data); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// syncDir fsyncs a directory so that renames inside it are durable
+func syncDir(dir string) error {
+	d, err := os.Open(dir)
+	if err != nil {
+		return err
+	}
+	defer d.Close()
+
+	return d.Sync()
+}
+
+// Close syncs and closes the log. The cache must not be used afterwards;
+// closing it again does nothing.
+func (c *DurableCache[K, V]) Close() error {
+	c.mu.Lock()
+	closed := c.closed
+	c.closed = true
+	c.mu.Unlock()
+	if closed {
+		return nil
+	}
+
+	if c.stop != nil {
+		close(c.stop)
+		<-c.done
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	var syncErr error
+	if c.pending && c.err == nil {
+		syncErr = c.syncLocked()
+	}
+	return errors.Join(syncErr, c.log.Close())
+}
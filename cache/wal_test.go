@@ -0,0 +1,293 @@
+package cache
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestDurableCacheReplay(t *testing.T) {
+	dir := t.TempDir()
+
+	cache, err := OpenDurableCache[string, int](3, dir, WALOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	cache.Put("one", 1)
+	cache.Put("two", 2)
+	cache.Put("one", 100)
+	cache.Delete("two")
+	cache.Put("three", 3)
+	if err := cache.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	cache, err = OpenDurableCache[string, int](3, dir, WALOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer cache.Close()
+
+	val, found := cache.Get("one")
+	if !found || *val != 100 {
+		t.Errorf("Expected 'one'=100 after replay, got %v %v", val, found)
+	}
+	if _, found := cache.Get("two"); found {
+		t.Error("Deleted key 'two' should not be replayed")
+	}
+	if _, found := cache.Get("three"); !found {
+		t.Error("Key 'three' should be replayed")
+	}
+
+	// Replayed writes don't count towards the new run's statistics
+	stats := cache.GetStatistics()
+	if stats.Writes != 0 || stats.Reads != 3 {
+		t.Errorf("Expected 0 writes and 3 reads, got %d and %d", stats.Writes, stats.Reads)
+	}
+}
+
+func TestDurableCacheTornRecord(t *testing.T) {
+	dir := t.TempDir()
+
+	cache, err := OpenDurableCache[string, int](3, dir, WALOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	cache.Put("one", 1)
+	cache.Put("two", 2)
+	cache.Close()
+
+	// Cut the last record in half, as a crash during the write would
+	logs, _ := filepath.Glob(filepath.Join(dir, "wal-*.log"))
+	info, err := os.Stat(logs[0])
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Truncate(logs[0], info.Size()-5); err != nil {
+		t.Fatal(err)
+	}
+
+	cache, err = OpenDurableCache[string, int](3, dir, WALOptions{})
+	if err != nil {
+		t.Fatalf("A torn final record should be tolerated, got %v", err)
+	}
+
+	if _, found := cache.Get("one"); !found {
+		t.Error("Key 'one' should survive a torn final record")
+	}
+	if _, found := cache.Get("two"); found {
+		t.Error("Key 'two' was torn and should be dropped")
+	}
+
+	// The log keeps working after the torn tail is truncated
+	cache.Put("three", 3)
+	cache.Close()
+
+	cache, err = OpenDurableCache[string, int](3, dir, WALOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer cache.Close()
+	if _, found := cache.Get("three"); !found {
+		t.Error("Key 'three' written after recovery should be replayed")
+	}
+}
+
+func TestDurableCacheCorruptRecord(t *testing.T) {
+	dir := t.TempDir()
+
+	cache, err := OpenDurableCache[string, int](3, dir, WALOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	cache.Put("one", 1)
+	cache.Put("two", 2)
+	cache.Close()
+
+	// Flip a byte in the first record's payload
+	logs, _ := filepath.Glob(filepath.Join(dir, "wal-*.log"))
+	data, err := os.ReadFile(logs[0])
+	if err != nil {
+		t.Fatal(err)
+	}
+	data[recordHeaderSize+2] ^= 0xff
+	if err := os.WriteFile(logs[0], data, 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := OpenDurableCache[string, int](3, dir, WALOptions{}); err == nil {
+		t.Error("Corruption before the final record should be reported")
+	}
+}
+
+func TestDurableCacheCompact(t *testing.T) {
+	dir := t.TempDir()
+
+	cache, err := OpenDurableCache[string, int](3, dir, WALOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i := 0; i < 100; i++ {
+		cache.Put("counter", i)
+	}
+	cache.Put("one", 1)
+	cache.Put("two", 2)
+	cache.Get("counter") // make "counter" the most recently used
+
+	if err := cache.Compact(); err != nil {
+		t.Fatal(err)
+	}
+
+	logs, _ := filepath.Glob(filepath.Join(dir, "wal-*.log"))
+	snapshots, _ := filepath.Glob(filepath.Join(dir, "snapshot-*"))
+	if len(logs) != 1 || len(snapshots) != 1 {
+		t.Fatalf("Expected one log and one snapshot, got %v and %v", logs, snapshots)
+	}
+	if info, _ := os.Stat(logs[0]); info.Size() != 0 {
+		t.Errorf("Expected an empty log after compaction, got %d bytes", info.Size())
+	}
+
+	cache.Put("three", 3) // should evict "one", the least recently used
+	cache.Close()
+
+	cache, err = OpenDurableCache[string, int](3, dir, WALOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer cache.Close()
+
+	val, found := cache.Get("counter")
+	if !found || *val != 99 {
+		t.Errorf("Expected 'counter'=99 from the snapshot, got %v %v", val, found)
+	}
+	if _, found := cache.Get("one"); found {
+		t.Error("Key 'one' should have been evicted in LRU order")
+	}
+	if _, found := cache.Get("three"); !found {
+		t.Error("Key 'three' should be replayed from the new log")
+	}
+}
+
+func TestDurableCacheCompactFailure(t *testing.T) {
+	tests := []struct {
+		name    string
+		blocked string // made a directory so Compact can't create it
+	}{
+		{"log", "wal-000001.log"},
+		{"snapshot", "snapshot-000001.tmp"},
+	}
+
+	for _, tt := range tests {
+		dir := t.TempDir()
+
+		cache, err := OpenDurableCache[string, int](10, dir, WALOptions{})
+		if err != nil {
+			t.Fatal(err)
+		}
+		cache.Put("one", 1)
+
+		blocked := filepath.Join(dir, tt.blocked)
+		if err := os.Mkdir(blocked, 0o755); err != nil {
+			t.Fatal(err)
+		}
+		if err := cache.Compact(); err == nil {
+			t.Errorf("%s: expected Compact to fail", tt.name)
+		}
+		if _, err := cache.Put("two", 2); err != nil {
+			t.Fatalf("%s: expected writes to carry on after a failed Compact, got %v", tt.name, err)
+		}
+		cache.Close()
+		os.Remove(blocked)
+
+		// Writes acknowledged after the failure must not be hidden behind a
+		// snapshot that was left behind
+		cache, err = OpenDurableCache[string, int](10, dir, WALOptions{})
+		if err != nil {
+			t.Fatal(err)
+		}
+		for _, key := range []string{"one", "two"} {
+			if _, found := cache.Get(key); !found {
+				t.Errorf("%s: expected key %q to survive the failed Compact", tt.name, key)
+			}
+		}
+		cache.Close()
+	}
+}
+
+func TestDurableCacheCrashDuringCompact(t *testing.T) {
+	dir := t.TempDir()
+
+	cache, err := OpenDurableCache[string, int](10, dir, WALOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	cache.Put("one", 1)
+	if err := cache.Compact(); err != nil {
+		t.Fatal(err)
+	}
+	cache.Put("two", 2)
+	cache.Close()
+
+	// Crash after Compact created the next log but before the snapshot,
+	// part way through a write to the old log
+	f, err := os.OpenFile(filepath.Join(dir, "wal-000001.log"), os.O_APPEND|os.O_WRONLY, 0o644)
+	if err != nil {
+		t.Fatal(err)
+	}
+	f.Write([]byte{42, 0, 0, 0, 1, 2})
+	f.Close()
+	if err := os.WriteFile(filepath.Join(dir, "wal-000002.log"), nil, 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	// The snapshot and the old log stay until a snapshot covers them, so
+	// every reopen finds both keys
+	for i := 0; i < 2; i++ {
+		cache, err = OpenDurableCache[string, int](10, dir, WALOptions{})
+		if err != nil {
+			t.Fatalf("Reopen %d: %v", i+1, err)
+		}
+		for _, key := range []string{"one", "two"} {
+			if _, found := cache.Get(key); !found {
+				t.Errorf("Reopen %d: expected key %q to survive", i+1, key)
+			}
+		}
+		cache.Put("three", 3)
+		if err := cache.Close(); err != nil {
+			t.Fatal(err)
+		}
+		if err := cache.Close(); err != nil {
+			t.Errorf("Expected a second Close to do nothing, got %v", err)
+		}
+	}
+}
+
+func TestDurableCacheGroupCommit(t *testing.T) {
+	dir := t.TempDir()
+
+	cache, err := OpenDurableCache[int, int](100, dir, WALOptions{SyncInterval: 5 * time.Millisecond})
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i := 0; i < 50; i++ {
+		if _, err := cache.Put(i, i); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := cache.Sync(); err != nil {
+		t.Fatal(err)
+	}
+	cache.Close()
+
+	cache, err = OpenDurableCache[int, int](100, dir, WALOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer cache.Close()
+	for i := 0; i < 50; i++ {
+		if val, found := cache.Get(i); !found || *val != i {
+			t.Fatalf("Expected %d=%d after replay, got %v %v", i, i, val, found)
+		}
+	}
+}
@@ -0,0 +1,71 @@
+// Command atomics runs the increment from exercises/buggy next to the fixed
+// versions from exercises/atomics, and compares the updates each one loses.
+//
+// Usage:
+//
+//	go run ./cmd/atomics
+//	go run ./cmd/atomics -goroutines 100 -iterations 10000 -repeat 10
+//	go run ./cmd/atomics -methods atomic,mutex
+package main
+
+import (
+	"concurrency/cmd/internal/repeat"
+	"concurrency/exercises/atomics"
+	"concurrency/exercises/buggy"
+	"flag"
+	"fmt"
+	"os"
+	"strings"
+)
+
+// methods lists the increments the command can run, in the order it runs them
+var methods = []struct {
+	name        string
+	description string
+	increment   repeat.Increment
+}{
+	{"buggy", "doesn't handle concurrent access properly", buggy.Increment},
+	{"atomic", "uses atomic operations for thread safety", atomics.AtomicIncrement},
+	{"mutex", "uses a lock to protect the critical section", atomics.MutexIncrement},
+}
+
+func main() {
+	config := repeat.RegisterFlags()
+	methodList := flag.String("methods", "all", "comma separated increments to run: buggy, atomic, mutex, or all")
+	flag.Parse()
+
+	err := config.Validate()
+	if err == nil {
+		err = run(*config, *methodList)
+	}
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "atomics:", err)
+		os.Exit(2)
+	}
+}
+
+func run(config repeat.Config, methodList string) error {
+	selected := make(map[string]bool)
+	for _, m := range methods {
+		selected[m.name] = methodList == "all"
+	}
+	if methodList != "all" {
+		for _, name := range strings.Split(methodList, ",") {
+			name = strings.TrimSpace(name)
+			if _, known := selected[name]; !known {
+				return fmt.Errorf("unknown method %q", name)
+			}
+			selected[name] = true
+		}
+	}
+
+	fmt.Printf("%d goroutines each add 1 to x %d times\n", config.Goroutines, config.Iterations)
+	for _, m := range methods {
+		if !selected[m.name] {
+			continue
+		}
+		fmt.Printf("\n%s increment (%s):\n", m.name, m.description)
+		repeat.Run(os.Stdout, m.name, config, m.increment)
+	}
+	return nil
+}
@@ -0,0 +1,29 @@
+// Command buggy runs the unsynchronized increment from exercises/buggy and
+// shows how many updates the data race loses.
+//
+// Usage:
+//
+//	go run ./cmd/buggy
+//	go run ./cmd/buggy -goroutines 100 -iterations 10000 -repeat 10
+package main
+
+import (
+	"concurrency/cmd/internal/repeat"
+	"concurrency/exercises/buggy"
+	"flag"
+	"fmt"
+	"os"
+)
+
+func main() {
+	config := repeat.RegisterFlags()
+	flag.Parse()
+
+	if err := config.Validate(); err != nil {
+		fmt.Fprintln(os.Stderr, "buggy:", err)
+		os.Exit(2)
+	}
+
+	fmt.Printf("%d goroutines each add 1 to x %d times, without synchronization\n", config.Goroutines, config.Iterations)
+	repeat.Run(os.Stdout, "buggy", *config, buggy.Increment)
+}
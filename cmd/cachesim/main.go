@@ -0,0 +1,202 @@
+// Command cachesim replays an access trace against every cache implementation
+// and eviction policy at several capacities and reports hit-ratio curves and
+// throughput.
+//
+// Usage:
+//
+//	go run ./cmd/cachesim -trace requests.txt -capacities 100,1000,10000
+//	go run ./cmd/cachesim -trace P1.lis -format arc -csv results.csv
+//	go run ./cmd/cachesim -workload zipf -keys 100000 -ops 1000000
+package main
+
+import (
+	"encoding/csv"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+)
+
+func main() {
+	tracePath := flag.String("trace", "", "trace file to replay")
+	format := flag.String("format", "text", "trace format: text, arc or lirs")
+	synthetic := flag.String("workload", "", "generate a trace instead: uniform, zipf, scan, hotspot, latest or shifting")
+	keys := flag.Uint64("keys", 100000, "distinct keys in a generated trace")
+	ops := flag.Int("ops", 1000000, "accesses in a generated trace")
+	skew := flag.Float64("skew", 0.99, "Zipfian skew of a generated trace")
+	writes := flag.Float64("writes", 0, "fraction of writes in a generated trace")
+	seed := flag.Uint64("seed", 1, "seed of a generated trace")
+	capacityList := flag.String("capacities", "100,1000,10000", "comma separated cache capacities")
+	implList := flag.String("impls", "all", "comma separated implementations to run, or all")
+	workers := flag.Int("workers", 1, "goroutines replaying the trace concurrently")
+	csvPath := flag.String("csv", "", "also write results as CSV to this file (- for stdout)")
+	flag.Parse()
+
+	var t *trace
+	var err error
+	switch {
+	case *tracePath != "":
+		t, err = readTrace(*tracePath, *format)
+	case *synthetic != "":
+		t, err = syntheticTrace(*synthetic, *keys, *ops, *skew, *writes, *seed)
+		*tracePath = *synthetic + " workload"
+	default:
+		err = fmt.Errorf("either -trace or -workload is required")
+	}
+	if err == nil {
+		err = run(t, *tracePath, *capacityList, *implList, *workers, *csvPath)
+	}
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "cachesim:", err)
+		os.Exit(1)
+	}
+}
+
+func readTrace(path, format string) (*trace, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	t, err := parseTrace(f, format)
+	if err != nil {
+		return nil, fmt.Errorf("reading %s: %w", path, err)
+	}
+	return t, nil
+}
+
+func run(t *trace, name, capacityList, implList string, workers int, csvPath string) error {
+	if workers < 1 {
+		return fmt.Errorf("-workers must be at least 1")
+	}
+
+	capacities, err := parseCapacities(capacityList)
+	if err != nil {
+		return err
+	}
+	impls, err := selectImplementations(implList)
+	if err != nil {
+		return err
+	}
+
+	fmt.Printf("Trace %s: %d accesses, %d distinct keys, %d workers\n\n", name, len(t.accesses), t.keys, workers)
+
+	var results []result
+	for _, impl := range impls {
+		for _, capacity := range capacities {
+			results = append(results, replay(impl, capacity, t, workers))
+		}
+	}
+
+	writeTable(os.Stdout, results, capacities)
+
+	switch csvPath {
+	case "":
+		return nil
+	case "-":
+		return writeCSV(os.Stdout, results)
+	default:
+		out, err := os.Create(csvPath)
+		if err != nil {
+			return err
+		}
+		if err := writeCSV(out, results); err != nil {
+			out.Close()
+			return err
+		}
+		return out.Close()
+	}
+}
+
+func parseCapacities(list string) ([]int, error) {
+	var capacities []int
+	for _, field := range strings.Split(list, ",") {
+		capacity, err := strconv.Atoi(strings.TrimSpace(field))
+		if err != nil || capacity < 1 {
+			return nil, fmt.Errorf("bad capacity %q", field)
+		}
+		capacities = append(capacities, capacity)
+	}
+	return capacities, nil
+}
+
+func selectImplementations(list string) ([]implementation, error) {
+	if list == "all" {
+		return implementations, nil
+	}
+
+	var selected []implementation
+	for _, name := range strings.Split(list, ",") {
+		name = strings.TrimSpace(name)
+		found := false
+		for _, impl := range implementations {
+			if impl.name == name {
+				selected = append(selected, impl)
+				found = true
+			}
+		}
+		if !found {
+			return nil, fmt.Errorf("unknown implementation %q", name)
+		}
+	}
+	return selected, nil
+}
+
+// writeTable prints the hit-ratio curve and throughput of each implementation,
+// one column per capacity
+func writeTable(w io.Writer, results []result, capacities []int) {
+	fmt.Fprintln(w, "Hit ratio (%) by capacity")
+	writePivot(w, results, capacities, func(r result) string {
+		return fmt.Sprintf("%.2f", r.hitRatio()*100)
+	})
+
+	fmt.Fprintln(w, "\nThroughput (Mops/s) by capacity")
+	writePivot(w, results, capacities, func(r result) string {
+		return fmt.Sprintf("%.2f", r.opsPerSec/1e6)
+	})
+}
+
+// writePivot prints one row per implementation and one column per capacity
+func writePivot(w io.Writer, results []result, capacities []int, cell func(result) string) {
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', tabwriter.AlignRight)
+
+	fmt.Fprint(tw, "implementation\t")
+	for _, capacity := range capacities {
+		fmt.Fprintf(tw, "%d\t", capacity)
+	}
+	fmt.Fprintln(tw)
+
+	for i := 0; i < len(results); i += len(capacities) {
+		fmt.Fprintf(tw, "%s\t", results[i].impl)
+		for _, r := range results[i : i+len(capacities)] {
+			fmt.Fprintf(tw, "%s\t", cell(r))
+		}
+		fmt.Fprintln(tw)
+	}
+
+	tw.Flush()
+}
+
+// writeCSV writes one row per implementation and capacity
+func writeCSV(w io.Writer, results []result) error {
+	cw := csv.NewWriter(w)
+	cw.Write([]string{"implementation", "capacity", "accesses", "hits", "misses", "hit_ratio", "elapsed_ns", "ops_per_sec"})
+	for _, r := range results {
+		cw.Write([]string{
+			r.impl,
+			strconv.Itoa(r.capacity),
+			strconv.Itoa(r.accesses),
+			strconv.FormatInt(r.hits, 10),
+			strconv.FormatInt(r.misses, 10),
+			strconv.FormatFloat(r.hitRatio(), 'f', 6, 64),
+			strconv.FormatInt(r.elapsed.Nanoseconds(), 10),
+			strconv.FormatFloat(r.opsPerSec, 'f', 0, 64),
+		})
+	}
+	cw.Flush()
+	return cw.Error()
+}
@@ -0,0 +1,102 @@
+package main
+
+import (
+	"concurrency/cache"
+	"io"
+	"sync"
+	"time"
+)
+
+// simCache is the behaviour the simulator needs from a cache implementation
+type simCache interface {
+	Get(key int) (*int, bool)
+	Put(key int, value int) bool
+	Delete(key int) bool
+	GetStatistics() cache.Statistics
+}
+
+// implementation is a cache implementation or eviction policy under test
+type implementation struct {
+	name string
+	new  func(capacity int) simCache
+}
+
+// implementations lists everything the simulator can replay a trace against
+var implementations = []implementation{
+	{"lru", func(capacity int) simCache { return cache.NewCache[int, int](capacity) }},
+	{"lru-rwmutex", func(capacity int) simCache { return cache.NewRWMutexCache[int, int](capacity) }},
+	{"lru-sharded", func(capacity int) simCache { return cache.NewShardedCache[int, int](capacity, 8) }},
+	{"lru-channel", func(capacity int) simCache { return cache.NewChannelCache[int, int](capacity) }},
+	{"lru-sampled-5", func(capacity int) simCache { return cache.NewSampledCache[int, int](capacity, 5) }},
+	{"lru-sampled-10", func(capacity int) simCache { return cache.NewSampledCache[int, int](capacity, 10) }},
+	{"slru", func(capacity int) simCache { return cache.NewSLRUCache[int, int](capacity, 0.8) }},
+	{"2q", func(capacity int) simCache { return cache.NewTwoQueueCache[int, int](capacity) }},
+	{"sieve", func(capacity int) simCache { return cache.NewSIEVECache[int, int](capacity) }},
+	{"s3fifo", func(capacity int) simCache { return cache.NewS3FIFOCache[int, int](capacity) }},
+}
+
+// result is the outcome of replaying a trace against one cache
+type result struct {
+	impl      string
+	capacity  int
+	accesses  int
+	hits      int64
+	misses    int64
+	elapsed   time.Duration
+	opsPerSec float64
+}
+
+// hitRatio returns the fraction of gets that hit
+func (r result) hitRatio() float64 {
+	if r.hits+r.misses == 0 {
+		return 0
+	}
+	return float64(r.hits) / float64(r.hits+r.misses)
+}
+
+// replay runs the trace against c. A get that misses fills the cache, as a
+// demand-filled cache in front of a slower store would. With more than one
+// worker the trace is dealt out round-robin, so the hit ratio depends on
+// scheduling but the throughput reflects lock contention.
+func replay(impl implementation, capacity int, t *trace, workers int) result {
+	c := impl.new(capacity)
+	if closer, ok := c.(io.Closer); ok {
+		defer closer.Close()
+	}
+
+	start := time.Now()
+	var wg sync.WaitGroup
+	for w := 0; w < workers; w++ {
+		wg.Add(1)
+		go func(w int) {
+			defer wg.Done()
+
+			for i := w; i < len(t.accesses); i += workers {
+				a := t.accesses[i]
+				switch a.op {
+				case opGet:
+					if _, found := c.Get(a.key); !found {
+						c.Put(a.key, i)
+					}
+				case opPut:
+					c.Put(a.key, i)
+				case opDelete:
+					c.Delete(a.key)
+				}
+			}
+		}(w)
+	}
+	wg.Wait()
+	elapsed := time.Since(start)
+
+	stats := c.GetStatistics()
+	return result{
+		impl:      impl.name,
+		capacity:  capacity,
+		accesses:  len(t.accesses),
+		hits:      stats.Hits,
+		misses:    stats.Misses,
+		elapsed:   elapsed,
+		opsPerSec: float64(len(t.accesses)) / elapsed.Seconds(),
+	}
+}
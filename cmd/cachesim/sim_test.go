@@ -0,0 +1,174 @@
+package main
+
+import (
+	"bytes"
+	"encoding/csv"
+	"strings"
+	"testing"
+)
+
+func TestParseText(t *testing.T) {
+	input := `# a comment
+user:1
+get user:2
+put user:1
+
+delete user:2
+`
+	tr, err := parseTrace(strings.NewReader(input), "text")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	want := []access{{opGet, 0}, {opGet, 1}, {opPut, 0}, {opDelete, 1}}
+	if len(tr.accesses) != len(want) {
+		t.Fatalf("Expected %d accesses, got %d", len(want), len(tr.accesses))
+	}
+	for i, a := range want {
+		if tr.accesses[i] != a {
+			t.Errorf("Access %d: expected %v, got %v", i, a, tr.accesses[i])
+		}
+	}
+	if tr.keys != 2 {
+		t.Errorf("Expected 2 distinct keys, got %d", tr.keys)
+	}
+
+	if _, err := parseTrace(strings.NewReader("frobnicate key\n"), "text"); err == nil {
+		t.Error("Unknown operations should be rejected")
+	}
+}
+
+func TestParseARC(t *testing.T) {
+	tr, err := parseTrace(strings.NewReader("100 3 0 1\n101 2 0 2\n"), "arc")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Blocks 100,101,102 then 101,102
+	want := []int{0, 1, 2, 1, 2}
+	if len(tr.accesses) != len(want) {
+		t.Fatalf("Expected %d accesses, got %d", len(want), len(tr.accesses))
+	}
+	for i, key := range want {
+		if tr.accesses[i].key != key {
+			t.Errorf("Access %d: expected key %d, got %d", i, key, tr.accesses[i].key)
+		}
+	}
+}
+
+func TestParseLIRS(t *testing.T) {
+	tr, err := parseTrace(strings.NewReader("5\n7\n*\n5\n"), "lirs")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(tr.accesses) != 3 || tr.keys != 2 {
+		t.Errorf("Expected 3 accesses over 2 keys, got %d over %d", len(tr.accesses), tr.keys)
+	}
+}
+
+func TestParseLIRSRejectsMalformedLines(t *testing.T) {
+	_, err := parseTrace(strings.NewReader("5\n7\n5x\n"), "lirs")
+	if err == nil || !strings.Contains(err.Error(), "line 3") {
+		t.Errorf("Expected an error on line 3, got %v", err)
+	}
+}
+
+func TestReplay(t *testing.T) {
+	// A loop over 10 keys fits comfortably in 80 entries, even split across
+	// shards, but thrashes LRU at 5
+	var accesses []access
+	for i := 0; i < 100; i++ {
+		accesses = append(accesses, access{opGet, i % 10})
+	}
+	tr := &trace{accesses: accesses, keys: 10}
+
+	for _, impl := range implementations {
+		r := replay(impl, 80, tr, 1)
+		if r.misses != 10 || r.hits != 90 {
+			t.Errorf("%s: expected 90 hits and 10 misses, got %d and %d", impl.name, r.hits, r.misses)
+		}
+	}
+
+	lru := implementations[0]
+	if r := replay(lru, 5, tr, 1); r.hits != 0 {
+		t.Errorf("A looping trace larger than the cache should never hit LRU, got %d hits", r.hits)
+	}
+
+	// Concurrent replay still sees every access
+	if r := replay(lru, 10, tr, 4); r.hits+r.misses != 100 {
+		t.Errorf("Expected 100 gets with 4 workers, got %d", r.hits+r.misses)
+	}
+}
+
+func TestSampledApproximatesLRU(t *testing.T) {
+	tr, err := syntheticTrace("zipf", 10_000, 200_000, 0.99, 0, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ratios := make(map[string]float64)
+	for _, impl := range implementations {
+		ratios[impl.name] = replay(impl, 1000, tr, 1).hitRatio()
+	}
+
+	// Sampling gives up a little precision, not a meaningful share of hits
+	for _, name := range []string{"lru-sampled-5", "lru-sampled-10"} {
+		if diff := ratios[name] - ratios["lru"]; diff < -0.02 || diff > 0.02 {
+			t.Errorf("Expected %s within 2 points of LRU's hit ratio of %.3f, got %.3f", name, ratios["lru"], ratios[name])
+		}
+	}
+}
+
+func TestWriteCSV(t *testing.T) {
+	var buf bytes.Buffer
+	results := []result{{impl: "lru", capacity: 10, accesses: 4, hits: 3, misses: 1}}
+	if err := writeCSV(&buf, results); err != nil {
+		t.Fatal(err)
+	}
+
+	records, err := csv.NewReader(&buf).ReadAll()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(records) != 2 || records[1][5] != "0.750000" {
+		t.Errorf("Expected a header and one row with hit ratio 0.75, got %v", records)
+	}
+}
+
+func TestSyntheticTrace(t *testing.T) {
+	for _, name := range []string{"uniform", "zipf", "scan", "hotspot", "latest", "shifting"} {
+		tr, err := syntheticTrace(name, 100, 1000, 0.99, 0.1, 1)
+		if err != nil {
+			t.Fatalf("%s: %v", name, err)
+		}
+		if len(tr.accesses) != 1000 || tr.keys == 0 {
+			t.Errorf("%s: expected 1000 accesses, got %d over %d keys", name, len(tr.accesses), tr.keys)
+		}
+	}
+
+	if _, err := syntheticTrace("bogus", 100, 1000, 0.99, 0, 1); err == nil {
+		t.Error("Unknown workloads should be rejected")
+	}
+}
+
+func TestSyntheticTraceRejectsBadFlags(t *testing.T) {
+	for _, tc := range []struct {
+		name   string
+		keys   uint64
+		ops    int
+		skew   float64
+		writes float64
+	}{
+		{"zipf", 0, 1000, 0.99, 0},
+		{"zipf", 100, 1000, 1, 0},
+		{"latest", 100, 1000, -1, 0},
+		{"shifting", 100, 1000, 1, 0},
+		{"hotspot", 1, 1000, 0.99, 0},
+		{"uniform", 100, -1, 0.99, 0},
+		{"uniform", 100, 1000, 0.99, 1.5},
+	} {
+		if _, err := syntheticTrace(tc.name, tc.keys, tc.ops, tc.skew, tc.writes, 1); err == nil {
+			t.Errorf("Expected an error for %+v", tc)
+		}
+	}
+}
@@ -0,0 +1,231 @@
+package main
+
+import (
+	"bufio"
+	"concurrency/workload"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+)
+
+// opKind is the operation of a trace access
+type opKind uint8
+
+const (
+	opGet opKind = iota
+	opPut
+	opDelete
+)
+
+// access is one request in a trace. Keys are interned to dense ints so every
+// cache implementation replays the same, cheap key type.
+type access struct {
+	op  opKind
+	key int
+}
+
+// trace is a parsed access trace
+type trace struct {
+	accesses []access
+	keys     int // number of distinct keys
+}
+
+// interner assigns dense ids to trace keys
+type interner map[string]int
+
+func (in interner) id(key string) int {
+	id, exists := in[key]
+	if !exists {
+		id = len(in)
+		in[key] = id
+	}
+	return id
+}
+
+// parseTrace reads a trace in the named format: "text", "arc" or "lirs"
+func parseTrace(r io.Reader, format string) (*trace, error) {
+	switch format {
+	case "text":
+		return parseText(r)
+	case "arc":
+		return parseARC(r)
+	case "lirs":
+		return parseLIRS(r)
+	default:
+		return nil, fmt.Errorf("unknown trace format %q", format)
+	}
+}
+
+// parseText reads the simple text format: one access per line, either a bare
+// key (a get) or an operation followed by a key:
+//
+//	# comment
+//	user:1
+//	get user:1
+//	put user:2
+//	delete user:1
+func parseText(r io.Reader) (*trace, error) {
+	t := &trace{}
+	keys := interner{}
+
+	scanner := bufio.NewScanner(r)
+	for line := 1; scanner.Scan(); line++ {
+		fields := strings.Fields(scanner.Text())
+		if len(fields) == 0 || strings.HasPrefix(fields[0], "#") {
+			continue
+		}
+
+		a := access{op: opGet}
+		switch {
+		case len(fields) == 1:
+			a.key = keys.id(fields[0])
+		case len(fields) == 2:
+			switch strings.ToLower(fields[0]) {
+			case "get", "read":
+				a.op = opGet
+			case "put", "set", "write":
+				a.op = opPut
+			case "delete", "del":
+				a.op = opDelete
+			default:
+				return nil, fmt.Errorf("line %d: unknown operation %q", line, fields[0])
+			}
+			a.key = keys.id(fields[1])
+		default:
+			return nil, fmt.Errorf("line %d: expected [op] key, got %q", line, scanner.Text())
+		}
+		t.accesses = append(t.accesses, a)
+	}
+
+	t.keys = len(keys)
+	return t, scanner.Err()
+}
+
+// parseARC reads traces in the format used by the ARC paper: each line is
+//
+//	starting_block number_of_blocks ignored request_number
+//
+// and expands to a get of every block in the range
+func parseARC(r io.Reader) (*trace, error) {
+	t := &trace{}
+	blocks := map[int64]int{}
+
+	scanner := bufio.NewScanner(r)
+	for line := 1; scanner.Scan(); line++ {
+		fields := strings.Fields(scanner.Text())
+		if len(fields) == 0 {
+			continue
+		}
+		if len(fields) < 2 {
+			return nil, fmt.Errorf("line %d: expected start and count, got %q", line, scanner.Text())
+		}
+
+		start, err := strconv.ParseInt(fields[0], 10, 64)
+		if err != nil {
+			return nil, fmt.Errorf("line %d: bad starting block: %w", line, err)
+		}
+		count, err := strconv.ParseInt(fields[1], 10, 64)
+		if err != nil || count < 0 {
+			return nil, fmt.Errorf("line %d: bad block count %q", line, fields[1])
+		}
+
+		for block := start; block < start+count; block++ {
+			t.accesses = append(t.accesses, access{op: opGet, key: internBlock(blocks, block)})
+		}
+	}
+
+	t.keys = len(blocks)
+	return t, scanner.Err()
+}
+
+// parseLIRS reads traces in the format used by the LIRS paper: one block
+// number per line. Blank lines and the "*" phase separators found in some
+// traces are skipped; any other line that isn't a number is an error.
+func parseLIRS(r io.Reader) (*trace, error) {
+	t := &trace{}
+	blocks := map[int64]int{}
+
+	scanner := bufio.NewScanner(r)
+	for line := 1; scanner.Scan(); line++ {
+		text := strings.TrimSpace(scanner.Text())
+		if text == "" || text == "*" {
+			continue
+		}
+		block, err := strconv.ParseInt(text, 10, 64)
+		if err != nil {
+			return nil, fmt.Errorf("line %d: bad block number %q", line, text)
+		}
+		t.accesses = append(t.accesses, access{op: opGet, key: internBlock(blocks, block)})
+	}
+
+	t.keys = len(blocks)
+	return t, scanner.Err()
+}
+
+func internBlock(blocks map[int64]int, block int64) int {
+	id, exists := blocks[block]
+	if !exists {
+		id = len(blocks)
+		blocks[block] = id
+	}
+	return id
+}
+
+// syntheticTrace generates a trace from one of the workload package's key
+// distributions instead of reading it from a file. Bad flag values are
+// reported as errors rather than left to the generators.
+func syntheticTrace(name string, keys uint64, ops int, skew, writes float64, seed uint64) (*trace, error) {
+	switch {
+	case keys == 0:
+		return nil, fmt.Errorf("-keys must be at least 1")
+	case ops < 0:
+		return nil, fmt.Errorf("-ops must not be negative")
+	case !(writes >= 0 && writes <= 1):
+		return nil, fmt.Errorf("-writes must be between 0 and 1, got %v", writes)
+	}
+
+	var gen workload.Generator
+	var err error
+	switch name {
+	case "uniform":
+		gen, err = workload.NewUniform(keys, seed)
+	case "zipf":
+		gen, err = workload.NewZipfian(keys, skew, seed)
+	case "scan":
+		gen, err = workload.NewSequential(keys)
+	case "hotspot":
+		gen, err = workload.NewHotspot(keys, 0.2, 0.8, seed)
+	case "latest":
+		gen, err = workload.NewLatest(keys, skew, seed)
+	case "shifting":
+		workingSet := max(keys/10, 1)
+		var inner workload.Generator
+		if inner, err = workload.NewZipfian(workingSet, skew, seed); err == nil {
+			gen, err = workload.NewShifting(keys, inner, workingSet, ops/10+1)
+		}
+	default:
+		return nil, fmt.Errorf("unknown workload %q", name)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("-workload %s: %w", name, err)
+	}
+
+	w, err := workload.New(gen, workload.Mix{Read: 1 - writes, Write: writes}, seed)
+	if err != nil {
+		return nil, err
+	}
+	t := &trace{accesses: make([]access, ops)}
+	seen := make(map[uint64]bool)
+	for i := range t.accesses {
+		op := w.Next()
+		t.accesses[i] = access{op: opGet, key: int(op.Key)}
+		if op.Kind == workload.Write {
+			t.accesses[i].op = opPut
+		}
+		seen[op.Key] = true
+	}
+
+	t.keys = len(seen)
+	return t, nil
+}
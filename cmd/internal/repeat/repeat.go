@@ -0,0 +1,106 @@
+// Package repeat runs the increment exercises from the commands in cmd. It
+// registers the flags they share, compares every run with the value a correct
+// program would reach, and summarizes the lost updates over repeated runs so
+// the variance between runs is visible.
+package repeat
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"math"
+)
+
+// Config is what the shared flags select
+type Config struct {
+	Goroutines int // goroutines incrementing the shared variable
+	Iterations int // increments made by each goroutine
+	Repeat     int // runs of each exercise
+}
+
+// RegisterFlags adds -goroutines, -iterations and -repeat to the command line
+// flag set. The returned Config is filled in by flag.Parse.
+func RegisterFlags() *Config {
+	c := &Config{}
+	flag.IntVar(&c.Goroutines, "goroutines", 1000, "goroutines incrementing the shared variable")
+	flag.IntVar(&c.Iterations, "iterations", 1, "increments made by each goroutine")
+	flag.IntVar(&c.Repeat, "repeat", 1, "run each exercise this many times to show the variance between runs")
+	return c
+}
+
+// Validate checks the flag values
+func (c Config) Validate() error {
+	if c.Goroutines < 1 || c.Iterations < 1 || c.Repeat < 1 {
+		return fmt.Errorf("-goroutines, -iterations and -repeat must be at least 1")
+	}
+	return nil
+}
+
+// Expected returns the final value a correct program reaches
+func (c Config) Expected() int {
+	return c.Goroutines * c.Iterations
+}
+
+// Increment is an exercise: it runs goroutines that each increment a shared
+// variable iterations times and returns the variable's final value
+type Increment func(goroutines, iterations int) int
+
+// Summary describes the lost updates over every run of one exercise, as
+// percentages of the expected value
+type Summary struct {
+	Runs   int
+	Min    float64
+	Max    float64
+	Mean   float64
+	StdDev float64
+}
+
+// LostPercent returns the share of the expected updates missing from actual
+func LostPercent(expected, actual int) float64 {
+	if expected == 0 {
+		return 0
+	}
+	return float64(expected-actual) / float64(expected) * 100
+}
+
+// Run runs fn c.Repeat times, printing the expected and actual value of every
+// run, followed by a summary when there is more than one run
+func Run(w io.Writer, name string, c Config, fn Increment) Summary {
+	expected := c.Expected()
+	lost := make([]float64, c.Repeat)
+	for i := range lost {
+		actual := fn(c.Goroutines, c.Iterations)
+		lost[i] = LostPercent(expected, actual)
+		fmt.Fprintf(w, "%s run %d: expected %d, got %d (%.2f%% of updates lost)\n", name, i+1, expected, actual, lost[i])
+	}
+
+	s := summarize(lost)
+	if s.Runs > 1 {
+		fmt.Fprintf(w, "%s over %d runs: %.2f%% lost on average (min %.2f%%, max %.2f%%, std dev %.2f)\n",
+			name, s.Runs, s.Mean, s.Min, s.Max, s.StdDev)
+	}
+	return s
+}
+
+// summarize computes the summary of a set of lost update percentages
+func summarize(lost []float64) Summary {
+	s := Summary{Runs: len(lost), Min: math.Inf(1), Max: math.Inf(-1)}
+	if len(lost) == 0 {
+		return Summary{}
+	}
+
+	var sum float64
+	for _, l := range lost {
+		s.Min = min(s.Min, l)
+		s.Max = max(s.Max, l)
+		sum += l
+	}
+	s.Mean = sum / float64(len(lost))
+
+	var squares float64
+	for _, l := range lost {
+		squares += (l - s.Mean) * (l - s.Mean)
+	}
+	s.StdDev = math.Sqrt(squares / float64(len(lost)))
+	return s
+}
@@ -0,0 +1,60 @@
+package repeat
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+)
+
+func TestLostPercent(t *testing.T) {
+	for _, tc := range []struct {
+		expected, actual int
+		want             float64
+	}{
+		{1000, 1000, 0},
+		{1000, 750, 25},
+		{200, 0, 100},
+		{0, 0, 0},
+	} {
+		if got := LostPercent(tc.expected, tc.actual); got != tc.want {
+			t.Errorf("LostPercent(%d, %d): expected %v, got %v", tc.expected, tc.actual, tc.want, got)
+		}
+	}
+}
+
+func TestRun(t *testing.T) {
+	// Each run loses 10 more updates than the one before: 0%, 10% and 20%
+	run := 0
+	fn := func(goroutines, iterations int) int {
+		defer func() { run++ }()
+		return goroutines*iterations - run*10
+	}
+
+	var out bytes.Buffer
+	s := Run(&out, "test", Config{Goroutines: 10, Iterations: 10, Repeat: 3}, fn)
+
+	if s.Runs != 3 || s.Min != 0 || s.Max != 20 || s.Mean != 10 {
+		t.Errorf("Unexpected summary %+v", s)
+	}
+	if s.StdDev < 8.16 || s.StdDev > 8.17 {
+		t.Errorf("Expected a standard deviation of about 8.165, got %v", s.StdDev)
+	}
+
+	lines := strings.Split(strings.TrimSpace(out.String()), "\n")
+	if len(lines) != 4 {
+		t.Fatalf("Expected 3 runs and a summary, got:\n%s", out.String())
+	}
+	if want := "test run 2: expected 100, got 90 (10.00% of updates lost)"; lines[1] != want {
+		t.Errorf("Expected %q, got %q", want, lines[1])
+	}
+	if !strings.HasPrefix(lines[3], "test over 3 runs: 10.00% lost on average") {
+		t.Errorf("Unexpected summary line %q", lines[3])
+	}
+
+	// A single run has no summary
+	out.Reset()
+	Run(&out, "test", Config{Goroutines: 1, Iterations: 1, Repeat: 1}, func(int, int) int { return 1 })
+	if n := strings.Count(out.String(), "\n"); n != 1 {
+		t.Errorf("Expected a single line for a single run, got:\n%s", out.String())
+	}
+}
@@ -4,6 +4,11 @@ import (
 	"concurrency/cache"
 	"concurrency/exercises/atomics"
 	"concurrency/exercises/buggy"
+	"concurrency/exercises/deadlock"
+	"concurrency/exercises/mutex"
+	"concurrency/exercises/rwmutex"
+	"concurrency/exercises/waitgroup"
+	"concurrency/workload"
 	"fmt"
 	"sync"
 	"time"
@@ -24,15 +29,24 @@ func main() {
 	fmt.Println("\nFixed with mutex:")
 	atomics.RunMutexIncrement()
 
-	// 2. Basic LRU Cache example.
+	// 2. Locking mistakes, each broken and then fixed
+	fmt.Println("\n--- Deadlock, Livelock & Lock Misuse Examples ---")
+	deadlock.RunLockOrdering()
+	deadlock.RunLivelock()
+	rwmutex.RunWriterStarvation()
+	rwmutex.RunRecursiveRLock()
+	mutex.RunCopiedMutex()
+	waitgroup.RunWaitGroup()
+
+	// 3. Basic LRU Cache example.
 	fmt.Println("\n--- Basic Cache Usage ---")
 	demoBasicCache()
 
-	//3. Concurrent Cache Usage
+	// 4. Concurrent Cache Usage
 	fmt.Println("\n--- Concurrent Cache Usage ---")
 	demoConcurrentCache()
 
-	// 4. performance Comparison
+	// 5. performance Comparison
 	fmt.Println("\n--- Performance Comparison ---")
 	compareImplementations()
 }
@@ -125,17 +139,21 @@ func compareImplementations() {
 	regularCache := cache.NewCache[string, int](capacity)
 	rwCache := cache.NewRWMutexCache[string, int](capacity)
 	shardedCache := cache.NewShardedCache[string, int](capacity, 8)
+	channelCache := cache.NewChannelCache[string, int](capacity)
+	defer channelCache.Close()
 
 	// Run benchmarks
-	fmt.Println("Running write-heavy workload...")
+	fmt.Println("Running write-heavy Zipfian workload...")
 	benchmarkCache("Regular Cache (Mutex)", regularCache, 0.8)
 	benchmarkCache("RWMutex Cache", rwCache, 0.8)
 	benchmarkCache("Sharded Cache", shardedCache, 0.8)
+	benchmarkCache("Channel Cache (actor)", channelCache, 0.8)
 
-	fmt.Println("\nRunning read-heavy workload...")
+	fmt.Println("\nRunning read-heavy Zipfian workload...")
 	benchmarkCache("Regular Cache (Mutex)", regularCache, 0.2)
 	benchmarkCache("RWMutex Cache", rwCache, 0.2)
 	benchmarkCache("Sharded Cache", shardedCache, 0.2)
+	benchmarkCache("Channel Cache (actor)", channelCache, 0.2)
 }
 
 // Interface to allow benchmarking different cache implementation
@@ -149,9 +167,26 @@ func benchmarkCache(name string, c CacheBenchmark, writePct float32) {
 	numWorkers := 8
 
 	// Pre-populate with same values
-	for i := 0; i < 1000; i++ {
-		key := fmt.Sprintf("key%d", i)
-		c.Put(key, i)
+	keys := make([]string, 1000)
+	for i := range keys {
+		keys[i] = workload.Key(uint64(i))
+		c.Put(keys[i], i)
+	}
+
+	// Give every worker its own Zipfian trace, generated before timing starts,
+	// so a few hot keys get most of the traffic as in real workloads
+	mix := workload.Mix{Read: float64(1 - writePct), Write: float64(writePct)}
+	traces := make([][]workload.Operation, numWorkers)
+	for i := range traces {
+		keyGen, err := workload.NewZipfian(uint64(len(keys)), 0.99, uint64(i))
+		if err != nil {
+			panic(err)
+		}
+		w, err := workload.New(keyGen, mix, uint64(i))
+		if err != nil {
+			panic(err)
+		}
+		traces[i] = w.Trace(numOps / numWorkers)
 	}
 
 	// Start timing
@@ -163,17 +198,11 @@ func benchmarkCache(name string, c CacheBenchmark, writePct float32) {
 		go func(id int) {
 			defer wg.Done()
 
-			opsPerWorker := numOps / numWorkers
-			for j := 0; j < opsPerWorker; j++ {
-				// Determine operation based on write percentage
-				isWrite := (float64(j%100) / 100.0) < float64(writePct)
-
-				key := fmt.Sprintf("key%d", j%1000)
-
-				if isWrite {
-					c.Put(key, id*j)
+			for j, op := range traces[id] {
+				if op.Kind == workload.Write {
+					c.Put(keys[op.Key], id*j)
 				} else {
-					c.Get(key)
+					c.Get(keys[op.Key])
 				}
 			}
 		}(i)
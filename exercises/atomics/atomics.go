@@ -1,88 +1,65 @@
 package atomics
 
 import (
+	"concurrency/exercises/buggy"
 	"fmt"
 	"sync"
 	"sync/atomic"
 )
 
-func main() {
-	fmt.Println("Comparing different increment approaches:")
-
-	// Run the buggy version for comparison
-	fmt.Println("\nBuggy increment (with race condition):")
-	for i := 0; i < 3; i++ {
-		RunBuggyIncrement()
-	}
-
-	// Run the atomic version
-	fmt.Println("\nAtomic increment (thread-safe):")
-	for i := 0; i < 3; i++ {
-		RunAtomicIncrement()
-	}
-
-	// Run the mutex version
-	fmt.Println("\nMutex increment (thread-safe):")
-	for i := 0; i < 3; i++ {
-		RunMutexIncrement()
-	}
-
-	fmt.Println("\nExplanation:")
-	fmt.Println("- The buggy version doesn't handle concurrent access properly")
-	fmt.Println("- The atomic version uses atomic operations for thread safety")
-	fmt.Println("- The mutex version uses a lock to protect the critical section")
-}
-
 // Buggy increment - has a race condition
 func RunBuggyIncrement() {
-	var x = 0
-	var wg sync.WaitGroup
-
-	for i := 0; i < 1000; i++ {
-		wg.Add(1)
-		go func() {
-			x = x + 1 // This operation is not atomic!
-			wg.Done()
-		}()
-	}
-
-	wg.Wait()
-	fmt.Println("final value of x:", x)
+	fmt.Println("final value of x:", buggy.Increment(1000, 1))
 }
 
 // Atomic increment - uses atomic operations
 func RunAtomicIncrement() {
+	fmt.Println("final value of x:", AtomicIncrement(1000, 1))
+}
+
+// Mutex increment - uses a mutex
+func RunMutexIncrement() {
+	fmt.Println("final value of x:", MutexIncrement(1000, 1))
+}
+
+// AtomicIncrement is buggy.Increment with the shared variable updated by
+// atomic operations
+func AtomicIncrement(goroutines, iterations int) int {
 	var x atomic.Int64
 	var wg sync.WaitGroup
 
-	for i := 0; i < 1000; i++ {
+	for i := 0; i < goroutines; i++ {
 		wg.Add(1)
 		go func() {
-			x.Add(1) // This is an atomic operation
+			for j := 0; j < iterations; j++ {
+				x.Add(1) // This is an atomic operation
+			}
 			wg.Done()
 		}()
 	}
 
 	wg.Wait()
-	fmt.Println("final value of x:", x.Load())
+	return int(x.Load())
 }
 
-// Mutex increment - uses a mutex
-func RunMutexIncrement() {
+// MutexIncrement is buggy.Increment with every update made under a mutex
+func MutexIncrement(goroutines, iterations int) int {
 	var x = 0
 	var mu sync.Mutex
 	var wg sync.WaitGroup
 
-	for i := 0; i < 1000; i++ {
+	for i := 0; i < goroutines; i++ {
 		wg.Add(1)
 		go func() {
-			mu.Lock()
-			x = x + 1 // Protected by mutex
-			mu.Unlock()
+			for j := 0; j < iterations; j++ {
+				mu.Lock()
+				x = x + 1 // Protected by mutex
+				mu.Unlock()
+			}
 			wg.Done()
 		}()
 	}
 
 	wg.Wait()
-	fmt.Println("final value of x:", x)
+	return x
 }
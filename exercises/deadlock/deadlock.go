@@ -0,0 +1,151 @@
+package deadlock
+
+import (
+	"concurrency/exercises/internal/blocked"
+	"fmt"
+	"sync"
+	"time"
+)
+
+// Account is a bank account guarded by its own mutex
+type Account struct {
+	ID      int
+	mu      sync.Mutex
+	balance int
+}
+
+// NewAccount creates an account with an opening balance
+func NewAccount(id, balance int) *Account {
+	return &Account{ID: id, balance: balance}
+}
+
+// Balance returns the current balance
+func (a *Account) Balance() int {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	return a.balance
+}
+
+// TransferBroken locks the source account and then the destination. Two
+// transfers in opposite directions can each take their first lock and then
+// wait forever for the lock the other one holds.
+func TransferBroken(from, to *Account, amount int) {
+	transferBroken(from, to, amount, nil)
+}
+
+func transferBroken(from, to *Account, amount int, held func()) {
+	from.mu.Lock()
+	defer from.mu.Unlock()
+	if held != nil {
+		held()
+	}
+
+	to.mu.Lock()
+	defer to.mu.Unlock()
+
+	from.balance -= amount
+	to.balance += amount
+}
+
+// TransferFixed always locks the account with the lower ID first. Every
+// goroutine takes the locks in the same global order, so none can hold a lock
+// another is waiting for while waiting for one that goroutine holds.
+func TransferFixed(from, to *Account, amount int) {
+	transferFixed(from, to, amount, nil)
+}
+
+func transferFixed(from, to *Account, amount int, held func()) {
+	first, second := from, to
+	if second.ID < first.ID {
+		first, second = second, first
+	}
+
+	first.mu.Lock()
+	defer first.mu.Unlock()
+	if held != nil {
+		held()
+	}
+
+	second.mu.Lock()
+	defer second.mu.Unlock()
+
+	from.balance -= amount
+	to.balance += amount
+}
+
+// LockOrderingBroken runs two opposite transfers with TransferBroken and
+// returns blocked.ErrDeadlock when they never finish
+func LockOrderingBroken(timeout time.Duration) error {
+	return opposingTransfers(transferBroken, timeout)
+}
+
+// LockOrderingFixed runs the same two transfers with TransferFixed
+func LockOrderingFixed(timeout time.Duration) error {
+	return opposingTransfers(transferFixed, timeout)
+}
+
+// opposingTransfers moves money from a to b and from b to a at the same time.
+// Each transfer waits after its first lock until the other has taken its
+// first lock as well, so the broken ordering deadlocks on every run instead
+// of only when the scheduler happens to interleave them badly.
+func opposingTransfers(transfer func(from, to *Account, amount int, held func()), timeout time.Duration) error {
+	a, b := NewAccount(1, 100), NewAccount(2, 100)
+
+	var holding sync.WaitGroup
+	holding.Add(2)
+	bothHolding := make(chan struct{})
+	go func() {
+		holding.Wait()
+		close(bothHolding)
+	}()
+	held := func() {
+		holding.Done()
+		// With a consistent order the other transfer is blocked on our lock
+		// and can never take its first one, so don't wait for it forever
+		select {
+		case <-bothHolding:
+		case <-time.After(timeout / 10):
+		}
+	}
+
+	var wg sync.WaitGroup
+	wg.Add(2)
+	go func() {
+		defer wg.Done()
+		transfer(a, b, 10, held)
+	}()
+	go func() {
+		defer wg.Done()
+		transfer(b, a, 20, held)
+	}()
+
+	done := make(chan struct{})
+	go func() {
+		wg.Wait()
+		close(done)
+	}()
+
+	select {
+	case <-done:
+	case <-time.After(timeout):
+		return blocked.ErrDeadlock
+	}
+
+	if a.Balance() != 110 || b.Balance() != 90 {
+		return fmt.Errorf("expected balances 110 and 90, got %d and %d", a.Balance(), b.Balance())
+	}
+	return nil
+}
+
+// RunLockOrdering shows the deadlock and the fix
+func RunLockOrdering() {
+	fmt.Println("Transfers locking accounts in argument order:", describe(LockOrderingBroken(200*time.Millisecond)))
+	fmt.Println("Transfers locking accounts in ID order:", describe(LockOrderingFixed(200*time.Millisecond)))
+}
+
+func describe(err error) string {
+	if err != nil {
+		return err.Error()
+	}
+	return "finished"
+}
@@ -0,0 +1,70 @@
+package deadlock
+
+import (
+	"concurrency/exercises/internal/blocked"
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestLockOrderingBrokenDeadlocks(t *testing.T) {
+	if err := LockOrderingBroken(200 * time.Millisecond); !errors.Is(err, blocked.ErrDeadlock) {
+		t.Errorf("Expected opposite transfers to deadlock, got %v", err)
+	}
+}
+
+func TestLockOrderingFixed(t *testing.T) {
+	if err := LockOrderingFixed(time.Second); err != nil {
+		t.Errorf("Expected ordered transfers to finish, got %v", err)
+	}
+}
+
+func TestTransferFixedConcurrent(t *testing.T) {
+	a, b := NewAccount(1, 1000), NewAccount(2, 1000)
+
+	done := make(chan struct{})
+	for i := 0; i < 10; i++ {
+		go func() {
+			for j := 0; j < 100; j++ {
+				if i%2 == 0 {
+					TransferFixed(a, b, 1)
+				} else {
+					TransferFixed(b, a, 1)
+				}
+			}
+			done <- struct{}{}
+		}()
+	}
+	for i := 0; i < 10; i++ {
+		select {
+		case <-done:
+		case <-time.After(5 * time.Second):
+			t.Fatal("Transfers deadlocked")
+		}
+	}
+
+	if a.Balance() != 1000 || b.Balance() != 1000 {
+		t.Errorf("Expected balances 1000 and 1000, got %d and %d", a.Balance(), b.Balance())
+	}
+}
+
+func TestLivelockBroken(t *testing.T) {
+	rounds, err := LivelockBroken(100)
+	if !errors.Is(err, ErrLivelock) {
+		t.Errorf("Expected diners with a fixed backoff to livelock, both ate within %d rounds", rounds)
+	}
+}
+
+func TestLivelockFixed(t *testing.T) {
+	rounds, err := LivelockFixed(100)
+	if err != nil {
+		t.Fatalf("Expected diners with a randomized backoff to eat, got %v", err)
+	}
+
+	// The backoffs are seeded, so every run takes the same rounds
+	for i := 0; i < 5; i++ {
+		if again, _ := LivelockFixed(100); again != rounds {
+			t.Errorf("Expected %d rounds on every run, got %d", rounds, again)
+		}
+	}
+}
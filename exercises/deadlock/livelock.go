@@ -0,0 +1,158 @@
+package deadlock
+
+import (
+	"errors"
+	"fmt"
+	"math/rand/v2"
+	"sync"
+)
+
+// ErrLivelock is returned when the goroutines of an exercise keep running but
+// never make progress
+var ErrLivelock = errors.New("livelock: goroutines kept backing off without making progress")
+
+// lockstep is a reusable barrier. It stands in for two goroutines that are
+// perfectly symmetric: the same work, the same timing and the same backoff.
+// Real goroutines drift apart eventually; these never do, which makes the
+// livelock show on every run.
+type lockstep struct {
+	mu      sync.Mutex
+	cond    *sync.Cond
+	parties int
+	waiting int
+	phase   int
+}
+
+func newLockstep(parties int) *lockstep {
+	l := &lockstep{parties: parties}
+	l.cond = sync.NewCond(&l.mu)
+	return l
+}
+
+// await blocks until every remaining party has reached the barrier
+func (l *lockstep) await() {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	l.waiting++
+	if l.waiting >= l.parties {
+		l.release()
+		return
+	}
+	for phase := l.phase; phase == l.phase; {
+		l.cond.Wait()
+	}
+}
+
+// leave removes the caller from the barrier so the others stop waiting for it
+func (l *lockstep) leave() {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	l.parties--
+	if l.waiting > 0 && l.waiting >= l.parties {
+		l.release()
+	}
+}
+
+func (l *lockstep) release() {
+	l.waiting = 0
+	l.phase++
+	l.cond.Broadcast()
+}
+
+// diner needs its own fork and its neighbour's. Every round it picks up its
+// own fork, tries the neighbour's, and when that one is taken politely puts
+// its own back and sits out for backoff rounds before trying again. It
+// returns the round it finished in.
+func diner(own, other *sync.Mutex, step *lockstep, backoff func() int, maxRounds int) (int, error) {
+	defer step.leave()
+
+	sitOut := 0
+	for round := 1; round <= maxRounds; round++ {
+		active := sitOut == 0
+		if !active {
+			sitOut--
+		}
+
+		if active {
+			own.Lock()
+		}
+		step.await()
+
+		ate := active && other.TryLock()
+		// Wait for every diner to try before anyone puts a fork down, so a
+		// try never depends on how fast the other diner backed off
+		step.await()
+
+		if ate {
+			other.Unlock()
+			own.Unlock()
+			return round, nil
+		}
+		if active {
+			own.Unlock()
+			sitOut = backoff()
+		}
+		step.await()
+	}
+	return maxRounds, ErrLivelock
+}
+
+// dine runs two diners sharing two forks and returns the number of rounds
+// until both have eaten
+func dine(backoffs [2]func() int, maxRounds int) (int, error) {
+	var forks [2]sync.Mutex
+	step := newLockstep(2)
+
+	var wg sync.WaitGroup
+	var rounds [2]int
+	var errs [2]error
+	for i := range 2 {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			rounds[i], errs[i] = diner(&forks[i], &forks[1-i], step, backoffs[i], maxRounds)
+		}()
+	}
+	wg.Wait()
+
+	if err := errors.Join(errs[:]...); err != nil {
+		return maxRounds, ErrLivelock
+	}
+	return max(rounds[0], rounds[1]), nil
+}
+
+// LivelockBroken gives both diners the same fixed backoff. They pick up their
+// forks together, see each other's fork taken, put theirs down together and
+// come back together, forever.
+func LivelockBroken(maxRounds int) (int, error) {
+	fixed := func() int { return 1 }
+	return dine([2]func() int{fixed, fixed}, maxRounds)
+}
+
+// LivelockFixed gives each diner a randomized backoff. As soon as they draw
+// different waits, one finds both forks free while the other sits out.
+func LivelockFixed(maxRounds int) (int, error) {
+	var backoffs [2]func() int
+	for i := range backoffs {
+		rng := rand.New(rand.NewPCG(uint64(i), 0))
+		backoffs[i] = func() int { return 1 + rng.IntN(4) }
+	}
+	return dine(backoffs, maxRounds)
+}
+
+// RunLivelock shows the livelock and the fix
+func RunLivelock() {
+	rounds, err := LivelockBroken(50)
+	fmt.Println("Diners with a fixed backoff:", describeRounds(rounds, err))
+	rounds, err = LivelockFixed(50)
+	fmt.Println("Diners with a randomized backoff:", describeRounds(rounds, err))
+}
+
+func describeRounds(rounds int, err error) string {
+	if err != nil {
+		return fmt.Sprintf("%v after %d rounds", err, rounds)
+	}
+	return fmt.Sprintf("both ate within %d rounds", rounds)
+}
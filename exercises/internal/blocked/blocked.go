@@ -0,0 +1,9 @@
+// Package blocked holds what the lock exercises share for reporting
+// goroutines that never finish.
+package blocked
+
+import "errors"
+
+// ErrDeadlock is returned when the goroutines of an exercise are still
+// blocked after the timeout
+var ErrDeadlock = errors.New("deadlock: goroutines still blocked after the timeout")
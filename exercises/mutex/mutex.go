@@ -0,0 +1,156 @@
+package mutex
+
+import (
+	"concurrency/exercises/internal/blocked"
+	"fmt"
+	"sync"
+	"time"
+)
+
+// Counter is a counter guarded by its own mutex. It must not be copied after
+// first use.
+type Counter struct {
+	mu sync.Mutex
+	n  int
+}
+
+// Registry hands out counters by index
+type Registry interface {
+	Register() int
+	Update(i int, fn func(n *int))
+	Value(i int) int
+}
+
+// RegistryBroken stores its counters by value. Growing the slice copies every
+// Counter, mutex included: a goroutine still holding a pointer into the old
+// array updates a copy nobody reads again, and a mutex copied while locked
+// stays locked forever. go vet's copylocks check can't see copies made by
+// append, so nothing warns about it.
+type RegistryBroken struct {
+	mu       sync.Mutex
+	counters []Counter
+}
+
+// Register adds a counter and returns its index
+func (r *RegistryBroken) Register() int {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.counters = append(r.counters, Counter{})
+	return len(r.counters) - 1
+}
+
+// Update runs fn on counter i while holding the counter's lock
+func (r *RegistryBroken) Update(i int, fn func(n *int)) {
+	r.mu.Lock()
+	c := &r.counters[i]
+	r.mu.Unlock()
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	fn(&c.n)
+}
+
+// Value returns counter i
+func (r *RegistryBroken) Value(i int) int {
+	r.mu.Lock()
+	c := &r.counters[i]
+	r.mu.Unlock()
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.n
+}
+
+// RegistryFixed stores pointers, so growing the slice copies the pointers and
+// every Counter, with its mutex, stays where it was created
+type RegistryFixed struct {
+	mu       sync.Mutex
+	counters []*Counter
+}
+
+// Register adds a counter and returns its index
+func (r *RegistryFixed) Register() int {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.counters = append(r.counters, &Counter{})
+	return len(r.counters) - 1
+}
+
+// Update runs fn on counter i while holding the counter's lock
+func (r *RegistryFixed) Update(i int, fn func(n *int)) {
+	r.mu.Lock()
+	c := r.counters[i]
+	r.mu.Unlock()
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	fn(&c.n)
+}
+
+// Value returns counter i
+func (r *RegistryFixed) Value(i int) int {
+	r.mu.Lock()
+	c := r.counters[i]
+	r.mu.Unlock()
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.n
+}
+
+// CopiedMutexBroken increments a counter of a RegistryBroken while more
+// counters are registered, then reads it back. It returns blocked.ErrDeadlock when
+// the read blocks on a mutex that was copied while locked.
+func CopiedMutexBroken(timeout time.Duration) (int, error) {
+	return growWhileLocked(&RegistryBroken{}, timeout)
+}
+
+// CopiedMutexFixed runs the same steps against a RegistryFixed
+func CopiedMutexFixed(timeout time.Duration) (int, error) {
+	return growWhileLocked(&RegistryFixed{}, timeout)
+}
+
+// growWhileLocked registers counters while another goroutine holds the lock
+// of the first one, and returns the first counter once the update is done
+func growWhileLocked(r Registry, timeout time.Duration) (int, error) {
+	first := r.Register()
+
+	locked := make(chan struct{})
+	resume := make(chan struct{})
+	go r.Update(first, func(n *int) {
+		close(locked)
+		<-resume
+		*n++
+	})
+
+	<-locked
+	for i := 0; i < 8; i++ {
+		r.Register()
+	}
+	close(resume)
+
+	value := make(chan int, 1)
+	go func() {
+		value <- r.Value(first)
+	}()
+
+	select {
+	case v := <-value:
+		return v, nil
+	case <-time.After(timeout):
+		return 0, blocked.ErrDeadlock
+	}
+}
+
+// RunCopiedMutex shows the copied mutex bug and the fix
+func RunCopiedMutex() {
+	fmt.Println("Registry of Counter values:", describe(CopiedMutexBroken(200*time.Millisecond)))
+	fmt.Println("Registry of *Counter pointers:", describe(CopiedMutexFixed(200*time.Millisecond)))
+}
+
+func describe(value int, err error) string {
+	if err != nil {
+		return err.Error()
+	}
+	return fmt.Sprintf("counter is %d", value)
+}
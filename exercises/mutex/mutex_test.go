@@ -0,0 +1,49 @@
+package mutex
+
+import (
+	"concurrency/exercises/internal/blocked"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestCopiedMutexBrokenDeadlocks(t *testing.T) {
+	if v, err := CopiedMutexBroken(200 * time.Millisecond); !errors.Is(err, blocked.ErrDeadlock) {
+		t.Errorf("Expected reading a counter copied while locked to deadlock, got %d, %v", v, err)
+	}
+}
+
+func TestCopiedMutexFixed(t *testing.T) {
+	v, err := CopiedMutexFixed(time.Second)
+	if err != nil {
+		t.Fatalf("Expected the read to finish, got %v", err)
+	}
+	if v != 1 {
+		t.Errorf("Expected the update to be kept, got counter %d", v)
+	}
+}
+
+func TestRegistryFixedConcurrent(t *testing.T) {
+	r := &RegistryFixed{}
+	first := r.Register()
+
+	var wg sync.WaitGroup
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for j := 0; j < 100; j++ {
+				r.Update(first, func(n *int) { *n++ })
+				if j%10 == 0 {
+					r.Register()
+				}
+			}
+		}()
+	}
+	wg.Wait()
+
+	if v := r.Value(first); v != 1000 {
+		t.Errorf("Expected 1000 increments, got %d", v)
+	}
+}
@@ -0,0 +1,262 @@
+package rwmutex
+
+import (
+	"concurrency/exercises/internal/blocked"
+	"fmt"
+	"runtime"
+	"sync"
+	"time"
+)
+
+// rwLocker is the part of sync.RWMutex the starvation exercise needs
+type rwLocker interface {
+	RLock()
+	RUnlock()
+	Lock()
+	Unlock()
+}
+
+// ReaderPreferringLock is a hand-rolled read-write lock that admits a new
+// reader whenever no writer holds the lock, even while a writer is waiting.
+// As long as readers overlap, the reader count never drops to zero and the
+// writer never gets in.
+type ReaderPreferringLock struct {
+	mu      sync.Mutex
+	cond    *sync.Cond
+	readers int
+	writing bool
+}
+
+// NewReaderPreferringLock creates an unlocked ReaderPreferringLock
+func NewReaderPreferringLock() *ReaderPreferringLock {
+	l := &ReaderPreferringLock{}
+	l.cond = sync.NewCond(&l.mu)
+	return l
+}
+
+// RLock locks for reading
+func (l *ReaderPreferringLock) RLock() {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	for l.writing {
+		l.cond.Wait()
+	}
+	l.readers++
+}
+
+// RUnlock undoes a single RLock call
+func (l *ReaderPreferringLock) RUnlock() {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.readers--
+	if l.readers == 0 {
+		l.cond.Broadcast()
+	}
+}
+
+// Lock locks for writing once there are no readers and no writer
+func (l *ReaderPreferringLock) Lock() {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	for l.writing || l.readers > 0 {
+		l.cond.Wait()
+	}
+	l.writing = true
+}
+
+// Unlock unlocks for writing
+func (l *ReaderPreferringLock) Unlock() {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.writing = false
+	l.cond.Broadcast()
+}
+
+// WriterStarvationBroken keeps overlapping readers on a ReaderPreferringLock
+// for busy and returns how long a writer waited for the lock. The writer only
+// gets in once the readers stop.
+func WriterStarvationBroken(busy time.Duration) time.Duration {
+	return writerWait(NewReaderPreferringLock(), busy)
+}
+
+// WriterStarvationFixed runs the same readers on a sync.RWMutex, which stops
+// admitting new readers as soon as a writer calls Lock. The writer waits only
+// for the readers already inside.
+func WriterStarvationFixed(busy time.Duration) time.Duration {
+	return writerWait(&sync.RWMutex{}, busy)
+}
+
+// writerWait starts readers that each hold the read lock for a short time and
+// take it again straight away, staggered so there is always one inside, then
+// measures how long a writer takes to get the lock
+func writerWait(l rwLocker, busy time.Duration) time.Duration {
+	const readers = 8
+	const hold = 2 * time.Millisecond
+
+	stop := make(chan struct{})
+	var wg sync.WaitGroup
+	for i := 0; i < readers; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			time.Sleep(time.Duration(i) * hold / readers)
+			for {
+				select {
+				case <-stop:
+					return
+				default:
+				}
+				l.RLock()
+				time.Sleep(hold)
+				l.RUnlock()
+			}
+		}()
+	}
+	time.Sleep(hold)
+
+	timer := time.AfterFunc(busy, func() { close(stop) })
+	defer timer.Stop()
+
+	start := time.Now()
+	l.Lock()
+	waited := time.Since(start)
+	l.Unlock()
+
+	wg.Wait()
+	return waited
+}
+
+// Config is a set of settings guarded by a sync.RWMutex
+type Config struct {
+	mu     sync.RWMutex
+	values map[string]string
+}
+
+// NewConfig creates an empty Config
+func NewConfig() *Config {
+	return &Config{values: make(map[string]string)}
+}
+
+// Get returns the value of one setting
+func (c *Config) Get(key string) string {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	return c.values[key]
+}
+
+// Set changes one setting
+func (c *Config) Set(key, value string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.values[key] = value
+}
+
+// SnapshotBroken takes the read lock and then calls Get, which takes it
+// again. A read lock is not reentrant: if a writer calls Lock between the two
+// RLocks, the inner RLock queues behind the writer while the writer waits for
+// the outer read lock to be released, and neither ever proceeds.
+func (c *Config) SnapshotBroken(keys []string) map[string]string {
+	return c.snapshotBroken(keys, nil)
+}
+
+func (c *Config) snapshotBroken(keys []string, held func()) map[string]string {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	if held != nil {
+		held()
+	}
+
+	snapshot := make(map[string]string, len(keys))
+	for _, key := range keys {
+		snapshot[key] = c.Get(key)
+	}
+	return snapshot
+}
+
+// SnapshotFixed takes the read lock once and reads the map through getLocked,
+// which expects the caller to hold the lock
+func (c *Config) SnapshotFixed(keys []string) map[string]string {
+	return c.snapshotFixed(keys, nil)
+}
+
+func (c *Config) snapshotFixed(keys []string, held func()) map[string]string {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	if held != nil {
+		held()
+	}
+
+	snapshot := make(map[string]string, len(keys))
+	for _, key := range keys {
+		snapshot[key] = c.getLocked(key)
+	}
+	return snapshot
+}
+
+// getLocked returns the value of one setting. The caller must hold c.mu.
+func (c *Config) getLocked(key string) string {
+	return c.values[key]
+}
+
+// RecursiveRLockBroken takes a snapshot with SnapshotBroken while a writer
+// arrives, and returns blocked.ErrDeadlock when it never finishes
+func RecursiveRLockBroken(timeout time.Duration) error {
+	return snapshotDuringWrite((*Config).snapshotBroken, timeout)
+}
+
+// RecursiveRLockFixed takes the same snapshot with SnapshotFixed
+func RecursiveRLockFixed(timeout time.Duration) error {
+	return snapshotDuringWrite((*Config).snapshotFixed, timeout)
+}
+
+// snapshotDuringWrite starts a writer once the snapshot holds the read lock
+// and lets the snapshot continue only when the writer is queued, the moment
+// a second RLock can no longer get in
+func snapshotDuringWrite(snapshot func(c *Config, keys []string, held func()) map[string]string, timeout time.Duration) error {
+	c := NewConfig()
+	c.Set("region", "eu-west")
+
+	held := func() {
+		go c.Set("region", "us-east")
+		// TryRLock fails once a writer is waiting for the lock
+		for c.mu.TryRLock() {
+			c.mu.RUnlock()
+			runtime.Gosched()
+		}
+	}
+
+	done := make(chan map[string]string, 1)
+	go func() {
+		done <- snapshot(c, []string{"region"}, held)
+	}()
+
+	select {
+	case s := <-done:
+		if s["region"] != "eu-west" {
+			return fmt.Errorf("expected the snapshot to see region eu-west, got %q", s["region"])
+		}
+		return nil
+	case <-time.After(timeout):
+		return blocked.ErrDeadlock
+	}
+}
+
+// RunWriterStarvation shows writer starvation and the fix
+func RunWriterStarvation() {
+	busy := 100 * time.Millisecond
+	fmt.Printf("Writer wait with a reader-preferring lock: %v\n", WriterStarvationBroken(busy).Round(time.Millisecond))
+	fmt.Printf("Writer wait with sync.RWMutex: %v\n", WriterStarvationFixed(busy).Round(time.Millisecond))
+}
+
+// RunRecursiveRLock shows the recursive read lock deadlock and the fix
+func RunRecursiveRLock() {
+	fmt.Println("Snapshot calling Get under the read lock:", describe(RecursiveRLockBroken(200*time.Millisecond)))
+	fmt.Println("Snapshot reading under a single read lock:", describe(RecursiveRLockFixed(200*time.Millisecond)))
+}
+
+func describe(err error) string {
+	if err != nil {
+		return err.Error()
+	}
+	return "finished"
+}
@@ -0,0 +1,73 @@
+package rwmutex
+
+import (
+	"concurrency/exercises/internal/blocked"
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestWriterStarvation(t *testing.T) {
+	busy := 200 * time.Millisecond
+
+	if waited := WriterStarvationBroken(busy); waited < busy/2 {
+		t.Errorf("Expected the writer to starve for about %v behind a reader-preferring lock, it waited %v", busy, waited)
+	}
+	if waited := WriterStarvationFixed(busy); waited >= busy/2 {
+		t.Errorf("Expected the writer to get a sync.RWMutex well within %v, it waited %v", busy, waited)
+	}
+}
+
+func TestReaderPreferringLockExcludesWriters(t *testing.T) {
+	l := NewReaderPreferringLock()
+	l.RLock()
+
+	locked := make(chan struct{})
+	go func() {
+		l.Lock()
+		close(locked)
+	}()
+
+	select {
+	case <-locked:
+		t.Fatal("Writer got the lock while a reader held it")
+	case <-time.After(20 * time.Millisecond):
+	}
+
+	l.RUnlock()
+	select {
+	case <-locked:
+	case <-time.After(time.Second):
+		t.Fatal("Writer didn't get the lock after the reader left")
+	}
+	l.Unlock()
+}
+
+func TestRecursiveRLockBrokenDeadlocks(t *testing.T) {
+	if err := RecursiveRLockBroken(200 * time.Millisecond); !errors.Is(err, blocked.ErrDeadlock) {
+		t.Errorf("Expected the nested read lock to deadlock, got %v", err)
+	}
+}
+
+func TestRecursiveRLockFixed(t *testing.T) {
+	if err := RecursiveRLockFixed(time.Second); err != nil {
+		t.Errorf("Expected the snapshot to finish, got %v", err)
+	}
+}
+
+func TestSnapshot(t *testing.T) {
+	c := NewConfig()
+	c.Set("region", "eu-west")
+	c.Set("tier", "gold")
+
+	keys := []string{"region", "tier", "missing"}
+	for name, snapshot := range map[string]func([]string) map[string]string{
+		"broken": c.SnapshotBroken,
+		"fixed":  c.SnapshotFixed,
+	} {
+		s := snapshot(keys)
+		if s["region"] != "eu-west" || s["tier"] != "gold" || s["missing"] != "" {
+			t.Errorf("%s: unexpected snapshot %v", name, s)
+		}
+	}
+}
@@ -0,0 +1,67 @@
+package waitgroup
+
+import (
+	"fmt"
+	"sync"
+	"sync/atomic"
+)
+
+// SumBroken adds the numbers in parallel, but each goroutine calls wg.Add
+// itself. Wait can run before any goroutine has started, see a zero counter
+// and return with a partial sum, or none at all.
+func SumBroken(nums []int) int {
+	return sumBroken(nums, false)
+}
+
+// sumBroken holds every goroutine back until Wait has returned when
+// lateAdd is set, so the early return happens on every run
+func sumBroken(nums []int, lateAdd bool) int {
+	var wg sync.WaitGroup
+	var total atomic.Int64
+	waited := make(chan struct{})
+
+	for _, n := range nums {
+		go func() {
+			if lateAdd {
+				<-waited
+			}
+			wg.Add(1) // Too late: Wait may already have returned
+			defer wg.Done()
+			total.Add(int64(n))
+		}()
+	}
+
+	wg.Wait()
+	sum := int(total.Load())
+	close(waited)
+	return sum
+}
+
+// SumFixed calls wg.Add before starting each goroutine, so Wait always sees
+// every goroutine it has to wait for
+func SumFixed(nums []int) int {
+	var wg sync.WaitGroup
+	var total atomic.Int64
+
+	for _, n := range nums {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			total.Add(int64(n))
+		}()
+	}
+
+	wg.Wait()
+	return int(total.Load())
+}
+
+// RunWaitGroup shows the WaitGroup misuse and the fix
+func RunWaitGroup() {
+	nums := make([]int, 100)
+	for i := range nums {
+		nums[i] = i + 1
+	}
+
+	fmt.Println("Sum with wg.Add inside the goroutines:", sumBroken(nums, true))
+	fmt.Println("Sum with wg.Add before starting them:", SumFixed(nums))
+}
@@ -0,0 +1,23 @@
+package waitgroup
+
+import "testing"
+
+func TestSumBrokenReturnsEarly(t *testing.T) {
+	nums := []int{1, 2, 3, 4, 5}
+	if sum := sumBroken(nums, true); sum != 0 {
+		t.Errorf("Expected Wait to return before any goroutine added its number, got sum %d", sum)
+	}
+}
+
+func TestSumFixed(t *testing.T) {
+	nums := make([]int, 1000)
+	for i := range nums {
+		nums[i] = i + 1
+	}
+
+	for i := 0; i < 10; i++ {
+		if sum := SumFixed(nums); sum != 500500 {
+			t.Fatalf("Expected sum 500500, got %d", sum)
+		}
+	}
+}
@@ -0,0 +1,114 @@
+package workload
+
+import (
+	"errors"
+	"fmt"
+	"math/rand/v2"
+)
+
+// Uniform draws keys uniformly from [0, n)
+type Uniform struct {
+	n   uint64
+	rng *rand.Rand
+}
+
+// NewUniform creates a uniform generator over n keys
+func NewUniform(n uint64, seed uint64) (*Uniform, error) {
+	if n == 0 {
+		return nil, errors.New("workload: Uniform needs at least one key")
+	}
+	return &Uniform{n: n, rng: newRand(seed)}, nil
+}
+
+func (g *Uniform) Next() uint64 {
+	return g.rng.Uint64N(g.n)
+}
+
+// Sequential scans keys 0, 1, ..., n-1 and then starts over. Scans are the
+// classic pattern that flushes frequently used entries out of an LRU cache.
+type Sequential struct {
+	n    uint64
+	next uint64
+}
+
+// NewSequential creates a scan over n keys
+func NewSequential(n uint64) (*Sequential, error) {
+	if n == 0 {
+		return nil, errors.New("workload: Sequential needs at least one key")
+	}
+	return &Sequential{n: n}, nil
+}
+
+func (g *Sequential) Next() uint64 {
+	key := g.next
+	g.next = (g.next + 1) % g.n
+	return key
+}
+
+// Hotspot sends a fixed share of the operations to a small, hot part of the
+// key space and spreads the rest uniformly over the remaining keys
+type Hotspot struct {
+	n       uint64
+	hotKeys uint64
+	hotOps  float64
+	rng     *rand.Rand
+}
+
+// NewHotspot creates a generator over n keys where the first hotFraction of
+// the keys receive hotOpFraction of the operations, e.g. 0.2 and 0.8
+func NewHotspot(n uint64, hotFraction, hotOpFraction float64, seed uint64) (*Hotspot, error) {
+	if hotFraction <= 0 || hotFraction >= 1 || hotOpFraction < 0 || hotOpFraction > 1 {
+		return nil, fmt.Errorf("workload: invalid hotspot %v/%v", hotFraction, hotOpFraction)
+	}
+
+	hotKeys := max(uint64(float64(n)*hotFraction), 1)
+	if hotKeys >= n {
+		return nil, fmt.Errorf("workload: Hotspot over %d keys needs keys outside the hot set", n)
+	}
+	return &Hotspot{n: n, hotKeys: hotKeys, hotOps: hotOpFraction, rng: newRand(seed)}, nil
+}
+
+func (g *Hotspot) Next() uint64 {
+	if g.rng.Float64() < g.hotOps {
+		return g.rng.Uint64N(g.hotKeys)
+	}
+	return g.hotKeys + g.rng.Uint64N(g.n-g.hotKeys)
+}
+
+// Shifting moves the working set through the key space in phases: for period
+// operations keys are drawn from one window of workingSet keys, then the
+// window jumps to the next, disjoint one. It models traffic whose hot data
+// changes over time, like a news site or a daily batch job.
+type Shifting struct {
+	n          uint64
+	inner      Generator
+	workingSet uint64
+	period     int
+	ops        int
+	phase      uint64
+}
+
+// NewShifting creates a shifting generator over n keys. inner chooses keys
+// within the current working set and must produce ids below workingSet.
+func NewShifting(n uint64, inner Generator, workingSet uint64, period int) (*Shifting, error) {
+	if workingSet == 0 || workingSet > n || period <= 0 {
+		return nil, fmt.Errorf("workload: invalid shifting working set %d of %d every %d", workingSet, n, period)
+	}
+	return &Shifting{n: n, inner: inner, workingSet: workingSet, period: period}, nil
+}
+
+func (g *Shifting) Next() uint64 {
+	if g.ops == g.period {
+		g.ops = 0
+		g.phase++
+	}
+	g.ops++
+
+	offset := (g.phase * g.workingSet) % g.n
+	return (offset + g.inner.Next()%g.workingSet) % g.n
+}
+
+// Phase returns the number of working set shifts so far
+func (g *Shifting) Phase() uint64 {
+	return g.phase
+}
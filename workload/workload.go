@@ -0,0 +1,127 @@
+// Package workload generates synthetic, seeded cache workloads: key
+// distributions such as Zipfian, scans, hotspots and shifting working sets,
+// combined with a read/write/delete mix.
+//
+// Generators are not safe for concurrent use. Give every goroutine its own
+// generator, seeded differently, or pre-generate a trace with Trace.
+package workload
+
+import (
+	"fmt"
+	"math/rand/v2"
+	"strconv"
+)
+
+// Generator produces a stream of key ids
+type Generator interface {
+	// Next returns the next key id
+	Next() uint64
+}
+
+// inserter is implemented by generators whose key space grows, like Latest.
+// A write drawn from a Workload over such a generator inserts a new key.
+type inserter interface {
+	Insert() uint64
+}
+
+// newRand returns a seeded PCG source; the same seed always yields the same stream
+func newRand(seed uint64) *rand.Rand {
+	return rand.New(rand.NewPCG(seed, seed^0x9e3779b97f4a7c15))
+}
+
+// OpKind is the kind of a cache operation
+type OpKind uint8
+
+const (
+	Read OpKind = iota
+	Write
+	Delete
+)
+
+func (k OpKind) String() string {
+	switch k {
+	case Read:
+		return "read"
+	case Write:
+		return "write"
+	case Delete:
+		return "delete"
+	default:
+		return fmt.Sprintf("OpKind(%d)", uint8(k))
+	}
+}
+
+// Operation is a single cache operation
+type Operation struct {
+	Kind OpKind
+	Key  uint64
+}
+
+// Mix gives the relative weights of reads, writes and deletes. The weights
+// don't need to add up to one.
+type Mix struct {
+	Read   float64
+	Write  float64
+	Delete float64
+}
+
+// Common mixes
+var (
+	ReadOnly   = Mix{Read: 1}
+	ReadHeavy  = Mix{Read: 0.95, Write: 0.05}
+	Balanced   = Mix{Read: 0.5, Write: 0.5}
+	WriteHeavy = Mix{Read: 0.2, Write: 0.8}
+)
+
+// Workload draws operations from a key generator and an operation mix
+type Workload struct {
+	keys       Generator
+	rng        *rand.Rand
+	readBelow  float64 // a uniform draw below this is a read
+	writeBelow float64 // below this (and not a read) is a write, otherwise a delete
+}
+
+// New creates a workload drawing keys from keys and operation kinds from mix
+func New(keys Generator, mix Mix, seed uint64) (*Workload, error) {
+	total := mix.Read + mix.Write + mix.Delete
+	if mix.Read < 0 || mix.Write < 0 || mix.Delete < 0 || total <= 0 {
+		return nil, fmt.Errorf("workload: invalid mix %+v", mix)
+	}
+
+	return &Workload{
+		keys:       keys,
+		rng:        newRand(seed),
+		readBelow:  mix.Read / total,
+		writeBelow: (mix.Read + mix.Write) / total,
+	}, nil
+}
+
+// Next returns the next operation
+func (w *Workload) Next() Operation {
+	p := w.rng.Float64()
+	switch {
+	case p < w.readBelow:
+		return Operation{Kind: Read, Key: w.keys.Next()}
+	case p < w.writeBelow:
+		if ins, ok := w.keys.(inserter); ok {
+			return Operation{Kind: Write, Key: ins.Insert()}
+		}
+		return Operation{Kind: Write, Key: w.keys.Next()}
+	default:
+		return Operation{Kind: Delete, Key: w.keys.Next()}
+	}
+}
+
+// Trace pre-generates n operations, so benchmarks don't time the generator
+func (w *Workload) Trace(n int) []Operation {
+	ops := make([]Operation, n)
+	for i := range ops {
+		ops[i] = w.Next()
+	}
+	return ops
+}
+
+// Key formats a key id as a string key such as "key42"
+func Key(id uint64) string {
+	return "key" + strconv.FormatUint(id, 10)
+}
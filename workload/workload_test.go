@@ -0,0 +1,191 @@
+package workload
+
+import (
+	"math"
+	"testing"
+)
+
+// must returns g, panicking if its constructor rejected valid test parameters
+func must[G any](g G, err error) G {
+	if err != nil {
+		panic(err)
+	}
+	return g
+}
+
+// frequencies counts how often each key is drawn in n draws
+func frequencies(g Generator, n int) map[uint64]int {
+	counts := make(map[uint64]int)
+	for i := 0; i < n; i++ {
+		counts[g.Next()]++
+	}
+	return counts
+}
+
+func TestSeededGeneratorsAreDeterministic(t *testing.T) {
+	generators := map[string]func() Generator{
+		"uniform":  func() Generator { return must(NewUniform(1000, 42)) },
+		"zipfian":  func() Generator { return must(NewZipfian(1000, 0.99, 42)) },
+		"hotspot":  func() Generator { return must(NewHotspot(1000, 0.2, 0.8, 42)) },
+		"latest":   func() Generator { return must(NewLatest(1000, 0.99, 42)) },
+		"shifting": func() Generator { return must(NewShifting(1000, must(NewUniform(100, 42)), 100, 10)) },
+	}
+
+	for name, newGen := range generators {
+		a, b := newGen(), newGen()
+		for i := 0; i < 1000; i++ {
+			if x, y := a.Next(), b.Next(); x != y {
+				t.Fatalf("%s: draw %d differs between equal seeds: %d vs %d", name, i, x, y)
+			}
+		}
+	}
+
+	if must(NewUniform(1000, 1)).Next() == must(NewUniform(1000, 2)).Next() &&
+		must(NewUniform(1000, 1)).Next() == must(NewUniform(1000, 3)).Next() {
+		t.Error("Different seeds should produce different streams")
+	}
+}
+
+func TestZipfian(t *testing.T) {
+	const n, draws = 1000, 200000
+
+	for _, skew := range []float64{0.5, 0.99, 1.2} {
+		counts := frequencies(must(NewZipfian(n, skew, 1)), draws)
+
+		for key := range counts {
+			if key >= n {
+				t.Fatalf("skew %v: key %d out of range", skew, key)
+			}
+		}
+
+		// Key 0 is drawn with probability 1/zeta(n)
+		want := 1 / zeta(0, n, skew, 0)
+		got := float64(counts[0]) / draws
+		if math.Abs(got-want) > 0.1*want {
+			t.Errorf("skew %v: expected key 0 with frequency %.4f, got %.4f", skew, want, got)
+		}
+		if counts[0] <= counts[10] || counts[10] <= counts[500] {
+			t.Errorf("skew %v: popularity should fall with rank, got %d, %d, %d", skew, counts[0], counts[10], counts[500])
+		}
+	}
+}
+
+func TestSequential(t *testing.T) {
+	g := must(NewSequential(3))
+	for i, want := range []uint64{0, 1, 2, 0, 1} {
+		if got := g.Next(); got != want {
+			t.Errorf("Draw %d: expected %d, got %d", i, want, got)
+		}
+	}
+}
+
+func TestHotspot(t *testing.T) {
+	const draws = 100000
+	counts := frequencies(must(NewHotspot(1000, 0.1, 0.9, 1)), draws)
+
+	hot := 0
+	for key, count := range counts {
+		if key >= 1000 {
+			t.Fatalf("Key %d out of range", key)
+		}
+		if key < 100 {
+			hot += count
+		}
+	}
+	if share := float64(hot) / draws; math.Abs(share-0.9) > 0.01 {
+		t.Errorf("Expected 90%% of draws on the hot keys, got %.1f%%", share*100)
+	}
+}
+
+func TestShifting(t *testing.T) {
+	g := must(NewShifting(1000, must(NewUniform(100, 1)), 100, 50))
+
+	for phase := uint64(0); phase < 12; phase++ {
+		low := (phase * 100) % 1000
+		for i := 0; i < 50; i++ {
+			if key := g.Next(); key < low || key >= low+100 {
+				t.Fatalf("Phase %d: key %d outside working set [%d, %d)", phase, key, low, low+100)
+			}
+		}
+		if g.Phase() != phase {
+			t.Fatalf("Expected phase %d, got %d", phase, g.Phase())
+		}
+	}
+}
+
+func TestLatest(t *testing.T) {
+	g := must(NewLatest(1000, 0.99, 1))
+
+	if key := g.Insert(); key != 1000 {
+		t.Fatalf("Expected the first insert to be key 1000, got %d", key)
+	}
+
+	counts := frequencies(g, 10000)
+	if counts[1000] <= counts[500] {
+		t.Errorf("The newest key should be the hottest, got %d draws vs %d", counts[1000], counts[500])
+	}
+	for key := range counts {
+		if key > 1000 {
+			t.Fatalf("Key %d was never inserted", key)
+		}
+	}
+}
+
+func TestWorkloadMix(t *testing.T) {
+	const draws = 100000
+	w := must(New(must(NewUniform(100, 1)), Mix{Read: 7, Write: 2, Delete: 1}, 2))
+
+	counts := map[OpKind]int{}
+	for _, op := range w.Trace(draws) {
+		counts[op.Kind]++
+	}
+
+	for kind, want := range map[OpKind]float64{Read: 0.7, Write: 0.2, Delete: 0.1} {
+		if got := float64(counts[kind]) / draws; math.Abs(got-want) > 0.01 {
+			t.Errorf("Expected %.0f%% %v operations, got %.1f%%", want*100, kind, got*100)
+		}
+	}
+}
+
+func TestWorkloadInsertsLatest(t *testing.T) {
+	w := must(New(must(NewLatest(10, 0.99, 1)), Mix{Write: 1}, 1))
+
+	for i := uint64(0); i < 5; i++ {
+		if op := w.Next(); op.Kind != Write || op.Key != 10+i {
+			t.Errorf("Expected a write of new key %d, got %v %d", 10+i, op.Kind, op.Key)
+		}
+	}
+}
+
+func TestInvalidParameters(t *testing.T) {
+	uniform := must(NewUniform(10, 1))
+	for name, err := range map[string]error{
+		"zipfian without keys":     errOf(NewZipfian(0, 0.99, 1)),
+		"zipfian with skew 1":      errOf(NewZipfian(100, 1, 1)),
+		"latest with skew 0":       errOf(NewLatest(100, 0, 1)),
+		"uniform without keys":     errOf(NewUniform(0, 1)),
+		"sequential without keys":  errOf(NewSequential(0)),
+		"hotspot of every key":     errOf(NewHotspot(1, 0.2, 0.8, 1)),
+		"hotspot over 100%":        errOf(NewHotspot(100, 0.2, 1.5, 1)),
+		"shifting past the keys":   errOf(NewShifting(10, uniform, 20, 5)),
+		"shifting without a phase": errOf(NewShifting(10, uniform, 5, 0)),
+		"negative mix":             errOf(New(uniform, Mix{Read: 2, Write: -1}, 1)),
+		"empty mix":                errOf(New(uniform, Mix{}, 1)),
+	} {
+		if err == nil {
+			t.Errorf("%s: expected an error", name)
+		}
+	}
+}
+
+// errOf returns just the error of a constructor
+func errOf[G any](_ G, err error) error {
+	return err
+}
+
+func BenchmarkZipfian(b *testing.B) {
+	g := must(NewZipfian(1_000_000, 0.99, 1))
+	for i := 0; i < b.N; i++ {
+		g.Next()
+	}
+}
@@ -0,0 +1,100 @@
+package workload
+
+import (
+	"fmt"
+	"math"
+	"math/rand/v2"
+)
+
+// Zipfian draws keys from [0, n) with a Zipfian distribution: key 0 is the
+// most popular and key i is requested with probability proportional to
+// 1/(i+1)^skew. A skew of 0.99 matches the YCSB default; higher is more skewed.
+//
+// It uses the rejection-free method of Gray et al., "Quickly Generating
+// Billion-Record Synthetic Databases", as YCSB does.
+type Zipfian struct {
+	n     uint64
+	skew  float64
+	zetaN float64 // sum of 1/i^skew for i in 1..n
+	zeta2 float64
+	alpha float64
+	eta   float64
+	rng   *rand.Rand
+}
+
+// NewZipfian creates a Zipfian generator over n keys. skew must be positive
+// and not exactly 1.
+func NewZipfian(n uint64, skew float64, seed uint64) (*Zipfian, error) {
+	if n == 0 || skew <= 0 || skew == 1 {
+		return nil, fmt.Errorf("workload: invalid Zipfian over %d keys with skew %v", n, skew)
+	}
+
+	z := &Zipfian{
+		skew:  skew,
+		zeta2: zeta(0, 2, skew, 0),
+		alpha: 1 / (1 - skew),
+		rng:   newRand(seed),
+	}
+	z.resize(n)
+	return z, nil
+}
+
+// zeta extends the partial sum zeta(from) to zeta(to)
+func zeta(from, to uint64, skew, sum float64) float64 {
+	for i := from; i < to; i++ {
+		sum += 1 / math.Pow(float64(i+1), skew)
+	}
+	return sum
+}
+
+// resize grows the key space to n, extending zeta incrementally
+func (z *Zipfian) resize(n uint64) {
+	z.zetaN = zeta(z.n, n, z.skew, z.zetaN)
+	z.n = n
+	z.eta = (1 - math.Pow(2/float64(n), 1-z.skew)) / (1 - z.zeta2/z.zetaN)
+}
+
+func (z *Zipfian) Next() uint64 {
+	u := z.rng.Float64()
+	uz := u * z.zetaN
+
+	if uz < 1 {
+		return 0
+	}
+	if uz < 1+math.Pow(0.5, z.skew) {
+		return min(1, z.n-1)
+	}
+
+	key := uint64(float64(z.n) * math.Pow(z.eta*u-z.eta+1, z.alpha))
+	return min(key, z.n-1)
+}
+
+// Latest favours recently inserted keys, like a timeline or news feed where
+// new items are read the most. Reads are Zipfian over recency; writes from a
+// Workload insert a new key, which immediately becomes the hottest.
+type Latest struct {
+	zipf *Zipfian
+	max  uint64 // number of keys inserted so far
+}
+
+// NewLatest creates a Latest generator that starts with n existing keys,
+// with skew as in NewZipfian
+func NewLatest(n uint64, skew float64, seed uint64) (*Latest, error) {
+	zipf, err := NewZipfian(n, skew, seed)
+	if err != nil {
+		return nil, err
+	}
+	return &Latest{zipf: zipf, max: n}, nil
+}
+
+func (g *Latest) Next() uint64 {
+	return g.max - 1 - g.zipf.Next()
+}
+
+// Insert adds a new key and returns its id
+func (g *Latest) Insert() uint64 {
+	key := g.max
+	g.max++
+	g.zipf.resize(g.max)
+	return key
+}